@@ -0,0 +1,98 @@
+package logr
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefBlockedProducerInterval is the minimum interval between calls to
+	// `Logr.OnBlockedProducer`.
+	DefBlockedProducerInterval = time.Second
+
+	// DefaultDroppedRecordsBuffer is the number of log records buffered by the
+	// `Logr.DroppedRecords` channel before further dropped records are discarded.
+	DefaultDroppedRecordsBuffer = 100
+)
+
+// QueueFullDrops returns the total number of log records dropped because the
+// Logr queue was full, either via `OnQueueFull` or because `EnqueueTimeout`
+// elapsed while blocked.
+func (logr *Logr) QueueFullDrops() uint64 {
+	return atomic.LoadUint64(&logr.queueDrops)
+}
+
+// isDegraded returns true if a blocked producer has timed out and the queue has
+// not had room since. While degraded, log records arriving to a full queue are
+// dropped immediately rather than blocking each producer for `EnqueueTimeout`,
+// so a wedged target slows producers once rather than on every logging call.
+func (logr *Logr) isDegraded() bool {
+	return atomic.LoadInt32(&logr.degraded) == 1
+}
+
+func (logr *Logr) setDegraded(degraded bool) {
+	var v int32
+	if degraded {
+		v = 1
+	}
+	atomic.StoreInt32(&logr.degraded, v)
+}
+
+// dropQueueFull counts a log record dropped because the queue was full.
+func (logr *Logr) dropQueueFull(rec *LogRec) {
+	atomic.AddUint64(&logr.queueDrops, 1)
+	logr.notifyDropped(rec)
+}
+
+// DroppedRecords returns a channel that receives a copy of each log record
+// dropped because the Logr queue or a target queue was full, so what is being
+// lost can be inspected while diagnosing backpressure. This is best-effort
+// observability rather than a guarantee: nothing is sent before the first
+// call, and the channel is buffered and itself lossy, so dropped records are
+// discarded while it is full. Logging never blocks on this channel and it is
+// never closed.
+func (logr *Logr) DroppedRecords() <-chan *LogRec {
+	logr.droppedMux.Lock()
+	defer logr.droppedMux.Unlock()
+
+	if logr.dropped == nil {
+		logr.dropped = make(chan *LogRec, DefaultDroppedRecordsBuffer)
+	}
+	return logr.dropped
+}
+
+// notifyDropped sends a copy of a dropped log record to the `DroppedRecords`
+// channel, if any, without blocking.
+func (logr *Logr) notifyDropped(rec *LogRec) {
+	logr.droppedMux.Lock()
+	ch := logr.dropped
+	logr.droppedMux.Unlock()
+
+	if ch == nil || rec.flush != nil {
+		return
+	}
+	rec.prep()
+	select {
+	case ch <- rec.WithTime(rec.Time()):
+	default:
+	}
+}
+
+// beginBlocked records a producer blocking on a full queue, calling
+// `OnBlockedProducer` at most once per DefBlockedProducerInterval.
+// Returns a function to call when the producer is no longer blocked.
+func (logr *Logr) beginBlocked() func() {
+	blocked := atomic.AddInt32(&logr.blockedProducers, 1)
+
+	if logr.OnBlockedProducer != nil {
+		now := time.Now().UnixNano()
+		last := atomic.LoadInt64(&logr.lastBlockedNotify)
+		if now-last >= int64(DefBlockedProducerInterval) && atomic.CompareAndSwapInt64(&logr.lastBlockedNotify, last, now) {
+			logr.OnBlockedProducer(int(blocked), logr.maxQueueSizeActual)
+		}
+	}
+
+	return func() {
+		atomic.AddInt32(&logr.blockedProducers, -1)
+	}
+}
@@ -0,0 +1,59 @@
+package logr_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockedProducerDegrades(t *testing.T) {
+	var notified int32
+	lgr := &logr.Logr{
+		MaxQueueSize:   10,
+		EnqueueTimeout: 50 * time.Millisecond,
+		OnLoggerError:  func(err error) {},
+		OnBlockedProducer: func(blocked int, maxQueueSize int) {
+			atomic.AddInt32(&notified, 1)
+		},
+	}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true}
+
+	// a wedged target that blocks until released.
+	release := make(chan struct{})
+	fn := func(formatted []byte, rec *logr.LogRec) {
+		<-release
+	}
+	err := lgr.AddTarget(target.NewFuncTarget(filter, formatter, fn, 1))
+	require.NoError(t, err)
+
+	const count = 200
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger := lgr.NewLogger()
+		for i := 0; i < count; i++ {
+			logger.Info("record")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("producers blocked by wedged target")
+	}
+	close(release)
+
+	drops := lgr.QueueFullDrops()
+	assert.True(t, drops > 0 && drops < count, "unexpected drops %d", drops)
+	assert.True(t, atomic.LoadInt32(&notified) >= 1, "OnBlockedProducer not called")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+}
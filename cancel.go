@@ -0,0 +1,99 @@
+package logr
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// DefCancelledKey is the field key added, with the value true, to log records
+// created after the context bound via `Logger.BindContext` is cancelled.
+const DefCancelledKey = "cancelled"
+
+// CancelOptions configures how a Logger created via `Logger.BindContext`
+// behaves once its context is cancelled.
+type CancelOptions struct {
+	// MinLevel, when set, suppresses log records less severe than this level
+	// once the context is cancelled, e.g. `logr.Warn` drops info, debug and
+	// trace records logged during graceful cancellation. The zero Level
+	// suppresses nothing.
+	MinLevel Level
+
+	// Msg, when not empty, is logged once, at Level, when the context is
+	// cancelled, e.g. "operation cancelled". It is not subject to MinLevel.
+	Msg string
+
+	// Level is the level Msg is logged at. The zero Level defaults to Info.
+	Level Level
+}
+
+// cancelWatch holds the cancellation state of a context, shared by all Loggers
+// derived from the Logger that created it.
+type cancelWatch struct {
+	cancelled int32 // accessed atomically
+	opts      CancelOptions
+}
+
+func (cw *cancelWatch) isCancelled() bool {
+	return atomic.LoadInt32(&cw.cancelled) == 1
+}
+
+// suppresses returns true if log records at the level are dropped because the
+// context is cancelled.
+func (cw *cancelWatch) suppresses(lvl Level) bool {
+	if cw.opts.MinLevel == (Level{}) || !cw.isCancelled() {
+		return false
+	}
+	return lvl.ID > cw.opts.MinLevel.ID
+}
+
+// BindContext creates a new `Logger` that tracks cancellation of ctx. Once ctx
+// is cancelled, log records are tagged with a field keyed by DefCancelledKey,
+// and are optionally suppressed below a level, distinguishing logs emitted
+// during graceful cancellation from normal flow. Loggers derived from it via
+// `WithFields` share the same state.
+//
+// Cancellation is detected by a goroutine that waits on ctx, so logging calls
+// only read a flag. That goroutine exits when ctx is done, so ctx should
+// eventually be cancelled; a context that is never done, such as
+// `context.Background()`, is ignored.
+func (logger Logger) BindContext(ctx context.Context, opts CancelOptions) Logger {
+	done := ctx.Done()
+	if done == nil {
+		return logger
+	}
+	if opts.Level == (Level{}) {
+		opts.Level = Info
+	}
+
+	l := logger
+	cw := &cancelWatch{opts: opts}
+	l.cancel = cw
+
+	onCancel := func() {
+		atomic.StoreInt32(&cw.cancelled, 1)
+		if opts.Msg != "" {
+			// not subject to MinLevel.
+			summary := l
+			summary.cancel = nil
+			summary.WithField(DefCancelledKey, true).Log(opts.Level, opts.Msg)
+		}
+	}
+	select {
+	case <-done:
+		onCancel()
+	default:
+		go func() {
+			<-done
+			onCancel()
+		}()
+	}
+	return l
+}
+
+// addCancelled adds the cancelled field if the log record's Logger is bound
+// to a context that has been cancelled.
+func (rec *LogRec) addCancelled() {
+	if rec.logger.cancel != nil && rec.logger.cancel.isCancelled() {
+		rec.logger = rec.logger.WithField(DefCancelledKey, true)
+	}
+}
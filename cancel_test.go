@@ -0,0 +1,71 @@
+package logr_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindContext(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	tgt := target.NewWriterTarget(filter, formatter, buf, 100)
+	tgt.SetSynchronous(true)
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := logr.CancelOptions{MinLevel: logr.Warn, Msg: "operation cancelled"}
+	logger := lgr.NewLogger().BindContext(ctx, opts).WithField("op", "sync")
+
+	logger.Info("working")
+	cancel()
+
+	// the summary record is logged by the watcher goroutine.
+	for i := 0; i < 500 && !strings.Contains(buf.String(), "operation cancelled"); i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	logger.Info("suppressed")
+	logger.Warn("cleaning up")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	want := "info | working | op=sync\n" +
+		"info | operation cancelled | cancelled=true\n" +
+		"warn | cleaning up | cancelled=true op=sync\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestBindContextAlreadyCancelled(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	logger := lgr.NewLogger().BindContext(ctx, logr.CancelOptions{})
+	logger.Debug("after")
+
+	// contexts that are never done are ignored.
+	lgr.NewLogger().BindContext(context.Background(), logr.CancelOptions{}).Debug("background")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, "debug | after | cancelled=true\ndebug | background | \n", buf.String())
+}
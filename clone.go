@@ -0,0 +1,129 @@
+package logr
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/wiggin77/merror"
+)
+
+// TargetCloner can optionally be implemented by a Target to support
+// `Logr.Clone`.
+type TargetCloner interface {
+	// CloneTarget creates and starts a new target with the same configuration
+	// as this one but its own queue and goroutine. Output is written to out,
+	// or to this target's output when out is nil.
+	CloneTarget(out io.Writer) (Target, error)
+}
+
+// CloneOption configures `Logr.Clone`.
+type CloneOption func(*cloneOptions)
+
+type cloneOptions struct {
+	writers map[Target]io.Writer
+	share   bool
+}
+
+// CloneWriter directs the clone of target to write to w instead of the
+// target's output.
+func CloneWriter(target Target, w io.Writer) CloneOption {
+	return func(opts *cloneOptions) {
+		if opts.writers == nil {
+			opts.writers = make(map[Target]io.Writer)
+		}
+		opts.writers[target] = w
+	}
+}
+
+// CloneShareWriters allows clones of targets without a `CloneWriter` option
+// to write to the same output as the original target. Output from both Logr
+// instances may then be interleaved.
+func CloneShareWriters() CloneOption {
+	return func(opts *cloneOptions) {
+		opts.share = true
+	}
+}
+
+// Clone creates a new Logr with the same settings as this one and a clone of
+// each target, with its own queue and goroutine, so the clone can be used and
+// shut down independently. Every target must implement TargetCloner, and
+// must either be given its own output via `CloneWriter` or be allowed to share
+// its output via `CloneShareWriters`.
+//
+// Filters and formatters are shared with the original targets, so they must be
+// safe for concurrent use; filters that keep state, such as SamplingFilter,
+// then apply across both. Subscriptions and metrics collectors are not cloned.
+func (logr *Logr) Clone(opts ...CloneOption) (*Logr, error) {
+	if logr.IsShutdown() {
+		return nil, ErrLoggerShutdown
+	}
+
+	var co cloneOptions
+	for _, opt := range opts {
+		opt(&co)
+	}
+
+	logr.tmux.RLock()
+	targets := make([]Target, len(logr.targets))
+	copy(targets, logr.targets)
+	logr.tmux.RUnlock()
+
+	clone := logr.cloneSettings()
+	clone.SetGlobalFields(logr.globalFields()...)
+	errs := merror.New()
+	for _, t := range targets {
+		tc, ok := t.(TargetCloner)
+		if !ok {
+			errs.Append(fmt.Errorf("target %v cannot be cloned", t))
+			continue
+		}
+		w, ok := co.writers[t]
+		if !ok && !co.share {
+			errs.Append(fmt.Errorf("target %v requires CloneWriter or CloneShareWriters", t))
+			continue
+		}
+		ct, err := tc.CloneTarget(w)
+		if err != nil {
+			errs.Append(fmt.Errorf("cannot clone target %v: %w", t, err))
+			continue
+		}
+		errs.Append(clone.AddTarget(ct))
+	}
+
+	if err := errs.ErrorOrNil(); err != nil {
+		_ = clone.Shutdown()
+		return nil, err
+	}
+	return clone, nil
+}
+
+// cloneSettings returns a new Logr with a copy of this Logr's exported settings.
+func (logr *Logr) cloneSettings() *Logr {
+	return &Logr{
+		MaxQueueSize:            logr.MaxQueueSize,
+		OnLoggerError:           logr.OnLoggerError,
+		OnQueueFull:             logr.OnQueueFull,
+		OnTargetQueueFull:       logr.OnTargetQueueFull,
+		OnExit:                  logr.OnExit,
+		OnPanic:                 logr.OnPanic,
+		DisableExit:             logr.DisableExit,
+		DisablePanic:            logr.DisablePanic,
+		FieldConflictPolicy:     logr.FieldConflictPolicy,
+		FallbackFormatter:       logr.FallbackFormatter,
+		FormatTimeout:           logr.FormatTimeout,
+		Clock:                   logr.Clock,
+		EnableGoroutineID:       logr.EnableGoroutineID,
+		GoroutineIDKey:          logr.GoroutineIDKey,
+		EnableSequence:          logr.EnableSequence,
+		EnqueueTimeout:          logr.EnqueueTimeout,
+		OnBlockedProducer:       logr.OnBlockedProducer,
+		OnLevelChange:           logr.OnLevelChange,
+		ShutdownTimeout:         logr.ShutdownTimeout,
+		FlushTimeout:            logr.FlushTimeout,
+		UseSyncMapLevelCache:    logr.UseSyncMapLevelCache,
+		MaxPooledBuffer:         logr.MaxPooledBuffer,
+		DisableBufferPool:       logr.DisableBufferPool,
+		MetricsUpdateFreqMillis: logr.MetricsUpdateFreqMillis,
+		LogLifecycleEvents:      logr.LogLifecycleEvents,
+	}
+}
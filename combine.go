@@ -0,0 +1,193 @@
+package logr
+
+import "time"
+
+// AndFilter returns a Filter that enables a level, or log record, only when
+// all of the filters enable it, e.g. Error and above AND has an `audit` field.
+//
+// A stack trace is required when any of the filters requires one for the
+// level, and the stack trace options, and any RecordStacktracer such as
+// StackOnceFilter, are those of the first such filter. A level is suppressed
+// when any filter suppresses it. With no filters, no levels are enabled.
+func AndFilter(filters ...Filter) Filter {
+	af := andFilter(append([]Filter(nil), filters...))
+	prepareSuppression(af)
+	return af
+}
+
+// OrFilter returns a Filter that enables a level, or log record, when any of
+// the filters enables it.
+//
+// A stack trace is required when any of the filters that enable the level
+// requires one, and the stack trace options, and any RecordStacktracer such as
+// StackOnceFilter, are those of the first such filter. A level is suppressed
+// only when every filter enabling it suppresses it. A log record is enabled
+// when any filter that enables its level also accepts the record, if that
+// filter is a RecordFilter.
+func OrFilter(filters ...Filter) Filter {
+	of := orFilter(append([]Filter(nil), filters...))
+	prepareSuppression(of)
+	return of
+}
+
+type andFilter []Filter
+
+func (af andFilter) IsEnabled(lvl Level) bool {
+	for _, f := range af {
+		if !f.IsEnabled(lvl) {
+			return false
+		}
+	}
+	return len(af) > 0
+}
+
+func (af andFilter) IsStacktraceEnabled(lvl Level) bool {
+	return stacktraceEnabled(af, lvl, false)
+}
+
+func (af andFilter) StacktraceOptions(lvl Level) StacktraceOptions {
+	return stacktraceOptions(af, lvl, false)
+}
+
+func (af andFilter) RecordStacktrace(rec *LogRec) (keep bool, key string, ref string) {
+	return recordStacktraceOf(af, rec, false)
+}
+
+func (af andFilter) Levels() []Level {
+	return filterLevels(af)
+}
+
+func (af andFilter) IsSuppressed(lvl Level, t time.Time) bool {
+	for _, f := range af {
+		if isSuppressed(f, lvl, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (af andFilter) IsRecordEnabled(rec *LogRec) bool {
+	for _, f := range af {
+		if rf, ok := f.(RecordFilter); ok && !rf.IsRecordEnabled(rec) {
+			return false
+		}
+	}
+	return true
+}
+
+type orFilter []Filter
+
+func (of orFilter) IsEnabled(lvl Level) bool {
+	for _, f := range of {
+		if f.IsEnabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+func (of orFilter) IsStacktraceEnabled(lvl Level) bool {
+	return stacktraceEnabled(of, lvl, true)
+}
+
+func (of orFilter) StacktraceOptions(lvl Level) StacktraceOptions {
+	return stacktraceOptions(of, lvl, true)
+}
+
+func (of orFilter) RecordStacktrace(rec *LogRec) (keep bool, key string, ref string) {
+	return recordStacktraceOf(of, rec, true)
+}
+
+func (of orFilter) Levels() []Level {
+	return filterLevels(of)
+}
+
+func (of orFilter) IsSuppressed(lvl Level, t time.Time) bool {
+	var enabled bool
+	for _, f := range of {
+		if !f.IsEnabled(lvl) {
+			continue
+		}
+		if !isSuppressed(f, lvl, t) {
+			return false
+		}
+		enabled = true
+	}
+	return enabled
+}
+
+func (of orFilter) IsRecordEnabled(rec *LogRec) bool {
+	lvl := rec.Level()
+	for _, f := range of {
+		if !f.IsEnabled(lvl) {
+			continue
+		}
+		if rf, ok := f.(RecordFilter); !ok || rf.IsRecordEnabled(rec) {
+			return true
+		}
+	}
+	return false
+}
+
+// stacktraceEnabled returns true if any of the filters requires a stack trace
+// for the level, considering only filters enabling the level if enabledOnly.
+func stacktraceEnabled(filters []Filter, lvl Level, enabledOnly bool) bool {
+	for _, f := range filters {
+		if enabledOnly && !f.IsEnabled(lvl) {
+			continue
+		}
+		if f.IsStacktraceEnabled(lvl) {
+			return true
+		}
+	}
+	return false
+}
+
+// stacktraceOptions returns the stack trace options of the first filter that
+// requires a stack trace for the level, per stacktraceEnabled.
+func stacktraceOptions(filters []Filter, lvl Level, enabledOnly bool) StacktraceOptions {
+	for _, f := range filters {
+		if enabledOnly && !f.IsEnabled(lvl) {
+			continue
+		}
+		if !f.IsStacktraceEnabled(lvl) {
+			continue
+		}
+		if so, ok := f.(StacktraceOptioner); ok {
+			return so.StacktraceOptions(lvl)
+		}
+		break
+	}
+	return StacktraceOptions{}
+}
+
+// recordStacktraceOf applies the RecordStacktracer of the first filter that
+// requires a stack trace for the log record's level, per stacktraceEnabled.
+// The stack trace is kept if that filter is not a RecordStacktracer.
+func recordStacktraceOf(filters []Filter, rec *LogRec, enabledOnly bool) (keep bool, key string, ref string) {
+	lvl := rec.Level()
+	for _, f := range filters {
+		if enabledOnly && !f.IsEnabled(lvl) {
+			continue
+		}
+		if !f.IsStacktraceEnabled(lvl) {
+			continue
+		}
+		if rs, ok := f.(RecordStacktracer); ok {
+			return rs.RecordStacktrace(rec)
+		}
+		break
+	}
+	return true, "", ""
+}
+
+// filterLevels returns the custom levels supported by any of the filters.
+func filterLevels(filters []Filter) []Level {
+	var levels []Level
+	for _, f := range filters {
+		if ll, ok := f.(LevelLister); ok {
+			levels = append(levels, ll.Levels()...)
+		}
+	}
+	return levels
+}
@@ -0,0 +1,80 @@
+package logr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAndOrFilter(t *testing.T) {
+	errorsOnly := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Error, Exact: true}
+	debugOnly := &logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic, Exact: true}
+	infoUp := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+
+	t.Run("and", func(t *testing.T) {
+		filter := logr.AndFilter(infoUp, errorsOnly)
+		assert.True(t, filter.IsEnabled(logr.Error))
+		assert.False(t, filter.IsEnabled(logr.Warn))
+		assert.False(t, filter.IsEnabled(logr.Debug))
+		assert.True(t, filter.IsStacktraceEnabled(logr.Error))
+		assert.False(t, logr.AndFilter().IsEnabled(logr.Error))
+	})
+
+	t.Run("or", func(t *testing.T) {
+		filter := logr.OrFilter(errorsOnly, debugOnly)
+		assert.True(t, filter.IsEnabled(logr.Error))
+		assert.True(t, filter.IsEnabled(logr.Debug))
+		assert.False(t, filter.IsEnabled(logr.Warn))
+		assert.True(t, filter.IsStacktraceEnabled(logr.Error))
+		assert.False(t, filter.IsStacktraceEnabled(logr.Debug))
+		assert.False(t, logr.OrFilter().IsEnabled(logr.Error))
+	})
+
+	t.Run("or with record filter", func(t *testing.T) {
+		lgr := &logr.Logr{}
+		buf := &test.Buffer{}
+		audit := logr.AndFilter(infoUp, &logr.FieldPresenceFilter{RequireKeys: []string{"audit"}})
+		filter := logr.OrFilter(audit, &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic})
+		formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+		err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+		require.NoError(t, err)
+
+		logger := lgr.NewLogger()
+		logger.WithField("audit", true).Info("login")
+		logger.Info("ping")
+		logger.Error("failed")
+
+		err = lgr.Shutdown()
+		require.NoError(t, err)
+
+		assert.Equal(t, "info | login | audit=true\nerror | failed | \n", buf.String())
+	})
+
+	t.Run("stack once", func(t *testing.T) {
+		lgr := &logr.Logr{}
+		buf := &test.Buffer{}
+		once := logr.NewStackOnceFilter(&logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Error})
+		filter := logr.OrFilter(logr.AndFilter(once, infoUp), debugOnly)
+		formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+		err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+		require.NoError(t, err)
+
+		logger := lgr.NewLogger()
+		for i := 0; i < 3; i++ {
+			logger.Error("db timeout")
+		}
+
+		err = lgr.Shutdown()
+		require.NoError(t, err)
+
+		output := buf.String()
+		assert.Equal(t, 1, strings.Count(output, "TestAndOrFilter"), "expected one stack for repeated error")
+		assert.Equal(t, 3, strings.Count(output, logr.DefStacktraceRefKey+"="), output)
+	})
+}
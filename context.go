@@ -0,0 +1,26 @@
+package logr
+
+import "context"
+
+// loggerContextKey is the context key for a Logger stored via `NewContext`.
+type loggerContextKey struct{}
+
+// NewContext returns a copy of ctx carrying the Logger. Deep call stacks can
+// then log with the Logger's fields via `FromContext` without the Logger
+// being passed explicitly.
+func NewContext(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the Logger stored in ctx via `NewContext`. If ctx
+// carries no Logger then a zero Logger is returned, which discards all
+// log records.
+func FromContext(ctx context.Context) Logger {
+	if ctx == nil {
+		return Logger{}
+	}
+	if logger, ok := ctx.Value(loggerContextKey{}).(Logger); ok {
+		return logger
+	}
+	return Logger{}
+}
@@ -0,0 +1,47 @@
+package logr_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextLogger(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger().WithField("request_id", "abc123")
+	ctx := logr.NewContext(context.Background(), logger)
+
+	deepCall(ctx)
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), "deep call | request_id=abc123")
+}
+
+func deepCall(ctx context.Context) {
+	logr.FromContext(ctx).Info("deep call")
+}
+
+func TestContextLoggerMissing(t *testing.T) {
+	logger := logr.FromContext(context.Background())
+	assert.Nil(t, logger.Logr())
+
+	// zero Logger discards everything without panic.
+	assert.NotPanics(t, func() {
+		logger.WithField("name", "wiggin").Info("discarded")
+		logger.Errorf("discarded %d", 1)
+	})
+}
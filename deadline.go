@@ -0,0 +1,45 @@
+package logr
+
+import "time"
+
+// WithDeadline creates a new `Logger` whose log records favor latency over
+// completeness: a record that cannot be queued by the Logr, or by a target
+// whose queue is full, within d of the logging call is dropped rather than
+// blocking, while targets that keep up still output it. This bounds how long
+// a logging call, and other targets, can be held up by a slow target, unlike
+// `Logr.EnqueueTimeout` which applies to all log records. Dropped records are
+// counted as dropped in metrics and sent to `Logr.DroppedRecords`; no error is
+// reported. Zero or negative d removes the deadline.
+//
+// Synchronous targets write in the logging goroutine and are not affected.
+func (logger Logger) WithDeadline(d time.Duration) Logger {
+	l := logger
+	if d < 0 {
+		d = 0
+	}
+	l.deadline = d
+	return l
+}
+
+// Deadline returns the time by which this log record must be queued or
+// written, and false if the record has no deadline. See `Logger.WithDeadline`.
+func (rec *LogRec) Deadline() (time.Time, bool) {
+	// no locking needed as this field is not mutated after creation.
+	return rec.deadline, !rec.deadline.IsZero()
+}
+
+// enqueueTimeout returns the lesser of timeout and the time remaining until
+// this log record's deadline, and true if limited by the deadline.
+func (rec *LogRec) enqueueTimeout(timeout time.Duration) (time.Duration, bool) {
+	if rec.deadline.IsZero() {
+		return timeout, false
+	}
+	remaining := time.Until(rec.deadline)
+	if remaining < timeout {
+		if remaining < 0 {
+			remaining = 0
+		}
+		return remaining, true
+	}
+	return timeout, false
+}
@@ -0,0 +1,85 @@
+package logr
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+)
+
+// DefErrorKey is the field key used by `Logger.WithError`.
+const DefErrorKey = "error"
+
+// WithError creates a new `Logger` with any existing fields plus
+// the error, added under the key DefErrorKey.
+func (logger Logger) WithError(err error) Logger {
+	return logger.WithField(DefErrorKey, err)
+}
+
+// ErrorStackFrames returns the stack frames recorded by an error when the error,
+// or any error it wraps, has a `StackTrace()` method returning a slice of
+// program counters, such as errors created via `github.com/pkg/errors`.
+// The stack recorded nearest to where the error originated is preferred.
+// Returns nil if no stack trace is found.
+func ErrorStackFrames(err error) []runtime.Frame {
+	var pcs []uintptr
+	for ; err != nil; err = errors.Unwrap(err) {
+		if p := errorStackPCs(err); len(p) > 0 {
+			pcs = p
+		}
+	}
+	if len(pcs) == 0 {
+		return nil
+	}
+	if len(pcs) > DefaultMaxStackFrames {
+		pcs = pcs[:DefaultMaxStackFrames]
+	}
+
+	frames := make([]runtime.Frame, 0, len(pcs))
+	iter := runtime.CallersFrames(pcs)
+	for {
+		frame, more := iter.Next()
+		frames = append(frames, frame)
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// errorStackPCs calls the error's `StackTrace()` method, if any, via reflection
+// so no dependency on a specific errors package is needed. The method must
+// return a slice whose elements are program counters (uintptr kind).
+func errorStackPCs(err error) []uintptr {
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() {
+		return nil
+	}
+	mt := m.Type()
+	if mt.NumIn() != 0 || mt.NumOut() != 1 {
+		return nil
+	}
+	out := mt.Out(0)
+	if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+
+	st := m.Call(nil)[0]
+	pcs := make([]uintptr, st.Len())
+	for i := range pcs {
+		pcs[i] = uintptr(st.Index(i).Uint())
+	}
+	return pcs
+}
+
+// ErrorStackFrames returns the stack frames recorded by the first error
+// field, in the order of `EachField`, that carries a stack trace. See
+// `ErrorStackFrames`. Returns nil if no error field has a stack trace.
+func (rec *LogRec) ErrorStackFrames() []runtime.Frame {
+	var frames []runtime.Frame
+	rec.EachField(func(key string, val interface{}) {
+		if err, ok := val.(error); ok && len(frames) == 0 {
+			frames = ErrorStackFrames(err)
+		}
+	})
+	return frames
+}
@@ -0,0 +1,378 @@
+package logr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/francoispqt/gojay"
+)
+
+// ObjectMarshaler can be implemented by field values to be encoded
+// structurally as a nested object, rather than stringified.
+type ObjectMarshaler = gojay.MarshalerJSONObject
+
+// ArrayMarshaler can be implemented by field values to be encoded
+// structurally as an array, rather than stringified.
+type ArrayMarshaler = gojay.MarshalerJSONArray
+
+// FieldType indicates how a Field's value is stored and encoded.
+type FieldType uint8
+
+const (
+	// UnknownType is the zero FieldType; the value is in `Field.Interface`
+	// and is encoded based on its Go type.
+	UnknownType FieldType = iota
+	// ArrayMarshalerType indicates `Field.Interface` is an ArrayMarshaler.
+	ArrayMarshalerType
+	// ObjectMarshalerType indicates `Field.Interface` is an ObjectMarshaler.
+	ObjectMarshalerType
+	// MapType indicates `Field.Interface` is a map[string]interface{}.
+	MapType
+	// StringType indicates the value is in `Field.String`.
+	StringType
+	// Int64Type indicates the value is in `Field.Integer`.
+	Int64Type
+	// StringerType indicates `Field.Interface` is a fmt.Stringer, whose
+	// `String` method is called when the field is output.
+	StringerType
+	// ReflectType indicates `Field.Interface` is an arbitrary value that is
+	// encoded via reflection, using `encoding/json`, when the field is output.
+	ReflectType
+	// Complex128Type indicates `Field.Interface` is a complex128.
+	Complex128Type
+	// Complex64Type indicates `Field.Interface` is a complex64.
+	Complex64Type
+	// IntBaseType indicates the value is in `Field.Integer` and is output as
+	// a string in the base held, as an int, in `Field.Interface`.
+	IntBaseType
+	// DurationType indicates the value is a time.Duration in `Field.Integer`.
+	DurationType
+)
+
+// RedactedValue is output in place of the value of sensitive fields.
+// See `Sensitive`.
+const RedactedValue = "***"
+
+// Field is a typed name/value pair that can be added to a Logger via `With`.
+// Use the constructors such as `Array` and `Object` to create them.
+type Field struct {
+	Key       string
+	Type      FieldType
+	Integer   int64
+	String    string
+	Interface interface{}
+
+	// Sensitive fields are output as RedactedValue. See `Sensitive`.
+	Sensitive bool
+}
+
+// Sensitive marks a field as sensitive, so formatters output RedactedValue in
+// place of its value regardless of its key, e.g.
+//
+//	logger.With(logr.Sensitive(logr.String("ssn", ssn))).Info("verified")
+func Sensitive(f Field) Field {
+	f.Sensitive = true
+	return f
+}
+
+// Any creates a Field whose value is encoded based on its Go type.
+func Any(key string, val interface{}) Field {
+	return Field{Key: key, Type: UnknownType, Interface: val}
+}
+
+// String creates a Field with a string value.
+func String(key string, val string) Field {
+	return Field{Key: key, Type: StringType, String: val}
+}
+
+// Int creates a Field with an int value.
+func Int(key string, val int) Field {
+	return Int64(key, int64(val))
+}
+
+// Int64 creates a Field with an int64 value.
+func Int64(key string, val int64) Field {
+	return Field{Key: key, Type: Int64Type, Integer: val}
+}
+
+// Duration creates a Field with a time.Duration value. Text formatters such as
+// `format.Plain` output it in human-readable form, e.g. `1.5s`; `format.JSON`
+// outputs it per `JSON.DurationFormat`.
+func Duration(key string, d time.Duration) Field {
+	return Field{Key: key, Type: DurationType, Integer: int64(d)}
+}
+
+// IntBase creates a Field with an int64 value that is output in the specified
+// base, e.g. hexadecimal for flags and bit masks. Bases 16, 8 and 2 are output
+// with a `0x`, `0o` or `0b` prefix respectively. Since JSON numbers are always
+// decimal, structured formatters output the value as a string. Base 10, or a
+// base outside the range 2 to 36, creates an `Int64` field.
+func IntBase(key string, val int64, base int) Field {
+	if base == 10 || base < 2 || base > 36 {
+		return Int64(key, val)
+	}
+	return Field{Key: key, Type: IntBaseType, Integer: val, Interface: base}
+}
+
+// formatIntBase returns v in the base, with a prefix for bases 16, 8 and 2.
+func formatIntBase(v int64, base int) string {
+	var prefix string
+	switch base {
+	case 16:
+		prefix = "0x"
+	case 8:
+		prefix = "0o"
+	case 2:
+		prefix = "0b"
+	}
+	if v < 0 {
+		return "-" + prefix + strconv.FormatUint(uint64(-v), base)
+	}
+	return prefix + strconv.FormatInt(v, base)
+}
+
+// Stringer creates a Field whose value is the result of calling `String` on
+// val when the field is output. A panic within `String` is recovered and a
+// placeholder output instead.
+func Stringer(key string, val fmt.Stringer) Field {
+	return Field{Key: key, Type: StringerType, Interface: val}
+}
+
+// Reflect creates a Field whose value, such as a struct without a marshaler,
+// is encoded via reflection using `encoding/json` when the field is output.
+// Nested structs, slices and maps are encoded structurally and `json` struct
+// tags are honored. Values that cannot be encoded as JSON are output using
+// `fmt` instead.
+func Reflect(key string, val interface{}) Field {
+	return Field{Key: key, Type: ReflectType, Interface: val}
+}
+
+// Complex128 creates a Field with a complex128 value. Structured formatters
+// such as `format.JSON` encode it as an object with `real` and `imag` members.
+func Complex128(key string, val complex128) Field {
+	return Field{Key: key, Type: Complex128Type, Interface: val}
+}
+
+// Complex64 creates a Field with a complex64 value. See `Complex128`.
+func Complex64(key string, val complex64) Field {
+	return Field{Key: key, Type: Complex64Type, Interface: val}
+}
+
+// Object creates a Field whose value is encoded as a nested object.
+func Object(key string, val ObjectMarshaler) Field {
+	return Field{Key: key, Type: ObjectMarshalerType, Interface: val}
+}
+
+// Array creates a Field whose values are encoded as an array of
+// nested objects.
+func Array(key string, vals ...ObjectMarshaler) Field {
+	return Field{Key: key, Type: ArrayMarshalerType, Interface: objects(vals)}
+}
+
+// Map creates a Field whose value is encoded as a nested object with keys in
+// sorted order, so output is deterministic.
+func Map(key string, m map[string]interface{}) Field {
+	return Field{Key: key, Type: MapType, Interface: m}
+}
+
+// objects is an ArrayMarshaler for a slice of ObjectMarshaler.
+type objects []ObjectMarshaler
+
+// MarshalJSONArray encodes each object as an array element.
+func (o objects) MarshalJSONArray(enc *gojay.Encoder) {
+	for _, obj := range o {
+		enc.AddObject(obj)
+	}
+}
+
+// IsNil returns true if there are no objects.
+func (o objects) IsNil() bool {
+	return len(o) == 0
+}
+
+// Value returns the Field's value as an interface{}, or RedactedValue if the
+// field is sensitive.
+func (f Field) Value() interface{} {
+	if f.Sensitive {
+		return RedactedValue
+	}
+	switch f.Type {
+	case StringType:
+		return f.String
+	case Int64Type:
+		return f.Integer
+	case IntBaseType:
+		return formatIntBase(f.Integer, f.Interface.(int))
+	case DurationType:
+		return time.Duration(f.Integer)
+	case StringerType:
+		return safeString(f.Interface.(fmt.Stringer))
+	}
+	return f.Interface
+}
+
+// safeString calls `String` on s, recovering from any panic and returning
+// a placeholder in the style of the fmt package.
+func safeString(s fmt.Stringer) (str string) {
+	defer func() {
+		if r := recover(); r != nil {
+			str = fmt.Sprintf("%%!v(PANIC=String method: %v)", r)
+		}
+	}()
+	return s.String()
+}
+
+// With creates a new `Logger` with any existing fields plus
+// the typed fields. Typed fields are kept in the order they were added,
+// available via `LogRec.TypedFields`, and are merged with fields added via
+// `WithFields` in `LogRec.Fields`. Fields added via `WithFields` after typed
+// fields are added as typed fields too, so the order is kept.
+func (logger Logger) With(fields ...Field) Logger {
+	if len(fields) == 0 {
+		return logger
+	}
+
+	policy := logger.conflictPolicy()
+	l := logger
+	l.typed = make([]Field, len(logger.typed), len(logger.typed)+len(fields))
+	copy(l.typed, logger.typed)
+	for _, f := range fields {
+		if policy != ConflictOverwrite && logger.hasField(f.Key) {
+			switch policy {
+			case ConflictKeepParent:
+				continue
+			case ConflictError:
+				logger.logr.ReportError(fmt.Errorf("field %q conflicts with existing field", f.Key))
+			case ConflictSuffix:
+				f.Key = l.uniqueKey(f.Key)
+			}
+		}
+		if i := indexField(l.typed, f.Key); i >= 0 {
+			l.typed[i] = f
+			continue
+		}
+		l.typed = append(l.typed, f)
+	}
+	return l
+}
+
+// sortedFields returns the fields as typed fields sorted by key.
+func sortedFields(fields Fields) []Field {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	flds := make([]Field, 0, len(keys))
+	for _, k := range keys {
+		f, ok := fields[k].(Field)
+		if !ok {
+			f = Any(k, fields[k])
+		}
+		f.Key = k
+		flds = append(flds, f)
+	}
+	return flds
+}
+
+// fieldValue returns the value output for the typed field: the value of a
+// Field created by `Any`, so it is output the same as a value added via
+// `Logger.WithFields`, otherwise the Field itself.
+func fieldValue(f Field) interface{} {
+	if f.Type == UnknownType && !f.Sensitive {
+		return f.Interface
+	}
+	return f
+}
+
+// indexField returns the index of the field with the key, or -1.
+func indexField(fields []Field, key string) int {
+	for i, f := range fields {
+		if f.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeTypedField writes a Field in key=value format, rendering structured
+// values as JSON.
+func writeTypedField(w io.Writer, key string, f Field, sep string, ff *FieldsFormat) {
+	if f.Sensitive {
+		writeField(w, key, RedactedValue, sep, ff)
+		return
+	}
+	var val interface{}
+	switch f.Type {
+	case ArrayMarshalerType:
+		b, err := gojay.MarshalJSONArray(f.Interface.(ArrayMarshaler))
+		if err != nil {
+			val = err
+			break
+		}
+		val = string(b)
+	case ObjectMarshalerType:
+		b, err := gojay.MarshalJSONObject(f.Interface.(ObjectMarshaler))
+		if err != nil {
+			val = err
+			break
+		}
+		val = string(b)
+	case ReflectType:
+		b, err := reflectJSON(f.Interface)
+		if err != nil {
+			writeField(w, key, fmt.Sprintf("%+v", f.Interface), sep, ff)
+			return
+		}
+		val = string(b)
+	case MapType:
+		fmt.Fprintf(w, "%s%s%s{", sep, ff.formatKey(key), ff.KeyValueSep)
+		nested := *ff
+		nested.Separator = " "
+		WriteFieldsFormat(w, f.Interface.(map[string]interface{}), nested)
+		fmt.Fprint(w, "}")
+		return
+	default:
+		writeField(w, key, f.Value(), sep, ff)
+		return
+	}
+	fmt.Fprintf(w, "%s%s%s%v", sep, ff.formatKey(key), ff.KeyValueSep, val)
+}
+
+// reflectJSON encodes val via reflection using `encoding/json`, without
+// escaping HTML characters.
+func reflectJSON(val interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// AlwaysValue wraps a field value that must be output even when formatters
+// are configured to omit empty values. See `Always`.
+type AlwaysValue struct {
+	Val interface{}
+}
+
+// Always wraps a field value so that it is output even when it is empty and the
+// formatter omits empty values, e.g. via `format.JSON.OmitEmpty`. Use this for
+// values where an explicit zero is meaningful:
+//
+//	logger.WithField("retries", logr.Always(0))
+func Always(val interface{}) AlwaysValue {
+	return AlwaysValue{Val: val}
+}
+
+// String returns the wrapped value as a string.
+func (a AlwaysValue) String() string {
+	return fmt.Sprint(a.Val)
+}
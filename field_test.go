@@ -0,0 +1,508 @@
+package logr_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/francoispqt/gojay"
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+func (u user) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.AddStringKey("name", u.Name)
+	enc.AddIntKey("age", u.Age)
+}
+
+func (u user) IsNil() bool {
+	return false
+}
+
+func ExampleArray() {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true}
+	_ = lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+
+	users := []logr.ObjectMarshaler{user{Name: "Bob", Age: 42}, user{Name: "Alice", Age: 39}}
+	lgr.NewLogger().With(logr.Array("users", users...)).Info("users logged in")
+
+	err := lgr.Shutdown()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	fmt.Print(buf.String())
+	// Output: {"level":"info","msg":"users logged in","users":[{"name":"Bob","age":42},{"name":"Alice","age":39}]}
+}
+
+func TestFieldPlain(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger().With(
+		logr.Object("owner", user{Name: "Bob", Age: 42}),
+		logr.Array("users", user{Name: "Alice", Age: 39}),
+	)
+	logger.Info("structured")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	want := `info | structured | owner={"name":"Bob","age":42} users=[{"name":"Alice","age":39}]` + "\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestFieldMap(t *testing.T) {
+	m := map[string]interface{}{
+		"zeta":  1,
+		"alpha": "a b",
+		"mid":   map[string]interface{}{"y": true, "x": 2.5},
+	}
+
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want:      `{"level":"info","msg":"map","attrs":{"alpha":"a b","mid":{"x":2.5,"y":true},"zeta":1}}` + "\n",
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want:      `info | map | attrs={alpha="a b" mid=map[x:2.5 y:true] zeta=1}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			require.NoError(t, err)
+
+			lgr.NewLogger().With(logr.Map("attrs", m)).Info("map")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestFieldIntBase(t *testing.T) {
+	fields := []logr.Field{
+		logr.IntBase("mask", 0x1f, 16),
+		logr.IntBase("flags", 5, 2),
+		logr.IntBase("neg", -255, 16),
+		logr.IntBase("dec", 42, 10),
+	}
+
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want:      `{"level":"info","msg":"bits","dec":42,"flags":"0b101","mask":"0x1f","neg":"-0xff"}` + "\n",
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want:      `info | bits | dec=42 flags=0b101 mask=0x1f neg="-0xff"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			require.NoError(t, err)
+
+			lgr.NewLogger().With(fields...).Info("bits")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestFieldDuration(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want:      `info | timed | elapsed=1.5s wait=300ms` + "\n",
+		},
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want:      `{"level":"info","msg":"timed","elapsed":"1.5s","wait":"300ms"}` + "\n",
+		},
+		{
+			name:      "json nanos",
+			formatter: &format.JSON{DisableTimestamp: true, DurationFormat: format.DurationNanos},
+			want:      `{"level":"info","msg":"timed","elapsed":1500000000,"wait":300000000}` + "\n",
+		},
+		{
+			name:      "json millis",
+			formatter: &format.JSON{DisableTimestamp: true, DurationFormat: format.DurationMillis},
+			want:      `{"level":"info","msg":"timed","elapsed":1500,"wait":300}` + "\n",
+		},
+		{
+			name:      "json seconds",
+			formatter: &format.JSON{DisableTimestamp: true, DurationFormat: format.DurationSeconds},
+			want:      `{"level":"info","msg":"timed","elapsed":1.5,"wait":0.3}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			require.NoError(t, err)
+
+			// untyped durations are output the same way.
+			lgr.NewLogger().With(logr.Duration("elapsed", 1500*time.Millisecond)).
+				WithField("wait", 300*time.Millisecond).Info("timed")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+type version struct {
+	major, minor int
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("v%d.%d", v.major, v.minor)
+}
+
+type panicky struct{}
+
+func (p panicky) String() string {
+	panic("boom")
+}
+
+func TestFieldStringer(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want:      `{"level":"info","msg":"stringer","bad":"%!v(PANIC=String method: boom)","untyped":"v3.1","version":"v1.2"}` + "\n",
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want:      `info | stringer | bad="%!v(PANIC=String method: boom)" untyped=v3.1 version="v1.2"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			require.NoError(t, err)
+
+			lgr.NewLogger().
+				With(logr.Stringer("version", version{1, 2}), logr.Stringer("bad", panicky{})).
+				WithField("untyped", version{3, 1}).
+				Info("stringer")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+type address struct {
+	City string   `json:"city"`
+	Tags []string `json:"tags"`
+}
+
+type account struct {
+	ID      int               `json:"id"`
+	Owner   user              `json:"owner"`
+	Address *address          `json:"address"`
+	Limits  map[string]int    `json:"limits"`
+	Notes   map[string]string `json:"notes,omitempty"`
+}
+
+func TestFieldReflect(t *testing.T) {
+	acct := account{
+		ID:      7,
+		Owner:   user{Name: "Bob", Age: 42},
+		Address: &address{City: "A&B", Tags: []string{"home", "work"}},
+		Limits:  map[string]int{"daily": 10, "monthly": 100},
+	}
+
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want: `{"level":"info","msg":"reflect","account":{"id":7,"owner":{"Name":"Bob","Age":42},` +
+				`"address":{"city":"A&B","tags":["home","work"]},"limits":{"daily":10,"monthly":100}},"bad":"(1+0i)"}` + "\n",
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want: `info | reflect | account={"id":7,"owner":{"Name":"Bob","Age":42},` +
+				`"address":{"city":"A&B","tags":["home","work"]},"limits":{"daily":10,"monthly":100}} bad="(1+0i)"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			require.NoError(t, err)
+
+			// complex numbers cannot be encoded as JSON.
+			lgr.NewLogger().With(logr.Reflect("account", acct), logr.Reflect("bad", complex(1, 0))).Info("reflect")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestFieldSensitive(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want:      `{"level":"info","msg":"verified","id":"***","profile":"***","ssn":"***","user":"bob"}` + "\n",
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want:      `info | verified | id="***" profile="***" ssn="***" user=bob` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			require.NoError(t, err)
+
+			lgr.NewLogger().With(
+				logr.String("user", "bob"),
+				logr.Sensitive(logr.String("ssn", "123-45-6789")),
+				logr.Sensitive(logr.Int("id", 42)),
+				logr.Sensitive(logr.Object("profile", user{Name: "Bob", Age: 42})),
+			).Info("verified")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+// typedOrderFormatter outputs the keys of a log record's typed fields in
+// order, followed by all of its fields.
+type typedOrderFormatter struct{}
+
+func (f typedOrderFormatter) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	if buf == nil {
+		buf = &bytes.Buffer{}
+	}
+	for _, fld := range rec.TypedFields() {
+		buf.WriteString(fld.Key)
+		buf.WriteString(" ")
+	}
+	buf.WriteString("| ")
+	logr.WriteFields(buf, rec.Fields(), " ")
+	buf.WriteString("\n")
+	return buf, nil
+}
+
+func TestFieldTypedOrder(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, typedOrderFormatter{}, buf, 1000))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger().WithFields(logr.Fields{"app": "test", "user": "alice"})
+	logger = logger.With(logr.String("zone", "east"), logr.Int("b", 2)).With(logr.String("user", "bob"), logr.Int("a", 1))
+	logger.Info("ordered")
+
+	// WithFields after With adds typed fields, so later fields still win.
+	logger.WithFields(logr.Fields{"zone": "west"}).With(logr.Int("c", 3)).Info("merged")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	want := "zone b user a | a=1 app=test b=2 user=bob zone=east\n" +
+		"zone b user a c | a=1 app=test b=2 c=3 user=bob zone=west\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestFieldOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json sorted",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want:      `{"level":"info","msg":"ordered","app":"test","b":2,"host":"h1","id":7,"user":"bob","zone":"east"}` + "\n",
+		},
+		{
+			name:      "json preserved",
+			formatter: &format.JSON{DisableTimestamp: true, PreserveFieldOrder: true},
+			want:      `{"level":"info","msg":"ordered","app":"test","zone":"east","b":2,"host":"h1","user":"bob","id":7}` + "\n",
+		},
+		{
+			name:      "plain sorted",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want:      "info | ordered | app=test b=2 host=h1 id=7 user=bob zone=east\n",
+		},
+		{
+			name:      "plain preserved",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | ", PreserveFieldOrder: true},
+			want:      "info | ordered | app=test zone=east b=2 host=h1 user=bob id=7\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			require.NoError(t, err)
+
+			lgr.NewLogger().
+				WithFields(logr.Fields{"app": "test"}).
+				With(logr.String("zone", "east"), logr.Int("b", 2)).
+				WithFields(logr.Fields{"user": "bob", "host": "h1"}).
+				With(logr.Int("id", 7)).
+				Info("ordered")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestGlobalFields(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	require.NoError(t, err)
+
+	lgr.SetGlobalFields(logr.String("app", "billing"), logr.String("region", "east"))
+
+	logger := lgr.NewLogger()
+	logger.Info("plain")
+	logger.WithField("region", "west").Info("override")
+	logger.With(logr.Int("id", 7)).Info("typed")
+
+	lgr.SetGlobalFields()
+	logger.Info("removed")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	want := "info | plain | app=billing region=east\n" +
+		"info | override | app=billing region=west\n" +
+		"info | typed | app=billing id=7 region=east\n" +
+		"info | removed | \n"
+	assert.Equal(t, want, buf.String())
+
+	host := logr.HostFields()
+	require.Len(t, host, 3)
+	assert.Equal(t, "host", host[0].Key)
+	assert.Equal(t, int64(os.Getpid()), host[1].Value())
+	assert.Equal(t, "app", host[2].Key)
+}
+
+func TestBuildFields(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	require.NoError(t, err)
+
+	lgr.SetGlobalFields(logr.BuildFields("v1.2.3", "abc123", "")...)
+	lgr.NewLogger().Info("started")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, "info | started | commit=abc123 version=\"v1.2.3\"\n", buf.String())
+
+	// test binaries carry build info, but no version or revision.
+	for _, f := range logr.BuildInfoFields() {
+		assert.Contains(t, []string{"version", "commit", "build_time"}, f.Key)
+	}
+}
@@ -0,0 +1,39 @@
+package logr
+
+// FieldPresenceFilter is a Filter that enables log records based on the
+// presence of fields, e.g. routing only records with an `audit` field to an
+// audit target. It enables all levels and requires no stack traces; combine
+// it with a level filter using `AndFilter`.
+type FieldPresenceFilter struct {
+	// RequireKeys are field keys that must all be present.
+	RequireKeys []string
+
+	// ForbidKeys are field keys that must all be absent.
+	ForbidKeys []string
+}
+
+// IsEnabled returns true for all levels.
+func (ff *FieldPresenceFilter) IsEnabled(lvl Level) bool {
+	return true
+}
+
+// IsStacktraceEnabled returns false for all levels.
+func (ff *FieldPresenceFilter) IsStacktraceEnabled(lvl Level) bool {
+	return false
+}
+
+// IsRecordEnabled returns true if the log record has all of RequireKeys and
+// none of ForbidKeys, including any global fields.
+func (ff *FieldPresenceFilter) IsRecordEnabled(rec *LogRec) bool {
+	for _, key := range ff.RequireKeys {
+		if _, ok := rec.LookupField(key); !ok {
+			return false
+		}
+	}
+	for _, key := range ff.ForbidKeys {
+		if _, ok := rec.LookupField(key); ok {
+			return false
+		}
+	}
+	return true
+}
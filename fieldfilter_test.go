@@ -0,0 +1,58 @@
+package logr_test
+
+import (
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldPresenceFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *logr.FieldPresenceFilter
+		want   string
+	}{
+		{
+			name:   "required present",
+			filter: &logr.FieldPresenceFilter{RequireKeys: []string{"audit"}},
+			want:   "info | login | audit=true user=bob\nerror | denied | audit=true\n",
+		},
+		{
+			name:   "required absent",
+			filter: &logr.FieldPresenceFilter{RequireKeys: []string{"audit", "user"}},
+			want:   "info | login | audit=true user=bob\n",
+		},
+		{
+			name:   "forbidden present",
+			filter: &logr.FieldPresenceFilter{ForbidKeys: []string{"audit"}},
+			want:   "info | ping | \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := logr.AndFilter(&logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}, tt.filter)
+			formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+			require.NoError(t, err)
+
+			logger := lgr.NewLogger()
+			logger.WithFields(logr.Fields{"audit": true, "user": "bob"}).Info("login")
+			logger.WithField("audit", true).Error("denied")
+			logger.WithField("audit", true).Debug("filtered by level")
+			logger.Info("ping")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
@@ -24,3 +24,34 @@ type Filter interface {
 	IsEnabled(Level) bool
 	IsStacktraceEnabled(Level) bool
 }
+
+// StacktraceOptions determines how a stack trace is captured.
+type StacktraceOptions struct {
+	// MaxDepth is the maximum number of stack frames captured.
+	// Zero means DefaultMaxStackFrames.
+	MaxDepth int
+
+	// AllGoroutines captures the stacks of all goroutines, similar to
+	// the dump output by an unrecovered panic.
+	AllGoroutines bool
+}
+
+// StacktraceOptioner is implemented by Filters and Targets that customize
+// how stack traces are captured for a Level. It is only consulted for
+// levels that require a stack trace.
+type StacktraceOptioner interface {
+	StacktraceOptions(Level) StacktraceOptions
+}
+
+// RecordFilter is implemented by Filters and Targets that decide whether to
+// output individual log records beyond their level, e.g. for sampling.
+// It is only consulted for log records whose level is enabled.
+type RecordFilter interface {
+	IsRecordEnabled(rec *LogRec) bool
+}
+
+// LevelLister is implemented by Filters and Targets that can enumerate the
+// custom levels they support, beyond the standard levels.
+type LevelLister interface {
+	Levels() []Level
+}
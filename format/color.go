@@ -0,0 +1,80 @@
+package format
+
+import (
+	"bytes"
+
+	"github.com/mattermost/logr"
+)
+
+// Color is an ANSI SGR parameter string used to color output for terminals,
+// e.g. "31" for red or "1;33" for bold yellow. The named colors below cover
+// common cases; any valid SGR parameters can be used.
+type Color string
+
+// Named colors.
+const (
+	NoColor       Color = ""
+	Black         Color = "30"
+	Red           Color = "31"
+	Green         Color = "32"
+	Yellow        Color = "33"
+	Blue          Color = "34"
+	Magenta       Color = "35"
+	Cyan          Color = "36"
+	White         Color = "37"
+	Gray          Color = "90"
+	BrightRed     Color = "91"
+	BrightGreen   Color = "92"
+	BrightYellow  Color = "93"
+	BrightBlue    Color = "94"
+	BrightMagenta Color = "95"
+	BrightCyan    Color = "96"
+	BoldRed       Color = "1;31"
+)
+
+// DefaultLevelColors returns the palette used for the standard levels when
+// `Plain.LevelColors` is nil. A new map is returned each call, so it can be
+// modified and assigned to `Plain.LevelColors`, e.g. to add custom levels.
+func DefaultLevelColors() map[logr.Level]Color {
+	return map[logr.Level]Color{
+		logr.Panic: BoldRed,
+		logr.Fatal: BoldRed,
+		logr.Error: Red,
+		logr.Warn:  Yellow,
+		logr.Info:  Green,
+		logr.Debug: Cyan,
+		logr.Trace: Gray,
+	}
+}
+
+var defaultLevelColors = DefaultLevelColors()
+
+// levelColor returns the color for the level, matching by level ID if the
+// level is not found as is. Levels without an entry get NoColor.
+func levelColor(colors map[logr.Level]Color, lvl logr.Level) Color {
+	if colors == nil {
+		colors = defaultLevelColors
+	}
+	if c, ok := colors[lvl]; ok {
+		return c
+	}
+	for l, c := range colors {
+		if l.ID == lvl.ID {
+			return c
+		}
+	}
+	return NoColor
+}
+
+// writeColored writes s to buf wrapped in the ANSI escapes for color c.
+func writeColored(buf *bytes.Buffer, s string, c Color) {
+	if c == NoColor {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteString("\x1b[")
+	buf.WriteString(string(c))
+	buf.WriteByte('m')
+	buf.WriteString(s)
+	buf.WriteString("\x1b[0m")
+}
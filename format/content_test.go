@@ -0,0 +1,87 @@
+package format_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+)
+
+type opaqueFormatter struct{}
+
+func (f opaqueFormatter) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	return buf, nil
+}
+
+func TestContentTyped(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		mime      string
+		ext       string
+	}{
+		{name: "json", formatter: &format.JSON{}, mime: "application/json", ext: ".json"},
+		{name: "plain", formatter: &format.Plain{}, mime: "text/plain", ext: ".log"},
+		{name: "template", formatter: &format.Template{}, mime: "text/plain", ext: ".log"},
+		{name: "default", formatter: &logr.DefaultFormatter{}, mime: "text/plain", ext: ".log"},
+		{name: "opaque", formatter: opaqueFormatter{}, mime: logr.DefContentType, ext: logr.DefFileExt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logr.ContentTypeOf(tt.formatter); got != tt.mime {
+				t.Errorf("expected content type %s, got %s", tt.mime, got)
+			}
+			if got := logr.FileExtOf(tt.formatter); got != tt.ext {
+				t.Errorf("expected file extension %s, got %s", tt.ext, got)
+			}
+		})
+	}
+}
+
+func TestFormatAppend(t *testing.T) {
+	formatters := map[string]interface {
+		logr.Formatter
+		FormatAppend(rec *logr.LogRec, stacktrace bool, dst []byte) ([]byte, error)
+	}{
+		"plain": &format.Plain{DisableTimestamp: true},
+		"json":  &format.JSON{DisableTimestamp: true},
+	}
+
+	for name, formatter := range formatters {
+		t.Run(name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+
+			var chain []byte
+			var formatted [][]byte
+			fn := func(b []byte, rec *logr.LogRec) {
+				formatted = append(formatted, append([]byte(nil), b...))
+				var err error
+				chain, err = formatter.FormatAppend(rec, false, chain)
+				if err != nil {
+					t.Error(err)
+				}
+			}
+			err := lgr.AddTarget(target.NewFuncTarget(filter, formatter, fn, 100))
+			if err != nil {
+				t.Error(err)
+			}
+
+			logger := lgr.NewLogger().WithField("n", 1)
+			logger.Info("first")
+			logger.Warn("second")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Error(err)
+			}
+
+			if want := bytes.Join(formatted, nil); !bytes.Equal(chain, want) {
+				t.Errorf("expected: %q;  got: %q", want, chain)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/francoispqt/gojay"
+)
+
+// Encoder is the backend used by the JSON formatter to encode log records.
+// GojayEncoder is used by default; StdEncoder uses `encoding/json` for values,
+// and custom Encoders can be used to apply house conventions such as number
+// formatting.
+type Encoder interface {
+	// EncodeObject appends obj to buf as a JSON object.
+	EncodeObject(buf *bytes.Buffer, obj JSONObject) error
+}
+
+// JSONObject is implemented by values that encode themselves as a JSON object
+// via a JSONWriter.
+type JSONObject interface {
+	WriteJSONObject(w JSONWriter)
+}
+
+// JSONArray is implemented by values that encode themselves as a JSON array
+// via a JSONWriter.
+type JSONArray interface {
+	WriteJSONArray(w JSONWriter)
+}
+
+// JSONWriter writes the keys and values of a JSON object, or the elements of
+// a JSON array, for an Encoder. Methods with a key parameter are used within
+// objects; the others within arrays. Implementations insert separators.
+type JSONWriter interface {
+	AddStringKey(key string, v string)
+	AddBoolKey(key string, v bool)
+	AddIntKey(key string, v int)
+	AddInt64Key(key string, v int64)
+	AddFloatKey(key string, v float64)
+	AddFloat32Key(key string, v float32)
+	AddNullKey(key string)
+	AddTimeKey(key string, t time.Time, layout string)
+
+	// AddRawKey adds a value that is already encoded as JSON.
+	AddRawKey(key string, raw []byte)
+
+	AddObjectKey(key string, obj JSONObject)
+	AddArrayKey(key string, arr JSONArray)
+
+	AddString(v string)
+	AddObject(obj JSONObject)
+
+	// Len returns the number of bytes written so far.
+	Len() int
+}
+
+// GojayEncoder encodes log records using `github.com/francoispqt/gojay`.
+// This is the default Encoder and the fastest.
+type GojayEncoder struct{}
+
+// EncodeObject appends obj to buf as a JSON object.
+func (GojayEncoder) EncodeObject(buf *bytes.Buffer, obj JSONObject) error {
+	enc := gojay.BorrowEncoder(buf)
+	defer enc.Release()
+	return enc.EncodeObject(asGojayObject(obj))
+}
+
+// gojayWriter adapts a gojay.Encoder to JSONWriter. It contains only a pointer
+// so converting it to an interface does not allocate.
+type gojayWriter struct {
+	*gojay.Encoder
+}
+
+func (w gojayWriter) AddTimeKey(key string, t time.Time, layout string) {
+	w.Encoder.AddTimeKey(key, &t, layout)
+}
+
+func (w gojayWriter) AddRawKey(key string, raw []byte) {
+	embedded := gojay.EmbeddedJSON(raw)
+	w.Encoder.AddEmbeddedJSONKey(key, &embedded)
+}
+
+func (w gojayWriter) AddObjectKey(key string, obj JSONObject) {
+	w.Encoder.AddObjectKey(key, asGojayObject(obj))
+}
+
+func (w gojayWriter) AddArrayKey(key string, arr JSONArray) {
+	w.Encoder.AddArrayKey(key, asGojayArray(arr))
+}
+
+func (w gojayWriter) AddObject(obj JSONObject) {
+	w.Encoder.AddObject(asGojayObject(obj))
+}
+
+func (w gojayWriter) Len() int {
+	return len(w.Encoder.Buf())
+}
+
+// asGojayObject returns obj as a gojay marshaler, avoiding a wrapper for types
+// in this package which implement both.
+func asGojayObject(obj JSONObject) gojay.MarshalerJSONObject {
+	if m, ok := obj.(gojay.MarshalerJSONObject); ok {
+		return m
+	}
+	return gojayObject{obj: obj}
+}
+
+func asGojayArray(arr JSONArray) gojay.MarshalerJSONArray {
+	if m, ok := arr.(gojay.MarshalerJSONArray); ok {
+		return m
+	}
+	return gojayArray{arr: arr}
+}
+
+type gojayObject struct {
+	obj JSONObject
+}
+
+func (o gojayObject) MarshalJSONObject(enc *gojay.Encoder) {
+	o.obj.WriteJSONObject(gojayWriter{enc})
+}
+
+func (o gojayObject) IsNil() bool {
+	return o.obj == nil
+}
+
+type gojayArray struct {
+	arr JSONArray
+}
+
+func (a gojayArray) MarshalJSONArray(enc *gojay.Encoder) {
+	a.arr.WriteJSONArray(gojayWriter{enc})
+}
+
+func (a gojayArray) IsNil() bool {
+	return false
+}
+
+// StdEncoder encodes log records using `encoding/json` for string and number
+// values. It is slower than GojayEncoder but renders values exactly as
+// `encoding/json` does, and allows floats to be formatted per house
+// conventions via AppendFloat.
+type StdEncoder struct {
+	// AppendFloat, when not nil, appends the JSON encoding of a float with the
+	// given bit size (32 or 64) to dst and returns the extended slice, e.g.
+	// `strconv.AppendFloat(dst, v, 'f', 2, bitSize)`.
+	AppendFloat func(dst []byte, v float64, bitSize int) []byte
+}
+
+// EncodeObject appends obj to buf as a JSON object.
+func (e *StdEncoder) EncodeObject(buf *bytes.Buffer, obj JSONObject) error {
+	w := &stdWriter{enc: e, buf: buf}
+	buf.WriteByte('{')
+	obj.WriteJSONObject(w)
+	buf.WriteByte('}')
+	return nil
+}
+
+// stdWriter writes JSON to a buffer for StdEncoder.
+type stdWriter struct {
+	enc     *StdEncoder
+	buf     *bytes.Buffer
+	scratch []byte
+}
+
+// sep writes a comma unless this is the first member of an object or array.
+func (w *stdWriter) sep() {
+	if b := w.buf.Bytes(); len(b) > 0 {
+		if last := b[len(b)-1]; last != '{' && last != '[' {
+			w.buf.WriteByte(',')
+		}
+	}
+}
+
+func (w *stdWriter) key(key string) {
+	w.sep()
+	w.string(key)
+	w.buf.WriteByte(':')
+}
+
+func (w *stdWriter) string(s string) {
+	b, err := reflectJSON(s)
+	if err != nil {
+		// strings always encode; this is unreachable.
+		b = []byte(strconv.Quote(s))
+	}
+	w.buf.Write(b)
+}
+
+func (w *stdWriter) float(v float64, bitSize int) {
+	if w.enc.AppendFloat != nil {
+		w.scratch = w.enc.AppendFloat(w.scratch[:0], v, bitSize)
+		w.buf.Write(w.scratch)
+		return
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		// not representable as a JSON number.
+		w.string(strconv.FormatFloat(v, 'g', -1, bitSize))
+		return
+	}
+	var val interface{} = v
+	if bitSize == 32 {
+		val = float32(v)
+	}
+	b, _ := reflectJSON(val)
+	w.buf.Write(b)
+}
+
+func (w *stdWriter) AddStringKey(key string, v string) {
+	w.key(key)
+	w.string(v)
+}
+
+func (w *stdWriter) AddBoolKey(key string, v bool) {
+	w.key(key)
+	w.buf.WriteString(strconv.FormatBool(v))
+}
+
+func (w *stdWriter) AddIntKey(key string, v int) {
+	w.AddInt64Key(key, int64(v))
+}
+
+func (w *stdWriter) AddInt64Key(key string, v int64) {
+	w.key(key)
+	w.scratch = strconv.AppendInt(w.scratch[:0], v, 10)
+	w.buf.Write(w.scratch)
+}
+
+func (w *stdWriter) AddFloatKey(key string, v float64) {
+	w.key(key)
+	w.float(v, 64)
+}
+
+func (w *stdWriter) AddFloat32Key(key string, v float32) {
+	w.key(key)
+	w.float(float64(v), 32)
+}
+
+func (w *stdWriter) AddNullKey(key string) {
+	w.key(key)
+	w.buf.WriteString("null")
+}
+
+func (w *stdWriter) AddTimeKey(key string, t time.Time, layout string) {
+	w.key(key)
+	w.string(t.Format(layout))
+}
+
+func (w *stdWriter) AddRawKey(key string, raw []byte) {
+	w.key(key)
+	w.buf.Write(raw)
+}
+
+func (w *stdWriter) AddObjectKey(key string, obj JSONObject) {
+	w.key(key)
+	w.object(obj)
+}
+
+func (w *stdWriter) AddArrayKey(key string, arr JSONArray) {
+	w.key(key)
+	w.buf.WriteByte('[')
+	arr.WriteJSONArray(w)
+	w.buf.WriteByte(']')
+}
+
+func (w *stdWriter) AddString(v string) {
+	w.sep()
+	w.string(v)
+}
+
+func (w *stdWriter) AddObject(obj JSONObject) {
+	w.sep()
+	w.object(obj)
+}
+
+func (w *stdWriter) object(obj JSONObject) {
+	w.buf.WriteByte('{')
+	obj.WriteJSONObject(w)
+	w.buf.WriteByte('}')
+}
+
+func (w *stdWriter) Len() int {
+	return w.buf.Len()
+}
+
+// addMarshalerObjectKey adds a gojay object marshaler, such as a
+// `logr.ObjectMarshaler`, natively when the writer uses gojay and otherwise
+// as pre-encoded JSON.
+func addMarshalerObjectKey(w JSONWriter, key string, m gojay.MarshalerJSONObject) {
+	if gw, ok := w.(gojayWriter); ok {
+		gw.Encoder.AddObjectKey(key, m)
+		return
+	}
+	b, err := gojay.MarshalJSONObject(m)
+	if err != nil {
+		w.AddStringKey(key, fmt.Sprintf("%+v", m))
+		return
+	}
+	w.AddRawKey(key, b)
+}
+
+// addMarshalerArrayKey adds a gojay array marshaler, such as a
+// `logr.ArrayMarshaler`, natively when the writer uses gojay and otherwise
+// as pre-encoded JSON.
+func addMarshalerArrayKey(w JSONWriter, key string, m gojay.MarshalerJSONArray) {
+	if gw, ok := w.(gojayWriter); ok {
+		gw.Encoder.AddArrayKey(key, m)
+		return
+	}
+	b, err := gojay.MarshalJSONArray(m)
+	if err != nil {
+		w.AddStringKey(key, fmt.Sprintf("%+v", m))
+		return
+	}
+	w.AddRawKey(key, b)
+}
@@ -2,9 +2,13 @@ package format
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +16,37 @@ import (
 	"github.com/mattermost/logr"
 )
 
+const (
+	// FieldsTruncatedKey is the key of the field containing the number of
+	// context fields omitted due to `MaxFields`.
+	FieldsTruncatedKey = "_fields_truncated"
+
+	// MsgTruncatedMarker is appended to messages truncated due to
+	// `MaxMsgBytes`, followed by the original message length in bytes.
+	MsgTruncatedMarker = "…[truncated, bytes="
+
+	// DefOversizeFieldBytes is the default encoded size, in bytes, above which
+	// `JSON.OnOversizeField` is called.
+	DefOversizeFieldBytes = 4096
+)
+
+// DurationFormat determines how `JSON` outputs time.Duration fields.
+type DurationFormat int
+
+const (
+	// DurationString outputs durations as strings via `time.Duration.String`,
+	// e.g. `"1.5s"`. This is the default.
+	DurationString DurationFormat = iota
+	// DurationNanos outputs durations as an integer number of nanoseconds.
+	DurationNanos
+	// DurationMillis outputs durations as a number of milliseconds, with a
+	// fractional part for sub-millisecond precision.
+	DurationMillis
+	// DurationSeconds outputs durations as a number of seconds, with a
+	// fractional part.
+	DurationSeconds
+)
+
 // ContextField is a name/value pair within the context fields.
 type ContextField struct {
 	Key string
@@ -31,17 +66,82 @@ type JSON struct {
 	// DisableStacktrace disables output of stack trace.
 	DisableStacktrace bool
 
-	// TimestampFormat is an optional format for timestamps. If empty
-	// then DefTimestampFormat is used.
+	// MaxMsgBytes, when greater than zero, truncates messages longer than this
+	// many bytes, e.g. due to an interpolated request body, appending
+	// MsgTruncatedMarker and the original length, e.g.
+	// `…[truncated, bytes=1048576]`. Truncation never splits a UTF-8 character.
+	// This is independent of `OnOversizeField`, which applies to context fields.
+	MaxMsgBytes int
+
+	// OmitEmptyMsg omits the msg field when the message is empty, such as for
+	// records that carry only fields to mark an event. Has no effect when
+	// DisableMsg is true, which always omits the msg field.
+	OmitEmptyMsg bool
+
+	// TimestampFormat is an optional format for timestamps, used for both the
+	// record timestamp and any time.Time context fields. If empty then
+	// DefTimestampFormat is used.
 	TimestampFormat string
 
-	// Deprecated: this has no effect.
+	// TimestampMinLevel, when set, outputs the timestamp only for log records
+	// at this level or more severe, e.g. `logr.Warn` timestamps errors and
+	// warnings but not info, debug or trace records. The zero Level outputs the
+	// timestamp at all levels. Has no effect when DisableTimestamp is true.
+	TimestampMinLevel logr.Level
+
+	// TimeTruncate, when greater than zero, truncates the record timestamp and
+	// any time.Time context fields to a multiple of this duration before
+	// rendering, e.g. `time.Second`.
+	TimeTruncate time.Duration
+
+	// Pretty outputs human-readable, multi-line JSON indented by Indent.
+	// This is intended for development; it is considerably slower than the
+	// default compact output and each log record spans multiple lines.
+	Pretty bool
+
+	// Indent is the indentation used when Pretty is true. Defaults to
+	// two spaces. Has no effect unless Pretty is true.
 	Indent string
 
-	// EscapeHTML determines if certain characters (e.g. `<`, `>`, `&`)
-	// are escaped.
+	// LevelUppercase outputs level names in upper case, e.g. `ERROR`.
+	// Level names are never padded in JSON output.
+	LevelUppercase bool
+
+	// EscapeHTML determines if the characters `<`, `>` and `&` are escaped
+	// as `\u003c`, `\u003e` and `\u0026` within all keys and string values,
+	// making the output safe to embed in HTML. Defaults to false.
 	EscapeHTML bool
 
+	// SanitizeControl causes C0 control characters other than tab, such as
+	// ANSI escapes, null bytes and newlines, within keys and string values to
+	// be output as their visible escape sequences, e.g. the JSON string
+	// `"\\x1b[31m"` rather than `"\u001b[31m"`. This prevents user supplied
+	// values from corrupting terminals and log viewers that display decoded
+	// values. Defaults to false.
+	SanitizeControl bool
+
+	// UnwrapErrors outputs error fields as an object containing the error
+	// message, the messages of the errors it wraps, found via `errors.Unwrap`,
+	// and the type of the root cause, e.g.
+	// `{"msg":"save: open x: not found","causes":["open x: not found","not found"],"type":"*errors.errorString"}`.
+	// Defaults to false, which outputs only the error message.
+	UnwrapErrors bool
+
+	// DurationFormat determines how time.Duration context fields, including
+	// those created via `logr.Duration`, are output.
+	// Defaults to DurationString.
+	DurationFormat DurationFormat
+
+	// ComplexAsString outputs complex number fields, created via
+	// `logr.Complex128` or `logr.Complex64`, as strings such as `"(1+2i)"`.
+	// Defaults to false, which outputs objects such as `{"real":1,"imag":2}`.
+	ComplexAsString bool
+
+	// Keys, when not nil, sets all the standard field key names at once, and
+	// optionally level names, to match a schema, e.g. `DatadogKeys()`. Key
+	// names set individually below take precedence over Keys.
+	Keys *KeyNames
+
 	// KeyTimestamp overrides the timestamp field key name.
 	KeyTimestamp string
 
@@ -51,52 +151,180 @@ type JSON struct {
 	// KeyMsg overrides the msg field key name.
 	KeyMsg string
 
+	// KeySequence overrides the key name of the sequence number, output when
+	// `logr.Logr.EnableSequence` is true. Defaults to DefSequenceKey.
+	KeySequence string
+
 	// KeyContextFields when not empty will group all context fields
 	// under this key.
 	KeyContextFields string
 
-	// KeyStacktrace overrides the stacktrace field key name.
+	// KeyStacktrace overrides the stacktrace field key name. When a stack
+	// trace is output and an error field carries its own stack trace (e.g.
+	// errors from `github.com/pkg/errors`), that stack is output in
+	// preference to the one captured at the log call.
 	KeyStacktrace string
 
-	// ContextSorter allows custom sorting for the context fields.
+	// KeyAllStacks overrides the key name for the all goroutines stack dump.
+	KeyAllStacks string
+
+	// TrimStackPathPrefix, when not empty, is removed from the start of each
+	// stack frame's file path, e.g. a build machine's module root, so frames
+	// show `pkg/file.go` rather than the full path. This keeps stack traces
+	// shorter and avoids leaking build environment details.
+	TrimStackPathPrefix string
+
+	// OmitEmpty skips context fields whose value is empty: nil, the zero value
+	// for its type (e.g. "", 0, false) or an empty slice or map. This is best
+	// effort since an explicitly logged zero cannot be distinguished from a
+	// default; wrap values that must always be output with `logr.Always`.
+	OmitEmpty bool
+
+	// ContextSorter allows custom sorting for the context fields. When nil the
+	// fields are sorted by key, otherwise the sorter is passed the merged
+	// fields from `logr.LogRec.Fields`.
 	ContextSorter func(fields logr.Fields) []ContextField
 
+	// PreserveFieldOrder outputs context fields in the order of
+	// `logr.LogRec.EachFieldInOrder`, so typed fields added via
+	// `logr.Logger.With` keep the order they were added in rather than being
+	// sorted by key. Ignored when ContextSorter is set. Defaults to false.
+	PreserveFieldOrder bool
+
+	// MaxFields, when greater than zero, limits the number of context fields
+	// output per log record. When exceeded, the first MaxFields fields, in
+	// output order, are output followed by a FieldsTruncatedKey field containing the
+	// number omitted. This guards log indexes against pathological records.
+	MaxFields int
+
+	// OnOversizeField, when not nil, is called for each context field whose
+	// encoded size (key and value) exceeds OversizeFieldBytes. This can be used
+	// to catch huge field values before they reach a log index. It is called
+	// from the target's goroutine and should return quickly.
+	OnOversizeField func(key string, size int)
+
+	// OversizeFieldBytes is the encoded size, in bytes, above which
+	// `OnOversizeField` is called. Defaults to DefOversizeFieldBytes.
+	OversizeFieldBytes int
+
+	// Encoder is the backend used to encode log records, e.g. `&StdEncoder{}`
+	// to render values as `encoding/json` does. Defaults to GojayEncoder.
+	Encoder Encoder
+
 	once sync.Once
 }
 
 // Format converts a log record to bytes in JSON format.
 func (j *JSON) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	buf, err := j.format(rec, stacktrace, buf, j.Pretty)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf, nil
+}
+
+// format converts a log record to JSON, indented if pretty is true, without a
+// trailing newline.
+func (j *JSON) format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer, pretty bool) (*bytes.Buffer, error) {
 	j.once.Do(j.applyDefaultKeyNames)
 
 	if buf == nil {
 		buf = &bytes.Buffer{}
 	}
-	enc := gojay.BorrowEncoder(buf)
-	defer func() {
-		enc.Release()
-	}()
+	start := buf.Len()
 
-	sorter := j.ContextSorter
-	if sorter == nil {
-		sorter = j.defaultContextSorter
+	encoder := j.Encoder
+	if encoder == nil {
+		encoder = GojayEncoder{}
 	}
 
 	jlr := JSONLogRec{
 		LogRec:     rec,
 		JSON:       j,
 		stacktrace: stacktrace,
-		sorter:     sorter,
 	}
 
-	err := enc.EncodeObject(jlr)
+	err := encoder.EncodeObject(buf, jlr)
 	if err != nil {
 		return nil, err
 	}
-	buf.WriteByte('\n')
+	if j.SanitizeControl {
+		sanitizeControlJSON(buf, start)
+	}
+	if j.EscapeHTML {
+		escapeHTML(buf, start)
+	}
+	if pretty {
+		j.indent(buf, start)
+	}
 	return buf, nil
 }
 
+// escapeHTML escapes `<`, `>` and `&` in the JSON written to buf after
+// offset start. These characters can only appear within JSON strings, so
+// replacing them with unicode escapes always yields equivalent JSON.
+func escapeHTML(buf *bytes.Buffer, start int) {
+	data := buf.Bytes()[start:]
+	if bytes.IndexAny(data, "<>&") < 0 {
+		return
+	}
+	tmp := make([]byte, len(data))
+	copy(tmp, data)
+	buf.Truncate(start)
+
+	const hex = "0123456789abcdef"
+	for _, c := range tmp {
+		switch c {
+		case '<', '>', '&':
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[c>>4])
+			buf.WriteByte(hex[c&0xF])
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// indent reformats the JSON written to buf after offset start as
+// indented, multi-line JSON. Key order is preserved.
+func (j *JSON) indent(buf *bytes.Buffer, start int) {
+	indent := j.Indent
+	if indent == "" {
+		indent = "  "
+	}
+	tmp := make([]byte, buf.Len()-start)
+	copy(tmp, buf.Bytes()[start:])
+	buf.Truncate(start)
+
+	if err := json.Indent(buf, tmp, "", indent); err != nil {
+		// output the compact form rather than lose the log record.
+		buf.Truncate(start)
+		buf.Write(tmp)
+	}
+}
+
+// FormatAppend appends the formatted log record to dst and returns the extended
+// slice, for targets that maintain their own buffer, e.g. for checksums or
+// hash chaining. dst may be nil. On error dst is returned unchanged.
+func (j *JSON) FormatAppend(rec *logr.LogRec, stacktrace bool, dst []byte) ([]byte, error) {
+	return formatAppend(j, rec, stacktrace, dst)
+}
+
+// ContentType returns the MIME type of the formatted output.
+func (j *JSON) ContentType() string {
+	return "application/json"
+}
+
+// FileExt returns a file extension suitable for the formatted output.
+func (j *JSON) FileExt() string {
+	return ".json"
+}
+
 func (j *JSON) applyDefaultKeyNames() {
+	if j.Keys != nil {
+		j.Keys.apply(j)
+	}
 	if j.KeyTimestamp == "" {
 		j.KeyTimestamp = "timestamp"
 	}
@@ -106,24 +334,23 @@ func (j *JSON) applyDefaultKeyNames() {
 	if j.KeyMsg == "" {
 		j.KeyMsg = "msg"
 	}
+	if j.KeySequence == "" {
+		j.KeySequence = logr.DefSequenceKey
+	}
 	if j.KeyStacktrace == "" {
 		j.KeyStacktrace = "stacktrace"
 	}
-}
-
-// defaultContextSorter sorts the context fields alphabetically by key.
-func (j *JSON) defaultContextSorter(fields logr.Fields) []ContextField {
-	keys := make([]string, 0, len(fields))
-	for k := range fields {
-		keys = append(keys, k)
+	if j.KeyAllStacks == "" {
+		j.KeyAllStacks = "goroutines"
 	}
-	sort.Strings(keys)
+}
 
-	cf := make([]ContextField, 0, len(keys))
-	for _, k := range keys {
-		cf = append(cf, ContextField{Key: k, Val: fields[k]})
+// levelName returns the name output for the level, which may be mapped via Keys.
+func (j *JSON) levelName(lvl logr.Level) string {
+	if name, ok := j.Keys.levelName(lvl); ok {
+		return name
 	}
-	return cf
+	return levelName(lvl, j.LevelUppercase, 0)
 }
 
 // JSONLogRec decorates a LogRec adding JSON encoding.
@@ -131,42 +358,57 @@ type JSONLogRec struct {
 	*logr.LogRec
 	*JSON
 	stacktrace bool
-	sorter     func(fields logr.Fields) []ContextField
 }
 
 // MarshalJSONObject encodes the LogRec as JSON.
 func (rec JSONLogRec) MarshalJSONObject(enc *gojay.Encoder) {
-	if !rec.DisableTimestamp {
+	rec.WriteJSONObject(gojayWriter{enc})
+}
+
+// WriteJSONObject encodes the LogRec as JSON via the JSONWriter.
+func (rec JSONLogRec) WriteJSONObject(enc JSONWriter) {
+	if !rec.DisableTimestamp && timestampEnabled(rec.Level(), rec.TimestampMinLevel) {
 		timestampFmt := rec.TimestampFormat
 		if timestampFmt == "" {
 			timestampFmt = logr.DefTimestampFormat
 		}
-		time := rec.Time()
-		enc.AddTimeKey(rec.KeyTimestamp, &time, timestampFmt)
+		enc.AddTimeKey(rec.KeyTimestamp, rec.truncateTime(rec.Time()), timestampFmt)
 	}
 	if !rec.DisableLevel {
-		enc.AddStringKey(rec.KeyLevel, rec.Level().Name)
+		enc.AddStringKey(rec.KeyLevel, rec.levelName(rec.Level()))
+	}
+	if seq, ok := rec.Sequence(); ok {
+		enc.AddInt64Key(rec.KeySequence, seq)
 	}
 	if !rec.DisableMsg {
-		enc.AddStringKey(rec.KeyMsg, rec.Msg())
+		msg := rec.Msg()
+		if rec.Newline() {
+			// the trailing newline from LoglnXXX is only meaningful for line based output.
+			msg = strings.TrimSuffix(msg, "\n")
+		}
+		msg = truncateMsg(msg, rec.MaxMsgBytes)
+		if msg != "" || !rec.OmitEmptyMsg {
+			enc.AddStringKey(rec.KeyMsg, msg)
+		}
 	}
 	if !rec.DisableContext {
-		ctxFields := rec.sorter(rec.Fields())
 		if rec.KeyContextFields != "" {
-			enc.AddObjectKey(rec.KeyContextFields, jsonFields(ctxFields))
+			enc.AddObjectKey(rec.KeyContextFields, jsonFields{rec})
 		} else {
-			if len(ctxFields) > 0 {
-				for _, cf := range ctxFields {
-					key := rec.prefixCollision(cf.Key)
-					encodeField(enc, key, cf.Val)
-				}
-			}
+			rec.writeContextFields(enc, true)
 		}
 	}
 	if rec.stacktrace && !rec.DisableStacktrace {
-		frames := rec.StackFrames()
+		// prefer the stack recorded where an error originated.
+		frames := rec.ErrorStackFrames()
+		if len(frames) == 0 {
+			frames = rec.StackFrames()
+		}
 		if len(frames) > 0 {
-			enc.AddArrayKey(rec.KeyStacktrace, stackFrames(frames))
+			enc.AddArrayKey(rec.KeyStacktrace, stackFrames(trimStackPaths(frames, rec.TrimStackPathPrefix)))
+		}
+		if allStacks := rec.AllStacks(); len(allStacks) > 0 {
+			enc.AddStringKey(rec.KeyAllStacks, string(allStacks))
 		}
 	}
 
@@ -177,9 +419,43 @@ func (rec JSONLogRec) IsNil() bool {
 	return rec.LogRec == nil
 }
 
+// writeContextFields encodes the context fields, sorted via ContextSorter
+// if set, and limited to MaxFields. Keys colliding with the record's own
+// keys are prefixed when collide is true.
+func (rec JSONLogRec) writeContextFields(enc JSONWriter, collide bool) {
+	var written, truncated int
+	write := func(key string, val interface{}) {
+		if rec.MaxFields > 0 && written >= rec.MaxFields {
+			truncated++
+			return
+		}
+		if collide {
+			key = rec.prefixCollision(key)
+		}
+		rec.encodeContextField(enc, key, val)
+		written++
+	}
+
+	if rec.ContextSorter != nil {
+		for _, cf := range rec.ContextSorter(rec.Fields()) {
+			write(cf.Key, cf.Val)
+		}
+	} else if rec.PreserveFieldOrder {
+		rec.EachFieldInOrder(write)
+	} else {
+		rec.EachField(write)
+	}
+	if truncated > 0 {
+		enc.AddIntKey(FieldsTruncatedKey, truncated)
+	}
+}
+
 func (rec JSONLogRec) prefixCollision(key string) string {
 	switch key {
-	case rec.KeyTimestamp, rec.KeyLevel, rec.KeyMsg, rec.KeyStacktrace:
+	case rec.KeyTimestamp, rec.KeyLevel, rec.KeyMsg, rec.KeyStacktrace, rec.KeyAllStacks:
+		return rec.prefixCollision("_" + key)
+	}
+	if _, ok := rec.Sequence(); ok && key == rec.KeySequence {
 		return rec.prefixCollision("_" + key)
 	}
 	return key
@@ -189,6 +465,11 @@ type stackFrames []runtime.Frame
 
 // MarshalJSONArray encodes stackFrames slice as JSON.
 func (s stackFrames) MarshalJSONArray(enc *gojay.Encoder) {
+	s.WriteJSONArray(gojayWriter{enc})
+}
+
+// WriteJSONArray encodes stackFrames slice as JSON via the JSONWriter.
+func (s stackFrames) WriteJSONArray(enc JSONWriter) {
 	for _, frame := range s {
 		enc.AddObject(stackFrame(frame))
 	}
@@ -199,10 +480,32 @@ func (s stackFrames) IsNil() bool {
 	return len(s) == 0
 }
 
+// trimStackPaths returns a copy of frames with prefix, and any following path
+// separator, removed from each file path. frames is returned unchanged if prefix
+// is empty.
+func trimStackPaths(frames []runtime.Frame, prefix string) []runtime.Frame {
+	if prefix == "" {
+		return frames
+	}
+	trimmed := make([]runtime.Frame, len(frames))
+	for i, frame := range frames {
+		if strings.HasPrefix(frame.File, prefix) {
+			frame.File = strings.TrimLeft(frame.File[len(prefix):], "/")
+		}
+		trimmed[i] = frame
+	}
+	return trimmed
+}
+
 type stackFrame runtime.Frame
 
-// MarshalJSONArray encodes stackFrame as JSON.
+// MarshalJSONObject encodes stackFrame as JSON.
 func (f stackFrame) MarshalJSONObject(enc *gojay.Encoder) {
+	f.WriteJSONObject(gojayWriter{enc})
+}
+
+// WriteJSONObject encodes stackFrame as JSON via the JSONWriter.
+func (f stackFrame) WriteJSONObject(enc JSONWriter) {
 	enc.AddStringKey("Function", f.Function)
 	enc.AddStringKey("File", f.File)
 	enc.AddIntKey("Line", f.Line)
@@ -212,26 +515,323 @@ func (f stackFrame) IsNil() bool {
 	return false
 }
 
-type jsonFields []ContextField
+// jsonFields encodes the context fields of a JSONLogRec as a nested object.
+type jsonFields struct {
+	rec JSONLogRec
+}
 
-// MarshalJSONObject encodes Fields map to JSON.
+// MarshalJSONObject encodes the context fields to JSON.
 func (f jsonFields) MarshalJSONObject(enc *gojay.Encoder) {
-	for _, ctxField := range f {
-		encodeField(enc, ctxField.Key, ctxField.Val)
-	}
+	f.WriteJSONObject(gojayWriter{enc})
+}
+
+// WriteJSONObject encodes the context fields to JSON via the JSONWriter.
+func (f jsonFields) WriteJSONObject(enc JSONWriter) {
+	f.rec.writeContextFields(enc, false)
 }
 
-// IsNil returns true if map is nil.
+// IsNil returns true if the record has no context fields.
 func (f jsonFields) IsNil() bool {
-	return f == nil
+	return !f.rec.HasFields()
 }
 
-func encodeField(enc *gojay.Encoder, key string, val interface{}) {
+// encodeContextField encodes a context field, checking its encoded size
+// when `OnOversizeField` is set.
+func (j *JSON) encodeContextField(enc JSONWriter, key string, val interface{}) {
+	if j.OmitEmpty && isEmptyValue(val) {
+		return
+	}
+
+	// time fields use the same layout as the record timestamp.
 	switch vt := val.(type) {
-	case gojay.MarshalerJSONObject:
+	case time.Time:
+		val = j.formatTime(vt)
+	case *time.Time:
+		if vt != nil {
+			val = j.formatTime(*vt)
+		}
+	case time.Duration:
+		val = j.durationValue(vt)
+	case logr.Field:
+		if vt.Type == logr.DurationType && !vt.Sensitive {
+			val = j.durationValue(time.Duration(vt.Integer))
+		}
+	}
+
+	if j.UnwrapErrors {
+		val = unwrapErrorValue(val)
+	}
+	if j.ComplexAsString {
+		if f, ok := val.(logr.Field); ok && !f.Sensitive && (f.Type == logr.Complex128Type || f.Type == logr.Complex64Type) {
+			val = fmt.Sprint(f.Interface)
+		}
+	}
+
+	if j.OnOversizeField == nil {
+		encodeField(enc, key, val)
+		return
+	}
+
+	start := enc.Len()
+	encodeField(enc, key, val)
+	size := enc.Len() - start
+
+	limit := j.OversizeFieldBytes
+	if limit == 0 {
+		limit = DefOversizeFieldBytes
+	}
+	if size > limit {
+		j.OnOversizeField(key, size)
+	}
+}
+
+// unwrapErrorValue returns an errorChain for non-nil error values, otherwise
+// returns val unchanged.
+func unwrapErrorValue(val interface{}) interface{} {
+	v := val
+	if av, ok := v.(logr.AlwaysValue); ok {
+		v = av.Val
+	}
+	if err, ok := v.(error); ok && !isNil(err) {
+		return errorChain{err: err}
+	}
+	return val
+}
+
+// errorChain encodes an error and the chain of errors it wraps.
+type errorChain struct {
+	err error
+}
+
+// MarshalJSONObject encodes the error chain as JSON.
+func (ec errorChain) MarshalJSONObject(enc *gojay.Encoder) {
+	ec.WriteJSONObject(gojayWriter{enc})
+}
+
+// WriteJSONObject encodes the error chain as JSON via the JSONWriter.
+func (ec errorChain) WriteJSONObject(enc JSONWriter) {
+	enc.AddStringKey("msg", ec.err.Error())
+	root := ec.err
+	var causes errorCauses
+	for cause := errors.Unwrap(root); cause != nil; cause = errors.Unwrap(cause) {
+		causes = append(causes, cause.Error())
+		root = cause
+	}
+	enc.AddArrayKey("causes", causes)
+	enc.AddStringKey("type", fmt.Sprintf("%T", root))
+}
+
+// IsNil returns true if the error is nil.
+func (ec errorChain) IsNil() bool {
+	return ec.err == nil
+}
+
+// errorCauses encodes the messages of wrapped errors as a JSON array.
+type errorCauses []string
+
+// MarshalJSONArray encodes the messages as JSON.
+func (c errorCauses) MarshalJSONArray(enc *gojay.Encoder) {
+	c.WriteJSONArray(gojayWriter{enc})
+}
+
+// WriteJSONArray encodes the messages as JSON via the JSONWriter.
+func (c errorCauses) WriteJSONArray(enc JSONWriter) {
+	for _, msg := range c {
+		enc.AddString(msg)
+	}
+}
+
+// IsNil always returns false so that an empty chain is output as `[]`.
+func (c errorCauses) IsNil() bool {
+	return false
+}
+
+// formatTime truncates t per `TimeTruncate` and formats it per `TimestampFormat`.
+func (j *JSON) formatTime(t time.Time) string {
+	layout := j.TimestampFormat
+	if layout == "" {
+		layout = logr.DefTimestampFormat
+	}
+	return j.truncateTime(t).Format(layout)
+}
+
+// durationValue returns d as a value to encode per `DurationFormat`.
+func (j *JSON) durationValue(d time.Duration) interface{} {
+	switch j.DurationFormat {
+	case DurationNanos:
+		return int64(d)
+	case DurationMillis:
+		return float64(d) / float64(time.Millisecond)
+	case DurationSeconds:
+		return d.Seconds()
+	}
+	return d.String()
+}
+
+// truncateTime truncates t per `TimeTruncate`.
+func (j *JSON) truncateTime(t time.Time) time.Time {
+	if j.TimeTruncate <= 0 {
+		return t
+	}
+	return t.Truncate(j.TimeTruncate)
+}
+
+// isNil returns true if val is nil or a nil pointer, including a nil
+// pointer stored in an interface such as error.
+func isNil(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	rv := reflect.ValueOf(val)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// isEmptyValue returns true if val is nil, the zero value for its type,
+// or an empty slice or map. AlwaysValue is never empty.
+func isEmptyValue(val interface{}) bool {
+	switch vt := val.(type) {
+	case nil:
+		return true
+	case logr.AlwaysValue:
+		return false
+	case logr.Field:
+		return isEmptyValue(vt.Value())
+	case string:
+		return vt == ""
+	case int:
+		return vt == 0
+	case int64:
+		return vt == 0
+	case float64:
+		return vt == 0
+	case bool:
+		return !vt
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+// sortedMap encodes a map as a JSON object with keys in sorted order.
+type sortedMap map[string]interface{}
+
+// MarshalJSONObject encodes the map, recursing through encodeField for values.
+func (m sortedMap) MarshalJSONObject(enc *gojay.Encoder) {
+	m.WriteJSONObject(gojayWriter{enc})
+}
+
+// WriteJSONObject encodes the map via the JSONWriter.
+func (m sortedMap) WriteJSONObject(enc JSONWriter) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		encodeField(enc, k, m[k])
+	}
+}
+
+// IsNil returns true if the map is nil.
+func (m sortedMap) IsNil() bool {
+	return m == nil
+}
+
+// encodeTypedField encodes a logr.Field based on its type.
+func encodeTypedField(enc JSONWriter, key string, f logr.Field) {
+	if f.Sensitive {
+		enc.AddStringKey(key, logr.RedactedValue)
+		return
+	}
+	switch f.Type {
+	case logr.ArrayMarshalerType:
+		addMarshalerArrayKey(enc, key, f.Interface.(logr.ArrayMarshaler))
+	case logr.ObjectMarshalerType:
+		addMarshalerObjectKey(enc, key, f.Interface.(logr.ObjectMarshaler))
+	case logr.MapType:
+		enc.AddObjectKey(key, sortedMap(f.Interface.(map[string]interface{})))
+	case logr.StringType:
+		enc.AddStringKey(key, f.String)
+	case logr.Int64Type:
+		enc.AddInt64Key(key, f.Integer)
+	case logr.StringerType, logr.IntBaseType:
+		enc.AddStringKey(key, f.Value().(string))
+	case logr.Complex128Type:
+		enc.AddObjectKey(key, complexNumber(f.Interface.(complex128)))
+	case logr.Complex64Type:
+		enc.AddObjectKey(key, complexNumber(f.Interface.(complex64)))
+	case logr.ReflectType:
+		b, err := reflectJSON(f.Interface)
+		if err != nil {
+			enc.AddStringKey(key, fmt.Sprintf("%+v", f.Interface))
+			return
+		}
+		enc.AddRawKey(key, b)
+	default:
+		encodeField(enc, key, f.Value())
+	}
+}
+
+// complexNumber encodes a complex number as a JSON object.
+type complexNumber complex128
+
+// MarshalJSONObject encodes the real and imaginary parts.
+func (c complexNumber) MarshalJSONObject(enc *gojay.Encoder) {
+	c.WriteJSONObject(gojayWriter{enc})
+}
+
+// WriteJSONObject encodes the real and imaginary parts via the JSONWriter.
+func (c complexNumber) WriteJSONObject(enc JSONWriter) {
+	enc.AddFloatKey("real", real(c))
+	enc.AddFloatKey("imag", imag(c))
+}
+
+// IsNil always returns false.
+func (c complexNumber) IsNil() bool {
+	return false
+}
+
+// reflectJSON encodes val via reflection using `encoding/json`. HTML characters
+// are not escaped here; see `JSON.EscapeHTML`.
+func reflectJSON(val interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(val); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func encodeField(enc JSONWriter, key string, val interface{}) {
+	switch vt := val.(type) {
+	case logr.AlwaysValue:
+		val = vt.Val
+	case logr.Field:
+		encodeTypedField(enc, key, vt)
+		return
+	}
+
+	if isNil(val) {
+		enc.AddNullKey(key)
+		return
+	}
+
+	switch vt := val.(type) {
+	case JSONObject:
 		enc.AddObjectKey(key, vt)
-	case gojay.MarshalerJSONArray:
+	case JSONArray:
 		enc.AddArrayKey(key, vt)
+	case gojay.MarshalerJSONObject:
+		addMarshalerObjectKey(enc, key, vt)
+	case gojay.MarshalerJSONArray:
+		addMarshalerArrayKey(enc, key, vt)
 	case string:
 		enc.AddStringKey(key, vt)
 	case error:
@@ -260,12 +860,18 @@ func encodeField(enc *gojay.Encoder, key string, val interface{}) {
 		enc.AddFloatKey(key, vt)
 	case float32:
 		enc.AddFloat32Key(key, vt)
+	case map[string]interface{}:
+		enc.AddObjectKey(key, sortedMap(vt))
+	case logr.Fields:
+		enc.AddObjectKey(key, sortedMap(vt))
 	case *gojay.EmbeddedJSON:
-		enc.AddEmbeddedJSONKey(key, vt)
+		enc.AddRawKey(key, *vt)
 	case time.Time:
-		enc.AddTimeKey(key, &vt, logr.DefTimestampFormat)
-	case *time.Time:
 		enc.AddTimeKey(key, vt, logr.DefTimestampFormat)
+	case *time.Time:
+		enc.AddTimeKey(key, *vt, logr.DefTimestampFormat)
+	case fmt.Stringer:
+		encodeTypedField(enc, key, logr.Stringer(key, vt))
 	default:
 		s := fmt.Sprintf("%v", vt)
 		enc.AddStringKey(key, s)
@@ -1,9 +1,16 @@
 package format_test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mattermost/logr"
 	"github.com/mattermost/logr/format"
@@ -161,6 +168,489 @@ func TestJSON(t *testing.T) {
 	}
 }
 
+func TestJSONOversizeField(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+
+	var mux sync.Mutex
+	oversize := make(map[string]int)
+
+	formatter := &format.JSON{
+		DisableTimestamp:   true,
+		OversizeFieldBytes: 100,
+		OnOversizeField: func(key string, size int) {
+			mux.Lock()
+			defer mux.Unlock()
+			oversize[key] = size
+		},
+	}
+
+	buf := &test.Buffer{}
+	target := target.NewWriterTarget(filter, formatter, buf, 1000)
+	err := lgr.AddTarget(target)
+	if err != nil {
+		t.Error(err)
+	}
+
+	logger := lgr.NewLogger().WithFields(logr.Fields{
+		"small": "tiny",
+		"big":   strings.Repeat("x", 500),
+	})
+	logger.Error("This is an error.")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	if len(oversize) != 1 {
+		t.Errorf("expected exactly one oversize field, got %v", oversize)
+	}
+	if size, ok := oversize["big"]; !ok || size < 500 {
+		t.Errorf("expected oversize field \"big\" with size >= 500, got %v", oversize)
+	}
+}
+
+func TestJSONEscapeHTML(t *testing.T) {
+	tests := []struct {
+		name       string
+		escapeHTML bool
+		want       string
+	}{
+		{name: "escaped", escapeHTML: true, want: NL(`{"level":"error","msg":"\u003cb\u003ebold\u003c/b\u003e \u0026 more","html":"\u003ci\u003e"}`)},
+		{name: "not escaped", escapeHTML: false, want: NL(`{"level":"error","msg":"<b>bold</b> & more","html":"<i>"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+			formatter := &format.JSON{DisableTimestamp: true, EscapeHTML: tt.escapeHTML}
+			buf := &test.Buffer{}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+			if err != nil {
+				t.Error(err)
+			}
+
+			lgr.NewLogger().WithField("html", "<i>").Error("<b>bold</b> & more")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Error(err)
+			}
+
+			if buf.String() != tt.want {
+				t.Errorf("JSON does not match: expected %s   got %s", tt.want, buf.String())
+			}
+
+			var m map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+				t.Error(err)
+			}
+			if m["msg"] != "<b>bold</b> & more" {
+				t.Errorf("unexpected decoded msg: %v", m["msg"])
+			}
+		})
+	}
+}
+
+func TestJSONOmitEmpty(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true, OmitEmpty: true}
+	buf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	var nilErr error
+	fields := logr.Fields{
+		"empty_str":   "",
+		"zero_int":    0,
+		"zero_float":  0.0,
+		"nil_err":     nilErr,
+		"nil_ptr":     (*int)(nil),
+		"empty_slice": []string{},
+		"kept_zero":   logr.Always(0),
+		"kept_str":    logr.Always(""),
+		"name":        "wiggin",
+		"count":       3,
+	}
+	lgr.NewLogger().WithFields(fields).Error("This is an error.")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := NL(`{"level":"error","msg":"This is an error.","count":3,"kept_str":"","kept_zero":0,"name":"wiggin"}`)
+	if buf.String() != want {
+		t.Errorf("JSON does not match: expected %s   got %s", want, buf.String())
+	}
+}
+
+// stackErr mimics errors from `github.com/pkg/errors` which record
+// the stack where they were created.
+type stackErr struct {
+	pcs []stackErrFrame
+}
+
+type stackErrFrame uintptr
+
+func (e stackErr) Error() string { return "stack error" }
+
+func (e stackErr) StackTrace() []stackErrFrame { return e.pcs }
+
+func newStackErr() error {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(1, pcs)
+	frames := make([]stackErrFrame, n)
+	for i := 0; i < n; i++ {
+		frames[i] = stackErrFrame(pcs[i])
+	}
+	return stackErr{pcs: frames}
+}
+
+func TestJSONErrorStacktrace(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Error}
+	formatter := &format.JSON{DisableTimestamp: true}
+	buf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	logger := lgr.NewLogger()
+	logger.WithError(newStackErr()).Error("with stack error")
+	logger.WithError(errors.New("plain error")).Error("with plain error")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	for i, wantFunc := range []string{"newStackErr", "TestJSONErrorStacktrace"} {
+		var m struct {
+			Error      string
+			Stacktrace []struct{ Function string }
+		}
+		if err := json.Unmarshal([]byte(lines[i]), &m); err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Stacktrace) == 0 {
+			t.Fatalf("line %d: missing stacktrace", i)
+		}
+		if !strings.HasSuffix(m.Stacktrace[0].Function, wantFunc) {
+			t.Errorf("line %d: expected stack to start at %s, got %s", i, wantFunc, m.Stacktrace[0].Function)
+		}
+	}
+}
+
+func TestJSONTimeTruncate(t *testing.T) {
+	lgr := &logr.Logr{}
+	ts := time.Date(2020, 5, 17, 10, 30, 15, 987654321, time.UTC)
+	logger := lgr.NewLogger().WithField("when", ts)
+	rec := logr.NewLogRec(logr.Info, logger, "", nil, false).WithTime(ts)
+
+	tests := []struct {
+		name     string
+		truncate time.Duration
+		want     string
+	}{
+		{name: "none", truncate: 0, want: `"2020-05-17T10:30:15.987654321Z"`},
+		{name: "millisecond", truncate: time.Millisecond, want: `"2020-05-17T10:30:15.987Z"`},
+		{name: "second", truncate: time.Second, want: `"2020-05-17T10:30:15Z"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter := &format.JSON{TimestampFormat: time.RFC3339Nano, TimeTruncate: tt.truncate, DisableMsg: true}
+			buf, err := formatter.Format(rec, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// time fields use the same layout as the record timestamp.
+			want := NL(`{"timestamp":` + tt.want + `,"level":"info","when":` + tt.want + `}`)
+			if buf.String() != want {
+				t.Errorf("JSON does not match: expected %s   got %s", want, buf.String())
+			}
+		})
+	}
+}
+
+func TestJSONPretty(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true, Pretty: true, Indent: "\t", EscapeHTML: true}
+	buf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	lgr.NewLogger().WithFields(logr.Fields{"name": "<wiggin>", "count": 3}).Error("pretty")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := "{\n\t\"level\": \"error\",\n\t\"msg\": \"pretty\",\n\t\"count\": 3,\n\t\"name\": \"\\u003cwiggin\\u003e\"\n}\n"
+	if buf.String() != want {
+		t.Errorf("JSON does not match: expected %s   got %s", want, buf.String())
+	}
+}
+
+type nilErr struct{}
+
+func (e *nilErr) Error() string { return e.String() }
+
+func (e *nilErr) String() string { return "never called" }
+
+func TestJSONNilFields(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true}
+	buf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	var nilIntf interface{}
+	var nilError error
+	fields := logr.Fields{
+		"nil_ptr":       (*int)(nil),
+		"nil_error":     nilError,
+		"nil_intf":      nilIntf,
+		"nil_typed_err": error((*nilErr)(nil)),
+		"nil_time":      (*time.Time)(nil),
+	}
+	lgr.NewLogger().WithFields(fields).Error("nils")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := NL(`{"level":"error","msg":"nils","nil_error":null,"nil_intf":null,"nil_ptr":null,"nil_time":null,"nil_typed_err":null}`)
+	if buf.String() != want {
+		t.Errorf("JSON does not match: expected %s   got %s", want, buf.String())
+	}
+}
+
+func TestJSONLogln(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	jsonBuf := &test.Buffer{}
+	plainBuf := &test.Buffer{}
+	err := lgr.AddTarget(
+		target.NewWriterTarget(filter, &format.JSON{DisableTimestamp: true}, jsonBuf, 1000),
+		target.NewWriterTarget(filter, &format.Plain{DisableTimestamp: true, Delim: " | "}, plainBuf, 1000),
+	)
+	if err != nil {
+		t.Error(err)
+	}
+
+	lgr.NewLogger().Infoln("hello", "world")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := NL(`{"level":"info","msg":"hello world"}`)
+	if jsonBuf.String() != want {
+		t.Errorf("JSON does not match: expected %s   got %s", want, jsonBuf.String())
+	}
+
+	// Plain keeps the newline produced by fmt.Sprintln.
+	want = "info | hello world\n | \n"
+	if plainBuf.String() != want {
+		t.Errorf("Plain does not match: expected %q   got %q", want, plainBuf.String())
+	}
+}
+
+func TestJSONSanitizeControl(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true, DisableLevel: true, SanitizeControl: true}
+	buf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	lgr.NewLogger().WithField("user", "bob\x1b[0m\\u001b").Info("red \x1b[31malert\x00\tend\n")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	var rec map[string]string
+	if err := json.Unmarshal([]byte(buf.String()), &rec); err != nil {
+		t.Fatalf("invalid JSON %s: %v", buf.String(), err)
+	}
+	if want := `red \x1b[31malert\x00` + "\t" + `end\n`; rec["msg"] != want {
+		t.Errorf("msg does not match: expected %q   got %q", want, rec["msg"])
+	}
+	// an escaped backslash followed by u001b is not a control character.
+	if want := `bob\x1b[0m\u001b`; rec["user"] != want {
+		t.Errorf("field does not match: expected %q   got %q", want, rec["user"])
+	}
+}
+
+func TestJSONUnwrapErrors(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true, DisableLevel: true, UnwrapErrors: true}
+	buf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	root := errors.New("not found")
+	wrapped := fmt.Errorf("save: %w", fmt.Errorf("open x: %w", root))
+	lgr.NewLogger().WithFields(logr.Fields{"err": wrapped, "plain": root, "none": nil}).Info("failed")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := NL(`{"msg":"failed",` +
+		`"err":{"msg":"save: open x: not found","causes":["open x: not found","not found"],"type":"*errors.errorString"},` +
+		`"none":null,` +
+		`"plain":{"msg":"not found","causes":[],"type":"*errors.errorString"}}`)
+	if buf.String() != want {
+		t.Errorf("JSON does not match: expected %s   got %s", want, buf.String())
+	}
+}
+
+func TestJSONComplex(t *testing.T) {
+	const c128 = complex(1.5, -2.25)
+	const c64 = complex64(complex(3, 0.5))
+
+	tests := []struct {
+		name     string
+		asString bool
+	}{
+		{name: "object", asString: false},
+		{name: "string", asString: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			formatter := &format.JSON{DisableTimestamp: true, ComplexAsString: tt.asString}
+			buf := &test.Buffer{}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+			if err != nil {
+				t.Error(err)
+			}
+
+			lgr.NewLogger().With(logr.Complex128("c128", c128), logr.Complex64("c64", c64)).Info("signal")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Error(err)
+			}
+
+			if tt.asString {
+				want := NL(`{"level":"info","msg":"signal","c128":"(1.5-2.25i)","c64":"(3+0.5i)"}`)
+				if buf.String() != want {
+					t.Errorf("JSON does not match: expected %s   got %s", want, buf.String())
+				}
+				return
+			}
+
+			type complexJSON struct {
+				Real float64 `json:"real"`
+				Imag float64 `json:"imag"`
+			}
+			var rec struct {
+				C128 complexJSON `json:"c128"`
+				C64  complexJSON `json:"c64"`
+			}
+			if err := json.Unmarshal([]byte(buf.String()), &rec); err != nil {
+				t.Fatalf("invalid JSON %s: %v", buf.String(), err)
+			}
+			if got := complex(rec.C128.Real, rec.C128.Imag); got != c128 {
+				t.Errorf("complex128 round trip: expected %v   got %v", c128, got)
+			}
+			if got := complex64(complex(rec.C64.Real, rec.C64.Imag)); got != c64 {
+				t.Errorf("complex64 round trip: expected %v   got %v", c64, got)
+			}
+		})
+	}
+}
+
+func TestMaxFields(t *testing.T) {
+	fields := make(logr.Fields, 10000)
+	for i := 0; i < 10000; i++ {
+		fields[fmt.Sprintf("f%05d", i)] = i
+	}
+	logger := (&logr.Logr{}).NewLogger().WithFields(fields)
+	rec := logr.NewLogRec(logr.Info, logger, "", nil, false)
+
+	t.Run("json", func(t *testing.T) {
+		for _, group := range []string{"", "ctx"} {
+			formatter := &format.JSON{DisableTimestamp: true, MaxFields: 50, KeyContextFields: group}
+			buf, err := formatter.Format(rec, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if group != "" {
+				out = out[group].(map[string]interface{})
+			} else {
+				delete(out, "level")
+				delete(out, "msg")
+			}
+			if len(out) != 51 {
+				t.Errorf("expected 50 fields plus marker, got %d", len(out))
+			}
+			if out[format.FieldsTruncatedKey] != float64(9950) {
+				t.Errorf("unexpected truncation marker %v", out[format.FieldsTruncatedKey])
+			}
+			if _, ok := out["f00049"]; !ok {
+				t.Error("expected first 50 fields after sorting")
+			}
+		}
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		formatter := &format.Plain{DisableTimestamp: true, MaxFields: 50}
+		buf, err := formatter.Format(rec, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if got := strings.Count(out, "="); got != 51 {
+			t.Errorf("expected 50 fields plus marker, got %d", got)
+		}
+		if !strings.Contains(out, "f00049=49 _fields_truncated=9950\n") {
+			t.Errorf("unexpected output tail: %s", out[len(out)-60:])
+		}
+	})
+}
+
 func reverseSort(fields logr.Fields) []format.ContextField {
 	keys := make([]string, 0, len(fields))
 	for k := range fields {
@@ -178,3 +668,186 @@ func reverseSort(fields logr.Fields) []format.ContextField {
 func NL(s string) string {
 	return s + "\n"
 }
+
+func TestOmitEmptyMsg(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true, OmitEmptyMsg: true},
+			want: NL(`{"level":"info","event":"login"}`) +
+				NL(`{"level":"info","event":"logout"}`) +
+				NL(`{"level":"info","msg":"done","event":"exit"}`),
+		},
+		{
+			name:      "json keep empty",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want: NL(`{"level":"info","msg":"","event":"login"}`) +
+				NL(`{"level":"info","msg":"","event":"logout"}`) +
+				NL(`{"level":"info","msg":"done","event":"exit"}`),
+		},
+		{
+			name:      "json disable msg",
+			formatter: &format.JSON{DisableTimestamp: true, DisableMsg: true, OmitEmptyMsg: true},
+			want: NL(`{"level":"info","event":"login"}`) +
+				NL(`{"level":"info","event":"logout"}`) +
+				NL(`{"level":"info","event":"exit"}`),
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | ", OmitEmptyMsg: true},
+			want:      "info | event=login\ninfo | event=logout\ninfo | done | event=exit\n",
+		},
+		{
+			name:      "plain keep empty",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want:      "info |  | event=login\ninfo | \n | event=logout\ninfo | done | event=exit\n",
+		},
+		{
+			name:      "plain disable msg",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | ", DisableMsg: true, OmitEmptyMsg: true},
+			want:      "info | event=login\ninfo | event=logout\ninfo | event=exit\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logger := lgr.NewLogger()
+			logger.WithField("event", "login").Info("")
+			logger.WithField("event", "logout").Infoln()
+			logger.WithField("event", "exit").Info("done")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected: %q;  got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	lgr := &logr.Logr{}
+	logger := lgr.NewLogger().WithFields(logr.Fields{
+		"ratio": 2.0 / 3.0,
+		"count": 42,
+		"ok":    true,
+		"name":  `<"Wiggin">`,
+		"nil":   nil,
+		"tags":  map[string]interface{}{"b": 1.5, "a": "x"},
+		"c":     logr.Complex128("c", complex(1, 2)),
+	})
+	rec := logr.NewLogRec(logr.Info, logger, "", nil, false)
+
+	encode := func(t *testing.T, encoder format.Encoder) string {
+		formatter := &format.JSON{DisableTimestamp: true, Encoder: encoder}
+		buf, err := formatter.Format(rec, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	t.Run("std matches gojay", func(t *testing.T) {
+		var want, got map[string]interface{}
+		if err := json.Unmarshal([]byte(encode(t, nil)), &want); err != nil {
+			t.Fatal(err)
+		}
+		out := encode(t, &format.StdEncoder{})
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("invalid JSON %q: %v", out, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("std encoder output %v does not match gojay %v", got, want)
+		}
+		if !strings.Contains(out, `"name":"<\"Wiggin\">"`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("custom float format", func(t *testing.T) {
+		encoder := &format.StdEncoder{
+			AppendFloat: func(dst []byte, v float64, bitSize int) []byte {
+				return strconv.AppendFloat(dst, v, 'f', 2, bitSize)
+			},
+		}
+		out := encode(t, encoder)
+		for _, want := range []string{`"ratio":0.67`, `"tags":{"a":"x","b":1.50}`, `"c":{"real":1.00,"imag":2.00}`} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %s in output: %s", want, out)
+			}
+		}
+	})
+}
+
+func TestJSONKeys(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		formatter *format.JSON
+		want      string
+	}{
+		{
+			name:      "datadog",
+			formatter: &format.JSON{Keys: format.DatadogKeys()},
+			want: `{"timestamp":"2024-03-01T12:30:00Z","status":"info","message":"one"}` + "\n" +
+				`{"timestamp":"2024-03-01T12:30:00Z","status":"warning","message":"two"}` + "\n",
+		},
+		{
+			name:      "gcp",
+			formatter: &format.JSON{Keys: format.GCPKeys()},
+			want: `{"timestamp":"2024-03-01T12:30:00Z","severity":"INFO","message":"one"}` + "\n" +
+				`{"timestamp":"2024-03-01T12:30:00Z","severity":"WARNING","message":"two"}` + "\n",
+		},
+		{
+			name:      "ecs",
+			formatter: &format.JSON{Keys: format.ECSKeys()},
+			want: `{"@timestamp":"2024-03-01T12:30:00Z","log.level":"info","message":"one"}` + "\n" +
+				`{"@timestamp":"2024-03-01T12:30:00Z","log.level":"warn","message":"two"}` + "\n",
+		},
+		{
+			name:      "individual keys take precedence",
+			formatter: &format.JSON{Keys: format.GCPKeys(), KeyMsg: "msg", TimestampFormat: "15:04"},
+			want: `{"timestamp":"12:30","severity":"INFO","msg":"one"}` + "\n" +
+				`{"timestamp":"12:30","severity":"WARNING","msg":"two"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{Clock: func() time.Time { return ts }}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			buf := &test.Buffer{}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Error(err)
+			}
+
+			logger := lgr.NewLogger()
+			logger.Info("one")
+			logger.Warn("two")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Error(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("JSON does not match: expected %s   got %s", tt.want, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package format
+
+import (
+	"time"
+
+	"github.com/mattermost/logr"
+)
+
+// KeyNames sets the key names of all standard fields at once, along with
+// level names and timestamp format, to match the schema of a log ingestion
+// service. See `JSON.Keys` and the presets `DatadogKeys`, `GCPKeys` and
+// `ECSKeys`. Empty values leave the JSON formatter's defaults unchanged.
+type KeyNames struct {
+	Timestamp     string
+	Level         string
+	Msg           string
+	Sequence      string
+	Stacktrace    string
+	AllStacks     string
+	ContextFields string
+
+	// LevelNames maps level IDs to the names output in the level field, e.g.
+	// `WARNING` for Warn. Levels not mapped are output using their own name.
+	LevelNames map[logr.LevelID]string
+
+	// TimestampFormat is used when `JSON.TimestampFormat` is empty.
+	TimestampFormat string
+}
+
+// DatadogKeys returns key names and level names matching Datadog's reserved
+// log attributes, so the level and message are recognized without a custom
+// pipeline.
+func DatadogKeys() *KeyNames {
+	return &KeyNames{
+		Timestamp:  "timestamp",
+		Level:      "status",
+		Msg:        "message",
+		Stacktrace: "error.stack",
+		LevelNames: map[logr.LevelID]string{
+			logr.Panic.ID: "emergency",
+			logr.Fatal.ID: "critical",
+			logr.Warn.ID:  "warning",
+		},
+		TimestampFormat: time.RFC3339Nano,
+	}
+}
+
+// GCPKeys returns key names and level names matching Google Cloud Logging's
+// structured logging fields, with levels mapped to its severities.
+func GCPKeys() *KeyNames {
+	return &KeyNames{
+		Timestamp:  "timestamp",
+		Level:      "severity",
+		Msg:        "message",
+		Stacktrace: "stack_trace",
+		LevelNames: map[logr.LevelID]string{
+			logr.Panic.ID: "EMERGENCY",
+			logr.Fatal.ID: "CRITICAL",
+			logr.Error.ID: "ERROR",
+			logr.Warn.ID:  "WARNING",
+			logr.Info.ID:  "INFO",
+			logr.Debug.ID: "DEBUG",
+			logr.Trace.ID: "DEBUG",
+		},
+		TimestampFormat: time.RFC3339Nano,
+	}
+}
+
+// ECSKeys returns key names matching the Elastic Common Schema. Level names
+// are output unchanged.
+func ECSKeys() *KeyNames {
+	return &KeyNames{
+		Timestamp:       "@timestamp",
+		Level:           "log.level",
+		Msg:             "message",
+		Sequence:        "event.sequence",
+		Stacktrace:      "error.stack_trace",
+		TimestampFormat: time.RFC3339Nano,
+	}
+}
+
+// apply sets any of the JSON formatter's key names and timestamp format that
+// are empty to the values of these key names.
+func (k *KeyNames) apply(j *JSON) {
+	setIfEmpty(&j.KeyTimestamp, k.Timestamp)
+	setIfEmpty(&j.KeyLevel, k.Level)
+	setIfEmpty(&j.KeyMsg, k.Msg)
+	setIfEmpty(&j.KeySequence, k.Sequence)
+	setIfEmpty(&j.KeyStacktrace, k.Stacktrace)
+	setIfEmpty(&j.KeyAllStacks, k.AllStacks)
+	setIfEmpty(&j.KeyContextFields, k.ContextFields)
+	setIfEmpty(&j.TimestampFormat, k.TimestampFormat)
+}
+
+// levelName returns the name mapped for the level, if any.
+func (k *KeyNames) levelName(lvl logr.Level) (string, bool) {
+	if k == nil {
+		return "", false
+	}
+	name, ok := k.LevelNames[lvl.ID]
+	return name, ok
+}
+
+func setIfEmpty(s *string, val string) {
+	if *s == "" {
+		*s = val
+	}
+}
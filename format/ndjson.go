@@ -0,0 +1,78 @@
+package format
+
+import (
+	"bytes"
+
+	"github.com/mattermost/logr"
+)
+
+// NDJSON formats log records as newline-delimited JSON (http://ndjson.org),
+// for streaming APIs and line-oriented log shippers. It accepts all the
+// options of JSON except Pretty and Indent, which are ignored.
+//
+// Each log record is output as exactly one compact JSON object followed by a
+// single `\n`, unless DisableNewline is true. The object never contains a
+// raw newline or carriage return: those within strings are always escaped,
+// and any whitespace newlines, e.g. from an embedded JSON field value, are
+// removed. Each line can therefore be parsed independently.
+type NDJSON struct {
+	JSON
+
+	// DisableNewline omits the trailing newline so the caller controls
+	// framing and flushing, e.g. an HTTP handler writing each record
+	// followed by `\n` and a flush.
+	DisableNewline bool
+}
+
+// Format converts a log record to bytes in NDJSON format.
+func (n *NDJSON) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	if buf == nil {
+		buf = &bytes.Buffer{}
+	}
+	start := buf.Len()
+
+	buf, err := n.JSON.format(rec, stacktrace, buf, false)
+	if err != nil {
+		return nil, err
+	}
+	stripNewlines(buf, start)
+
+	if !n.DisableNewline {
+		buf.WriteByte('\n')
+	}
+	return buf, nil
+}
+
+// FormatAppend appends the formatted log record to dst and returns the extended
+// slice. dst may be nil. On error dst is returned unchanged.
+func (n *NDJSON) FormatAppend(rec *logr.LogRec, stacktrace bool, dst []byte) ([]byte, error) {
+	return formatAppend(n, rec, stacktrace, dst)
+}
+
+// ContentType returns the MIME type of the formatted output.
+func (n *NDJSON) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// FileExt returns a file extension suitable for the formatted output.
+func (n *NDJSON) FileExt() string {
+	return ".ndjson"
+}
+
+// stripNewlines removes raw `\r` and `\n` from the JSON written to buf after
+// offset start. These can only appear as whitespace between JSON tokens,
+// never within strings, so removing them yields equivalent JSON.
+func stripNewlines(buf *bytes.Buffer, start int) {
+	data := buf.Bytes()[start:]
+	if bytes.IndexAny(data, "\r\n") < 0 {
+		return
+	}
+	w := 0
+	for _, c := range data {
+		if c != '\r' && c != '\n' {
+			data[w] = c
+			w++
+		}
+	}
+	buf.Truncate(start + w)
+}
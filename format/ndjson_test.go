@@ -0,0 +1,96 @@
+package format_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/francoispqt/gojay"
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+)
+
+func TestNDJSONConformance(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+	// Pretty must be ignored.
+	formatter := &format.NDJSON{JSON: format.JSON{Pretty: true}}
+	buf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	embedded := gojay.EmbeddedJSON("{\n  \"a\": 1,\r\n  \"b\": [\n1, 2]\n}")
+	logger := lgr.NewLogger().WithFields(logr.Fields{
+		"multiline": "line1\nline2\r\nline3",
+		"embedded":  &embedded,
+	})
+	logger.Info("plain")
+	logger.Info("msg with\nnewline")
+	logger.Infoln("println", "style")
+	logger.Error("with stack trace")
+	logger.With(logr.Reflect("reflect", map[string][]string{"k": {"v\n"}})).Info("reflect")
+	const records = 5
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	output := buf.String()
+	if !strings.HasSuffix(output, "}\n") {
+		t.Errorf("output must end with a single newline: %q", output)
+	}
+
+	var count int
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		count++
+		line := scanner.Text()
+		if strings.ContainsAny(line, "\r") {
+			t.Errorf("line %d contains a carriage return: %q", count, line)
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			t.Errorf("line %d is not a JSON object: %v: %q", count, err, line)
+		}
+	}
+	if count != records {
+		t.Errorf("expected %d lines, got %d", records, count)
+	}
+}
+
+func TestNDJSONDisableNewline(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.NDJSON{JSON: format.JSON{DisableTimestamp: true}, DisableNewline: true}
+
+	var got []string
+	fn := func(formatted []byte, rec *logr.LogRec) {
+		got = append(got, string(formatted))
+	}
+	err := lgr.AddTarget(target.NewFuncTarget(filter, formatter, fn, 100))
+	if err != nil {
+		t.Error(err)
+	}
+
+	lgr.NewLogger().Info("one")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := `{"level":"info","msg":"one"}`
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("expected: %q;  got: %q", want, got)
+	}
+	if ct := formatter.ContentType(); ct != "application/x-ndjson" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+}
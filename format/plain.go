@@ -3,12 +3,16 @@ package format
 import (
 	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/mattermost/logr"
 )
 
 // Plain is the simplest formatter, outputting only text with
-// no colors.
+// optional level colors.
 type Plain struct {
 	// DisableTimestamp disables output of timestamp field.
 	DisableTimestamp bool
@@ -21,15 +25,96 @@ type Plain struct {
 	// DisableStacktrace disables output of stack trace.
 	DisableStacktrace bool
 
+	// TrimStackPathPrefix, when not empty, is removed from the start of each
+	// stack frame's file path, e.g. a build machine's module root, so frames
+	// show `pkg/file.go` rather than the full path. This keeps stack traces
+	// shorter and avoids leaking build environment details.
+	TrimStackPathPrefix string
+
+	// MaxMsgBytes, when greater than zero, truncates messages longer than this
+	// many bytes, e.g. due to an interpolated request body, appending
+	// MsgTruncatedMarker and the original length, e.g.
+	// `…[truncated, bytes=1048576]`. Truncation never splits a UTF-8 character.
+	MaxMsgBytes int
+
+	// OmitEmptyMsg skips the msg column, including its delimiter, when the
+	// message is empty, such as for records that carry only fields to mark an
+	// event. Has no effect when DisableMsg is true, which always skips it.
+	OmitEmptyMsg bool
+
 	// Delim is an optional delimiter output between each log field.
 	// Defaults to a single space.
 	Delim string
 
-	// TimestampFormat is an optional format for timestamps. If empty
-	// then DefTimestampFormat is used.
+	// KeyValueSep is output between each context field key and its value.
+	// Defaults to `=`.
+	KeyValueSep string
+
+	// KeySequence is the key of the sequence number, output before the context
+	// fields when `logr.Logr.EnableSequence` is true. Defaults to
+	// DefSequenceKey.
+	KeySequence string
+
+	// QuoteKeys quotes context field keys, in the same way as string values,
+	// when they are empty or contain whitespace, control characters, double
+	// quotes or the KeyValueSep, so the output can be parsed unambiguously.
+	// Defaults to false.
+	QuoteKeys bool
+
+	// TimestampFormat is an optional format for timestamps, used for both the
+	// record timestamp and any time.Time context fields. If empty then
+	// DefTimestampFormat is used.
 	TimestampFormat string
+
+	// TimestampMinLevel, when set, outputs the timestamp only for log records
+	// at this level or more severe, e.g. `logr.Warn` timestamps errors and
+	// warnings but not info, debug or trace records. The zero Level outputs the
+	// timestamp at all levels. Has no effect when DisableTimestamp is true.
+	TimestampMinLevel logr.Level
+
+	// LevelWidth, when greater than zero, pads or truncates level names to
+	// exactly this many characters so that columns align.
+	LevelWidth int
+
+	// LevelUppercase outputs level names in upper case, e.g. `ERROR`.
+	LevelUppercase bool
+
+	// EnableColor colors level names using ANSI escapes, per LevelColors,
+	// for output to terminals. Defaults to false.
+	EnableColor bool
+
+	// LevelColors maps levels, including custom levels, to the color used for
+	// their names when EnableColor is true. Levels without an entry are not
+	// colored. Defaults to `DefaultLevelColors()` when nil.
+	LevelColors map[logr.Level]Color
+
+	// EscapeHTML determines if the characters `<`, `>` and `&` are replaced
+	// with the HTML entities `&lt;`, `&gt;` and `&amp;`, making the output
+	// safe to display in HTML dashboards. Defaults to false.
+	EscapeHTML bool
+
+	// MaxFields, when greater than zero, limits the number of context fields
+	// output per log record. When exceeded, the first MaxFields fields, in
+	// output order, are output followed by a FieldsTruncatedKey field
+	// containing the number omitted.
+	MaxFields int
+
+	// PreserveFieldOrder outputs context fields in the order of
+	// `logr.LogRec.EachFieldInOrder`, so typed fields added via
+	// `logr.Logger.With` keep the order they were added in rather than being
+	// sorted by key. Defaults to false.
+	PreserveFieldOrder bool
+
+	// SanitizeControl replaces C0 control characters other than tab, such as
+	// ANSI escapes, null bytes and newlines, in the message and context fields
+	// with their escape sequences, e.g. `\x1b`. This prevents user supplied
+	// values from corrupting terminals and log viewers or injecting fake log
+	// lines. Defaults to false.
+	SanitizeControl bool
 }
 
+var htmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
 // Format converts a log record to bytes.
 func (p *Plain) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
 	delim := p.Delim
@@ -39,37 +124,201 @@ func (p *Plain) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*b
 	if buf == nil {
 		buf = &bytes.Buffer{}
 	}
+	start := buf.Len()
 
 	timestampFmt := p.TimestampFormat
 	if timestampFmt == "" {
 		timestampFmt = logr.DefTimestampFormat
 	}
 
-	if !p.DisableTimestamp {
+	if !p.DisableTimestamp && timestampEnabled(rec.Level(), p.TimestampMinLevel) {
 		var arr [128]byte
 		tbuf := rec.Time().AppendFormat(arr[:0], timestampFmt)
 		buf.Write(tbuf)
 		buf.WriteString(delim)
 	}
 	if !p.DisableLevel {
-		fmt.Fprintf(buf, "%v%s", rec.Level().Name, delim)
+		name := levelName(rec.Level(), p.LevelUppercase, p.LevelWidth)
+		if p.EnableColor {
+			writeColored(buf, name, levelColor(p.LevelColors, rec.Level()))
+		} else {
+			buf.WriteString(name)
+		}
+		buf.WriteString(delim)
 	}
-	if !p.DisableMsg {
-		fmt.Fprint(buf, rec.Msg(), delim)
+	if !p.DisableMsg && !(p.OmitEmptyMsg && isEmptyMsg(rec)) {
+		msg := rec.Msg()
+		if p.MaxMsgBytes > 0 && rec.Newline() {
+			msg = truncateMsg(strings.TrimSuffix(msg, "\n"), p.MaxMsgBytes) + "\n"
+		} else {
+			msg = truncateMsg(msg, p.MaxMsgBytes)
+		}
+		if p.SanitizeControl {
+			if rec.Newline() {
+				msg = sanitizeControl(strings.TrimSuffix(msg, "\n")) + "\n"
+			} else {
+				msg = sanitizeControl(msg)
+			}
+		}
+		fmt.Fprint(buf, msg, delim)
 	}
 	if !p.DisableContext {
-		ctx := rec.Fields()
-		if len(ctx) > 0 {
-			logr.WriteFields(buf, ctx, " ")
+		hasFields := rec.HasFields()
+		if seq, ok := rec.Sequence(); ok {
+			p.writeSequence(buf, seq)
+			if hasFields {
+				buf.WriteString(" ")
+			}
+		}
+		if hasFields {
+			ctxStart := buf.Len()
+			ff := logr.FieldsFormat{Separator: " ", KeyValueSep: p.KeyValueSep, QuoteKeys: p.QuoteKeys}
+			var written, truncated int
+			sep := ""
+			write := func(key string, val interface{}) {
+				if p.MaxFields > 0 && written >= p.MaxFields {
+					truncated++
+					return
+				}
+				logr.WriteFieldFormat(buf, key, formatTimeValue(val, timestampFmt), sep, ff)
+				sep = ff.Separator
+				written++
+			}
+			if p.PreserveFieldOrder {
+				rec.EachFieldInOrder(write)
+			} else {
+				rec.EachField(write)
+			}
+			if truncated > 0 {
+				kvSep := p.KeyValueSep
+				if kvSep == "" {
+					kvSep = "="
+				}
+				fmt.Fprintf(buf, " %s%s%d", FieldsTruncatedKey, kvSep, truncated)
+			}
+			if p.SanitizeControl {
+				sanitizeControlBuf(buf, ctxStart)
+			}
 		}
 	}
 	if stacktrace && !p.DisableStacktrace {
 		frames := rec.StackFrames()
 		if len(frames) > 0 {
 			buf.WriteString("\n")
-			logr.WriteStacktrace(buf, rec.StackFrames())
+			logr.WriteStacktrace(buf, trimStackPaths(frames, p.TrimStackPathPrefix))
+		}
+		if allStacks := rec.AllStacks(); len(allStacks) > 0 {
+			buf.WriteString("\n")
+			buf.Write(allStacks)
 		}
 	}
+	if p.EscapeHTML {
+		escaped := htmlReplacer.Replace(string(buf.Bytes()[start:]))
+		buf.Truncate(start)
+		buf.WriteString(escaped)
+	}
 	buf.WriteString("\n")
 	return buf, nil
 }
+
+// writeSequence writes the sequence number as a key/value pair.
+func (p *Plain) writeSequence(buf *bytes.Buffer, seq int64) {
+	key := p.KeySequence
+	if key == "" {
+		key = logr.DefSequenceKey
+	}
+	kvSep := p.KeyValueSep
+	if kvSep == "" {
+		kvSep = "="
+	}
+	buf.WriteString(key)
+	buf.WriteString(kvSep)
+	var arr [20]byte
+	buf.Write(strconv.AppendInt(arr[:0], seq, 10))
+}
+
+// FormatAppend appends the formatted log record to dst and returns the extended
+// slice, for targets that maintain their own buffer, e.g. for checksums or
+// hash chaining. dst may be nil.
+func (p *Plain) FormatAppend(rec *logr.LogRec, stacktrace bool, dst []byte) ([]byte, error) {
+	return formatAppend(p, rec, stacktrace, dst)
+}
+
+// ContentType returns the MIME type of the formatted output.
+func (p *Plain) ContentType() string {
+	return "text/plain"
+}
+
+// FileExt returns a file extension suitable for the formatted output.
+func (p *Plain) FileExt() string {
+	return ".log"
+}
+
+// timestampEnabled returns true if the timestamp is output for the level,
+// given the minimum level, where the zero Level means all levels.
+func timestampEnabled(lvl logr.Level, min logr.Level) bool {
+	return min == (logr.Level{}) || lvl.ID <= min.ID
+}
+
+// isEmptyMsg returns true if the log record's message is empty, ignoring the
+// trailing newline added by the LoglnXXX methods.
+func isEmptyMsg(rec *logr.LogRec) bool {
+	msg := rec.Msg()
+	if rec.Newline() {
+		msg = strings.TrimSuffix(msg, "\n")
+	}
+	return msg == ""
+}
+
+// truncateMsg truncates msg to at most max bytes, on a UTF-8 character
+// boundary, appending MsgTruncatedMarker and the original length. msg is
+// returned unchanged if max is not greater than zero.
+func truncateMsg(msg string, max int) string {
+	if max <= 0 || len(msg) <= max {
+		return msg
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + MsgTruncatedMarker + strconv.Itoa(len(msg)) + "]"
+}
+
+// levelName returns the level name for output, optionally in upper case and
+// padded or truncated to width characters when width is greater than zero.
+func levelName(lvl logr.Level, upper bool, width int) string {
+	name := lvl.Name
+	if upper {
+		name = strings.ToUpper(name)
+	}
+	if width <= 0 {
+		return name
+	}
+	if runes := []rune(name); len(runes) > width {
+		return string(runes[:width])
+	}
+	return fmt.Sprintf("%-*s", width, name)
+}
+
+// formatTimeValue returns val formatted using layout if it is a time.Time or
+// non-nil *time.Time, otherwise val as is.
+func formatTimeValue(val interface{}, layout string) interface{} {
+	switch v := val.(type) {
+	case time.Time:
+		return v.Format(layout)
+	case *time.Time:
+		if v != nil {
+			return v.Format(layout)
+		}
+	}
+	return val
+}
+
+// formatAppend formats the log record into a buffer that appends to dst.
+func formatAppend(f logr.Formatter, rec *logr.LogRec, stacktrace bool, dst []byte) ([]byte, error) {
+	buf, err := f.Format(rec, stacktrace, bytes.NewBuffer(dst))
+	if err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
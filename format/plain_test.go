@@ -1,8 +1,11 @@
 package format_test
 
 import (
+	"path"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mattermost/logr"
 	"github.com/mattermost/logr/format"
@@ -40,3 +43,417 @@ func TestPlain(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestPlainEscapeHTML(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | ", EscapeHTML: true}
+	target := target.NewWriterTarget(filter, formatter, buf, 1000)
+	err := lgr.AddTarget(target)
+	if err != nil {
+		t.Error(err)
+	}
+
+	lgr.NewLogger().WithField("tag", "<i>").Error("<b>bold</b> & more")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	got := buf.String()
+	want := "error | &lt;b&gt;bold&lt;/b&gt; &amp; more | tag=\"&lt;i&gt;\"\n"
+	if got != want {
+		t.Errorf("expected: \"%s\";  got:\"%s\"", want, got)
+	}
+}
+
+func TestPlainLevelWidth(t *testing.T) {
+	tests := []struct {
+		name      string
+		formatter *format.Plain
+		want      string
+	}{
+		{name: "default", formatter: &format.Plain{}, want: "warn|msg|\nerror|msg|\n"},
+		{name: "padded", formatter: &format.Plain{LevelWidth: 5}, want: "warn |msg|\nerror|msg|\n"},
+		{name: "truncated", formatter: &format.Plain{LevelWidth: 3}, want: "war|msg|\nerr|msg|\n"},
+		{name: "uppercase", formatter: &format.Plain{LevelWidth: 5, LevelUppercase: true}, want: "WARN |msg|\nERROR|msg|\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Warn, Stacktrace: logr.Panic}
+			tt.formatter.DisableTimestamp = true
+			tt.formatter.Delim = "|"
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Error(err)
+			}
+
+			logger := lgr.NewLogger()
+			logger.Warn("msg")
+			logger.Error("msg")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Error(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected: %q;  got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPlainLevelColors(t *testing.T) {
+	loginLevel := logr.Level{ID: 100, Name: "login"}
+	auditLevel := logr.Level{ID: 101, Name: "audit"}
+
+	colors := format.DefaultLevelColors()
+	colors[loginLevel] = format.BrightBlue
+	colors[logr.Warn] = "1;35"
+
+	tests := []struct {
+		name      string
+		formatter *format.Plain
+		want      string
+	}{
+		{name: "disabled", formatter: &format.Plain{LevelColors: colors}, want: "error|msg|\nwarn|msg|\nlogin|msg|\naudit|msg|\n"},
+		{name: "default palette", formatter: &format.Plain{EnableColor: true},
+			want: "\x1b[31merror\x1b[0m|msg|\n\x1b[33mwarn\x1b[0m|msg|\nlogin|msg|\naudit|msg|\n"},
+		{name: "custom palette", formatter: &format.Plain{EnableColor: true, LevelColors: colors},
+			want: "\x1b[31merror\x1b[0m|msg|\n\x1b[1;35mwarn\x1b[0m|msg|\n\x1b[94mlogin\x1b[0m|msg|\naudit|msg|\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.CustomFilter{}
+			filter.Add(logr.Error, logr.Warn, loginLevel, auditLevel)
+			tt.formatter.DisableTimestamp = true
+			tt.formatter.Delim = "|"
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Error(err)
+			}
+
+			logger := lgr.NewLogger()
+			logger.Log(logr.Error, "msg")
+			logger.Log(logr.Warn, "msg")
+			logger.Log(loginLevel, "msg")
+			logger.Log(auditLevel, "msg")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Error(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected: %q;  got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+type rawString string
+
+func (s rawString) String() string {
+	return string(s)
+}
+
+func TestPlainSanitizeControl(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, DisableLevel: true, Delim: " | ", SanitizeControl: true}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	logger := lgr.NewLogger().WithFields(logr.Fields{"user": "bob\x1b[0m", "raw": rawString("a\x00b\tc")})
+	logger.Info("red \x1b[31malert\x00\nfake line")
+	logger.Infoln("fake\nline")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := `red \x1b[31malert\x00\nfake line | raw=a\x00b` + "\tc" + ` user="bob\x1b[0m"` + "\n" +
+		`fake\nline` + "\n" + ` | raw=a\x00b` + "\tc" + ` user="bob\x1b[0m"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected: %q;  got: %q", want, got)
+	}
+}
+
+func TestTimeFieldLayout(t *testing.T) {
+	const layout = "02 Jan 2006 15:04:05"
+	ts := time.Date(2020, 5, 17, 10, 30, 15, 0, time.UTC)
+	started := ts.Add(-time.Hour)
+	logger := (&logr.Logr{}).NewLogger().WithFields(logr.Fields{"started": started, "ptr": &started})
+	rec := logr.NewLogRec(logr.Info, logger, "", nil, false).WithTime(ts)
+
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{TimestampFormat: layout, DisableMsg: true, DisableLevel: true},
+			want:      `{"timestamp":"17 May 2020 10:30:15","ptr":"17 May 2020 09:30:15","started":"17 May 2020 09:30:15"}` + "\n",
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{TimestampFormat: layout, DisableMsg: true, DisableLevel: true, Delim: " | "},
+			want:      `17 May 2020 10:30:15 | ptr="17 May 2020 09:30:15" started="17 May 2020 09:30:15"` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := tt.formatter.Format(rec, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected: %q;  got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTimestampMinLevel(t *testing.T) {
+	ts := time.Date(2020, 5, 17, 10, 30, 15, 0, time.UTC)
+	const layout = "15:04:05"
+
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{TimestampFormat: layout, TimestampMinLevel: logr.Warn},
+			want: NL(`{"timestamp":"10:30:15","level":"error","msg":"e"}`) +
+				NL(`{"timestamp":"10:30:15","level":"warn","msg":"w"}`) +
+				NL(`{"level":"info","msg":"i"}`) +
+				NL(`{"level":"debug","msg":"d"}`) +
+				NL(`{"level":"trace","msg":"t"}`),
+		},
+		{
+			name:      "json all levels",
+			formatter: &format.JSON{TimestampFormat: layout, DisableLevel: true},
+			want: NL(`{"timestamp":"10:30:15","msg":"e"}`) +
+				NL(`{"timestamp":"10:30:15","msg":"w"}`) +
+				NL(`{"timestamp":"10:30:15","msg":"i"}`) +
+				NL(`{"timestamp":"10:30:15","msg":"d"}`) +
+				NL(`{"timestamp":"10:30:15","msg":"t"}`),
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{TimestampFormat: layout, TimestampMinLevel: logr.Error, Delim: " | "},
+			want:      "10:30:15 | error | e | \nwarn | w | \ninfo | i | \ndebug | d | \ntrace | t | \n",
+		},
+		{
+			name:      "plain disabled",
+			formatter: &format.Plain{TimestampFormat: layout, TimestampMinLevel: logr.Error, DisableTimestamp: true, Delim: " | "},
+			want:      "error | e | \nwarn | w | \ninfo | i | \ndebug | d | \ntrace | t | \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{Clock: func() time.Time { return ts }}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Trace, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logger := lgr.NewLogger()
+			logger.Error("e")
+			logger.Warn("w")
+			logger.Info("i")
+			logger.Debug("d")
+			logger.Trace("t")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected: %q;  got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPlainFieldKeys(t *testing.T) {
+	fields := logr.Fields{
+		"user id":  "bob",
+		"a=b":      1,
+		"ok":       true,
+		`say"hi"`:  "hello world",
+		"tab\tkey": logr.Map("nested key", map[string]interface{}{"inner key": 2}),
+	}
+
+	tests := []struct {
+		name      string
+		formatter *format.Plain
+		want      string
+	}{
+		{
+			name:      "default",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want:      "info | keys | a=b=1 ok=true say\"hi\"=\"hello world\" tab\tkey={inner key=2} user id=bob\n",
+		},
+		{
+			name:      "quote keys",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | ", QuoteKeys: true},
+			want:      "info | keys | \"a=b\"=1 ok=true \"say\\\"hi\\\"\"=\"hello world\" \"tab\\tkey\"={\"inner key\"=2} \"user id\"=bob\n",
+		},
+		{
+			name:      "key value separator",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | ", QuoteKeys: true, KeyValueSep: ":"},
+			want:      "info | keys | a=b:1 ok:true \"say\\\"hi\\\"\":\"hello world\" \"tab\\tkey\":{\"inner key\":2} \"user id\":bob\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lgr.NewLogger().WithFields(fields).Info("keys")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected: %q;  got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMaxMsgBytes(t *testing.T) {
+	// 1MB of two byte characters, so an odd cap falls mid-character.
+	msg := strings.Repeat("é", 512*1024)
+
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true, MaxMsgBytes: 11},
+			want:      NL(`{"level":"info","msg":"ééééé…[truncated, bytes=1048576]","id":1}`),
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | ", MaxMsgBytes: 11},
+			want:      "info | ééééé…[truncated, bytes=1048576] | id=1\n",
+		},
+		{
+			name:      "plain under cap",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | ", MaxMsgBytes: len(msg)},
+			want:      "info | " + msg + " | id=1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lgr.NewLogger().WithField("id", 1).Info(msg)
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := buf.String(); got != tt.want {
+				if len(got) > 200 {
+					got = got[:200]
+				}
+				t.Errorf("expected: %.200q;  got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTrimStackPathPrefix(t *testing.T) {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("cannot determine test file path")
+	}
+	// the module root, e.g. `/home/ci/go/src/github.com/mattermost/logr`.
+	prefix := path.Dir(path.Dir(file))
+
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true, TrimStackPathPrefix: prefix},
+			want:      `"File":"format/plain_test.go"`,
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | ", TrimStackPathPrefix: prefix},
+			want:      "\n      format/plain_test.go:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Error}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lgr.NewLogger().Error("stack")
+
+			err = lgr.Shutdown()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := buf.String()
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("expected output to contain %q;  got: %q", tt.want, got)
+			}
+			if strings.Contains(got, prefix+"/format/") {
+				t.Errorf("expected prefix %q to be trimmed;  got: %q", prefix, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package format
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// isUnsafeControl returns true for C0 control characters other than tab.
+func isUnsafeControl(r rune) bool {
+	return r < 0x20 && r != '\t'
+}
+
+// controlEscape returns the Go escape sequence for a control character,
+// e.g. `\x1b` or `\n`.
+func controlEscape(r rune) string {
+	q := strconv.QuoteRune(r)
+	return q[1 : len(q)-1]
+}
+
+// sanitizeControl replaces C0 control characters, other than tab, in s with
+// their escape sequences so they cannot corrupt terminals or inject lines.
+func sanitizeControl(s string) string {
+	if strings.IndexFunc(s, isUnsafeControl) < 0 {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if isUnsafeControl(r) {
+			sb.WriteString(controlEscape(r))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// sanitizeControlBuf applies sanitizeControl to the bytes written to buf
+// after offset start.
+func sanitizeControlBuf(buf *bytes.Buffer, start int) {
+	data := buf.Bytes()[start:]
+	if bytes.IndexFunc(data, isUnsafeControl) < 0 {
+		return
+	}
+	sanitized := sanitizeControl(string(data))
+	buf.Truncate(start)
+	buf.WriteString(sanitized)
+}
+
+// sanitizeControlJSON rewrites JSON escapes for C0 control characters, other
+// than tab, in the JSON written to buf after offset start, so the decoded
+// string contains the visible escape sequence (e.g. `\x1b`) instead of the
+// control character itself. Raw control characters cannot appear in valid
+// JSON so only escape sequences need to be considered.
+func sanitizeControlJSON(buf *bytes.Buffer, start int) {
+	data := buf.Bytes()[start:]
+	if bytes.IndexByte(data, '\\') < 0 {
+		return
+	}
+
+	out := make([]byte, 0, len(data)+16)
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c != '\\' || i+1 >= len(data) {
+			out = append(out, c)
+			continue
+		}
+		var r rune = -1
+		n := 2 // length of the escape sequence
+		switch data[i+1] {
+		case 'n':
+			r = '\n'
+		case 'r':
+			r = '\r'
+		case 'b':
+			r = '\b'
+		case 'f':
+			r = '\f'
+		case 'u':
+			if i+6 <= len(data) {
+				if v, err := strconv.ParseUint(string(data[i+2:i+6]), 16, 16); err == nil {
+					r = rune(v)
+					n = 6
+				}
+			}
+		}
+		if r >= 0 && isUnsafeControl(r) {
+			// escaped backslash followed by the visible escape sequence.
+			out = append(out, '\\', '\\')
+			out = append(out, controlEscape(r)[1:]...)
+		} else {
+			out = append(out, data[i:i+n]...)
+		}
+		i += n - 1
+	}
+	buf.Truncate(start)
+	buf.Write(out)
+}
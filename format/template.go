@@ -0,0 +1,147 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/mattermost/logr"
+)
+
+// Template formats log records using a `text/template`, allowing any layout.
+// The template is executed with a TemplateRec for each log record, e.g.
+//
+//	{{.Time}} {{.Level}} {{.Msg}} (request={{.Field "request_id"}})
+//
+// A newline is appended to the output of each log record.
+type Template struct {
+	// Template is the `text/template` source. It is parsed once on first use;
+	// parse errors are returned from Format and reported via `Logr.OnLoggerError`.
+	Template string
+
+	// TimestampFormat is an optional format used by `TemplateRec.Time`.
+	// If empty then DefTimestampFormat is used.
+	TimestampFormat string
+
+	// Funcs are optional additional functions available to the template.
+	Funcs template.FuncMap
+
+	once sync.Once
+	tmpl *template.Template
+	err  error
+}
+
+// TemplateRec is the data passed to a Template for each log record.
+type TemplateRec struct {
+	rec        *logr.LogRec
+	stacktrace bool
+	tsFormat   string
+}
+
+// Level returns the log record's level name.
+func (tr TemplateRec) Level() string {
+	return tr.rec.Level().Name
+}
+
+// Msg returns the log record's message.
+func (tr TemplateRec) Msg() string {
+	return tr.rec.Msg()
+}
+
+// Time returns the log record's timestamp using the Template's TimestampFormat.
+func (tr TemplateRec) Time() string {
+	return tr.rec.Time().Format(tr.tsFormat)
+}
+
+// TimeFormat returns the log record's timestamp using the provided layout.
+func (tr TemplateRec) TimeFormat(layout string) string {
+	return tr.rec.Time().Format(layout)
+}
+
+// Timestamp returns the log record's timestamp as a time.Time.
+func (tr TemplateRec) Timestamp() time.Time {
+	return tr.rec.Time()
+}
+
+// Field returns the value of the named context field, or an empty string
+// if the field does not exist.
+func (tr TemplateRec) Field(key string) interface{} {
+	val, ok := tr.rec.LookupField(key)
+	if !ok {
+		return ""
+	}
+	switch v := val.(type) {
+	case logr.AlwaysValue:
+		return v.Val
+	case logr.Field:
+		return v.Value()
+	}
+	return val
+}
+
+// Fields returns all context fields in `key=value` format, sorted by key.
+func (tr TemplateRec) Fields() string {
+	buf := &bytes.Buffer{}
+	logr.WriteRecordFields(buf, tr.rec, logr.FieldsFormat{Separator: " "})
+	return buf.String()
+}
+
+// Stacktrace returns the formatted stack trace, or an empty string if no
+// stack trace is required.
+func (tr TemplateRec) Stacktrace() string {
+	if !tr.stacktrace {
+		return ""
+	}
+	buf := &bytes.Buffer{}
+	logr.WriteStacktrace(buf, tr.rec.StackFrames())
+	return buf.String()
+}
+
+// Format converts a log record to bytes by executing the template.
+func (t *Template) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	t.once.Do(t.parse)
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	if buf == nil {
+		buf = &bytes.Buffer{}
+	}
+	start := buf.Len()
+
+	tsFormat := t.TimestampFormat
+	if tsFormat == "" {
+		tsFormat = logr.DefTimestampFormat
+	}
+
+	data := TemplateRec{rec: rec, stacktrace: stacktrace, tsFormat: tsFormat}
+	if err := t.tmpl.Execute(buf, data); err != nil {
+		buf.Truncate(start)
+		return nil, fmt.Errorf("template execute fail: %w", err)
+	}
+	buf.WriteString("\n")
+	return buf, nil
+}
+
+func (t *Template) parse() {
+	tmpl := template.New("logr")
+	if t.Funcs != nil {
+		tmpl = tmpl.Funcs(t.Funcs)
+	}
+	t.tmpl, t.err = tmpl.Parse(t.Template)
+	if t.err != nil {
+		t.err = fmt.Errorf("template parse fail: %w", t.err)
+	}
+}
+
+// ContentType returns the MIME type of the formatted output.
+func (t *Template) ContentType() string {
+	return "text/plain"
+}
+
+// FileExt returns a file extension suitable for the formatted output.
+func (t *Template) FileExt() string {
+	return ".log"
+}
@@ -0,0 +1,77 @@
+package format_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+)
+
+func TestTemplate(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Template{
+		Template:        `{{.TimeFormat "2006"}} {{upper .Level}} {{.Msg}} (request={{.Field "request_id"}}) [{{.Fields}}]`,
+		TimestampFormat: "15:04",
+		Funcs:           map[string]interface{}{"upper": strings.ToUpper},
+	}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	logger := lgr.NewLogger().WithFields(logr.Fields{"request_id": "abc123", "user": "bob"})
+	logger.Info("hello")
+	logger.Debug("XXX")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	got := buf.String()
+	want := " INFO hello (request=abc123) [request_id=abc123 user=bob]\n"
+	if len(got) != len(want)+4 || !strings.HasSuffix(got, want) {
+		t.Errorf("expected: \"<year>%s\";  got: \"%s\"", want, got)
+	}
+}
+
+func TestTemplateParseError(t *testing.T) {
+	var mux sync.Mutex
+	var errs []error
+	lgr := &logr.Logr{
+		OnLoggerError: func(err error) {
+			mux.Lock()
+			defer mux.Unlock()
+			errs = append(errs, err)
+		},
+	}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Template{Template: `{{.Msg`}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Error(err)
+	}
+
+	lgr.NewLogger().Info("not output")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "template parse fail") {
+		t.Errorf("expected template parse error, got %v", errs)
+	}
+	if buf.String() != "" {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+}
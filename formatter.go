@@ -6,12 +6,22 @@ import (
 	"io"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
 // Formatter turns a LogRec into a formatted string.
 type Formatter interface {
-	// Format converts a log record to bytes. If buf is not nil then it will be
-	// be filled with the formatted results, otherwise a new buffer will be allocated.
+	// Format converts a log record to bytes, ending with a newline. If buf is
+	// not nil then the formatted results are appended to it, otherwise a new
+	// buffer is allocated.
+	// The returned buffer is owned by the caller and its contents remain valid
+	// until the caller modifies or releases it; formatters do not retain it.
+	// This allows targets to reuse the bytes, e.g. to both write them and
+	// compute a checksum.
 	Format(rec *LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error)
 }
 
@@ -19,8 +29,198 @@ const (
 	// DefTimestampFormat is the default time stamp format used by
 	// Plain formatter and others.
 	DefTimestampFormat = "2006-01-02 15:04:05.000 Z07:00"
+
+	// DefContentType is the MIME type assumed for formatters that do not
+	// implement ContentTyped.
+	DefContentType = "application/octet-stream"
+
+	// DefFileExt is the file extension assumed for formatters that do not
+	// implement ContentTyped.
+	DefFileExt = ".log"
+
+	// DefRecordSeparator is the default output by targets after each
+	// formatted log record, which is the newline output by formatters.
+	// See `Basic.SetRecordSeparator`.
+	DefRecordSeparator = "\n"
 )
 
+var defRecordSeparator = []byte(DefRecordSeparator)
+
+// ContentTyped can optionally be implemented by a Formatter to declare the
+// MIME type and a suitable file extension for its output. Targets such as
+// HTTP or file targets can use this to configure themselves.
+type ContentTyped interface {
+	// ContentType returns the MIME type of the formatted output, e.g. `application/json`.
+	ContentType() string
+	// FileExt returns a file extension, including the leading period, e.g. `.json`.
+	FileExt() string
+}
+
+// ContentTypeOf returns the MIME type declared by the formatter, or
+// DefContentType if the formatter does not implement ContentTyped.
+func ContentTypeOf(formatter Formatter) string {
+	if ct, ok := formatter.(ContentTyped); ok {
+		return ct.ContentType()
+	}
+	return DefContentType
+}
+
+// FileExtOf returns the file extension declared by the formatter, or
+// DefFileExt if the formatter does not implement ContentTyped.
+func FileExtOf(formatter Formatter) string {
+	if ct, ok := formatter.(ContentTyped); ok {
+		return ct.FileExt()
+	}
+	return DefFileExt
+}
+
+// DefFormatErrorKey is the field key for the formatter error included in
+// log records formatted by `Logr.FallbackFormatter`.
+const DefFormatErrorKey = "format_error"
+
+// DefSequenceKey is the default key formatters use for the sequence number of
+// log records when `Logr.EnableSequence` is true.
+const DefSequenceKey = "seq"
+
+// formatRecord formats the log record using the formatter, switching to
+// `Logr.FallbackFormatter` when the formatter fails, and enforcing
+// `Logr.FormatTimeout` via the worker.
+func formatRecord(f Formatter, worker *formatWorker, rec *LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	lgr := rec.Logger().Logr()
+	if lgr == nil || (lgr.FallbackFormatter == nil && lgr.FormatTimeout <= 0) {
+		return f.Format(rec, stacktrace, buf)
+	}
+
+	var start int
+	if buf != nil {
+		start = buf.Len()
+	}
+	var out *bytes.Buffer
+	var err error
+	if lgr.FormatTimeout > 0 {
+		out, err = worker.format(f, rec, stacktrace, buf, lgr.FormatTimeout)
+	} else {
+		out, err = formatSafe(f, rec, stacktrace, buf)
+	}
+	if err == nil {
+		return out, nil
+	}
+	if lgr.FallbackFormatter == nil {
+		return nil, err
+	}
+	lgr.ReportError(fmt.Errorf("formatter error, using fallback formatter: %w", err))
+
+	if buf != nil {
+		buf.Truncate(start) // discard any partial output
+	}
+	degraded := rec.WithTime(rec.Time())
+	degraded.logger = Logger{logr: lgr, fields: Fields{DefFormatErrorKey: err.Error()}}
+	degraded.globals = nil
+	return lgr.FallbackFormatter.Format(degraded, stacktrace, buf)
+}
+
+// formatSafe calls the formatter, converting a panic, such as from a custom
+// marshaler, into an error.
+func formatSafe(f Formatter, rec *LogRec, stacktrace bool, buf *bytes.Buffer) (out *bytes.Buffer, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("formatter panic: %v", r)
+		}
+	}()
+	return f.Format(rec, stacktrace, buf)
+}
+
+// formatWorker formats log records for a single target in one goroutine, so
+// a stalled formatter blocks at most one goroutine. While an abandoned call is
+// still running, log records are not formatted and ErrFormatTimeout is
+// returned without waiting.
+type formatWorker struct {
+	// mux serializes callers; each holds it for at most the timeout.
+	mux     sync.Mutex
+	reqs    chan formatReq
+	res     chan formatRes
+	buf     bytes.Buffer
+	stalled bool
+	stopped bool
+}
+
+type formatReq struct {
+	f          Formatter
+	rec        *LogRec
+	stacktrace bool
+}
+
+type formatRes struct {
+	out *bytes.Buffer
+	err error
+}
+
+// format formats the log record in the worker goroutine, starting it if
+// needed. The formatter writes to the worker's own buffer, copied to buf on
+// success, since an abandoned call may still be writing after this returns.
+func (w *formatWorker) format(f Formatter, rec *LogRec, stacktrace bool, buf *bytes.Buffer, timeout time.Duration) (*bytes.Buffer, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if w.stopped {
+		return formatSafe(f, rec, stacktrace, buf)
+	}
+	if w.stalled {
+		select {
+		case <-w.res:
+			w.stalled = false
+		default:
+			return nil, fmt.Errorf("%w, still formatting an earlier log record", ErrFormatTimeout)
+		}
+	}
+	if w.reqs == nil {
+		w.reqs = make(chan formatReq)
+		w.res = make(chan formatRes, 1)
+		go w.run()
+	}
+	w.reqs <- formatReq{f: f, rec: rec, stacktrace: stacktrace}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-w.res:
+		if res.err != nil {
+			return nil, res.err
+		}
+		if buf == nil {
+			buf = &bytes.Buffer{}
+		}
+		if res.out != nil {
+			buf.Write(res.out.Bytes())
+		}
+		return buf, nil
+	case <-timer.C:
+		w.stalled = true
+		return nil, fmt.Errorf("%w after %v", ErrFormatTimeout, timeout)
+	}
+}
+
+// run formats log records until the worker is stopped.
+func (w *formatWorker) run() {
+	for req := range w.reqs {
+		w.buf.Reset()
+		out, err := formatSafe(req.f, req.rec, req.stacktrace, &w.buf)
+		w.res <- formatRes{out: out, err: err}
+	}
+}
+
+// stop ends the worker goroutine once any call in progress returns. Log
+// records formatted afterwards are formatted without a timeout.
+func (w *formatWorker) stop() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if !w.stopped && w.reqs != nil {
+		close(w.reqs)
+	}
+	w.stopped = true
+}
+
 // DefaultFormatter is the default formatter, outputting only text with
 // no colors and a space delimiter. Use `format.Plain` instead.
 type DefaultFormatter struct {
@@ -38,9 +238,8 @@ func (p *DefaultFormatter) Format(rec *LogRec, stacktrace bool, buf *bytes.Buffe
 	fmt.Fprintf(buf, "%v%s", rec.Level(), delim)
 	fmt.Fprint(buf, rec.Msg(), delim)
 
-	ctx := rec.Fields()
-	if len(ctx) > 0 {
-		WriteFields(buf, ctx, " ")
+	if rec.HasFields() {
+		WriteRecordFields(buf, rec, FieldsFormat{Separator: " "})
 	}
 
 	if stacktrace {
@@ -49,16 +248,53 @@ func (p *DefaultFormatter) Format(rec *LogRec, stacktrace bool, buf *bytes.Buffe
 			buf.WriteString("\n")
 			WriteStacktrace(buf, rec.StackFrames())
 		}
+		if allStacks := rec.AllStacks(); len(allStacks) > 0 {
+			buf.WriteString("\n")
+			buf.Write(allStacks)
+		}
 	}
 	buf.WriteString("\n")
 
 	return buf, nil
 }
 
+// ContentType returns the MIME type of the formatted output.
+func (p *DefaultFormatter) ContentType() string {
+	return "text/plain"
+}
+
+// FileExt returns a file extension suitable for the formatted output.
+func (p *DefaultFormatter) FileExt() string {
+	return ".log"
+}
+
+// FieldsFormat controls how fields are output by `WriteFieldsFormat`.
+type FieldsFormat struct {
+	// Separator is output between fields.
+	Separator string
+
+	// KeyValueSep is output between each key and its value. Defaults to `=`.
+	KeyValueSep string
+
+	// QuoteKeys quotes keys, in the same way as string values, when they are
+	// empty or contain whitespace, control characters, double quotes, the
+	// Separator or the KeyValueSep, so fields can be parsed unambiguously.
+	QuoteKeys bool
+}
+
 // WriteFields writes zero or more name value pairs to the io.Writer.
 // The pairs are sorted by key name and output in key=value format
 // with optional separator between fields.
 func WriteFields(w io.Writer, flds Fields, separator string) {
+	WriteFieldsFormat(w, flds, FieldsFormat{Separator: separator})
+}
+
+// WriteFieldsFormat writes zero or more name value pairs to the io.Writer,
+// sorted by key name and output per the FieldsFormat.
+func WriteFieldsFormat(w io.Writer, flds Fields, ff FieldsFormat) {
+	if ff.KeyValueSep == "" {
+		ff.KeyValueSep = "="
+	}
 	keys := make([]string, 0, len(flds))
 	for k := range flds {
 		keys = append(keys, k)
@@ -66,31 +302,86 @@ func WriteFields(w io.Writer, flds Fields, separator string) {
 	sort.Strings(keys)
 	sep := ""
 	for _, key := range keys {
-		writeField(w, key, flds[key], sep)
-		sep = separator
+		writeField(w, key, flds[key], sep, &ff)
+		sep = ff.Separator
 	}
 }
 
-func writeField(w io.Writer, key string, val interface{}, sep string) {
+// WriteRecordFields writes the log record's fields to the io.Writer per the
+// FieldsFormat, sorted by key, without merging them into a map.
+func WriteRecordFields(w io.Writer, rec *LogRec, ff FieldsFormat) {
+	if ff.KeyValueSep == "" {
+		ff.KeyValueSep = "="
+	}
+	sep := ""
+	rec.EachField(func(key string, val interface{}) {
+		writeField(w, key, val, sep, &ff)
+		sep = ff.Separator
+	})
+}
+
+// WriteFieldFormat writes a single name value pair to the io.Writer per the
+// FieldsFormat, preceded by sep. Used by formatters that output fields one at
+// a time, e.g. to limit the number of fields.
+func WriteFieldFormat(w io.Writer, key string, val interface{}, sep string, ff FieldsFormat) {
+	if ff.KeyValueSep == "" {
+		ff.KeyValueSep = "="
+	}
+	writeField(w, key, val, sep, &ff)
+}
+
+func writeField(w io.Writer, key string, val interface{}, sep string, ff *FieldsFormat) {
+	switch v := val.(type) {
+	case AlwaysValue:
+		val = v.Val
+	case Field:
+		writeTypedField(w, key, v, sep, ff)
+		return
+	}
+
 	var template string
 	switch v := val.(type) {
 	case error:
 		val := v.Error()
 		if shouldQuote(val) {
-			template = "%s%s=%q"
+			template = "%s%s%s%q"
 		} else {
-			template = "%s%s=%s"
+			template = "%s%s%s%s"
 		}
 	case string:
 		if shouldQuote(v) {
-			template = "%s%s=%q"
+			template = "%s%s%s%q"
 		} else {
-			template = "%s%s=%s"
+			template = "%s%s%s%s"
 		}
 	default:
-		template = "%s%s=%v"
+		template = "%s%s%s%v"
 	}
-	fmt.Fprintf(w, template, sep, key, val)
+	fmt.Fprintf(w, template, sep, ff.formatKey(key), ff.KeyValueSep, val)
+}
+
+// formatKey returns the key, quoted if QuoteKeys is true and the key would be
+// ambiguous unquoted.
+func (ff *FieldsFormat) formatKey(key string) string {
+	if ff.QuoteKeys && ff.shouldQuoteKey(key) {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+func (ff *FieldsFormat) shouldQuoteKey(key string) bool {
+	if key == "" || strings.ContainsRune(key, '"') || strings.Contains(key, ff.KeyValueSep) {
+		return true
+	}
+	if ff.Separator != "" && strings.Contains(key, ff.Separator) {
+		return true
+	}
+	for _, c := range key {
+		if unicode.IsSpace(c) || unicode.IsControl(c) {
+			return true
+		}
+	}
+	return false
 }
 
 // shouldQuote returns true if val contains any characters that might be unsafe
@@ -0,0 +1,116 @@
+package logr
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	hostFieldsOnce sync.Once
+	hostFields     []Field
+)
+
+// HostFields returns fields identifying this process, for use with
+// `Logr.SetGlobalFields`: `host` containing the hostname, `pid` containing the
+// process ID and `app` containing the executable name. The values are
+// computed once, on first call.
+func HostFields() []Field {
+	hostFieldsOnce.Do(func() {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		hostFields = []Field{
+			String("host", host),
+			Int("pid", os.Getpid()),
+			String("app", filepath.Base(os.Args[0])),
+		}
+	})
+	fields := make([]Field, len(hostFields))
+	copy(fields, hostFields)
+	return fields
+}
+
+// BuildFields returns fields identifying the build of this program, for use
+// with `Logr.SetGlobalFields`, so that log output can be traced to a build:
+// `version`, `commit` and `build_time`. Empty values are omitted. The values
+// are typically set at link time, e.g.
+//
+//	var version, commit, buildTime string // set via -ldflags "-X main.version=..."
+//
+//	lgr.SetGlobalFields(append(logr.HostFields(), logr.BuildFields(version, commit, buildTime)...)...)
+//
+// See `BuildInfoFields` to obtain them from the Go toolchain instead.
+func BuildFields(version, commit, buildTime string) []Field {
+	fields := make([]Field, 0, 3)
+	if version != "" {
+		fields = append(fields, String("version", version))
+	}
+	if commit != "" {
+		fields = append(fields, String("commit", commit))
+	}
+	if buildTime != "" {
+		fields = append(fields, String("build_time", buildTime))
+	}
+	return fields
+}
+
+// BuildInfoFields returns `BuildFields` using the build information embedded
+// by the Go toolchain, via `runtime/debug.ReadBuildInfo`: the main module
+// version and, when built from a version control checkout, the revision and
+// commit time. Returns no fields if build information is unavailable.
+func BuildInfoFields() []Field {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	version := info.Main.Version
+	if version == "(devel)" {
+		version = ""
+	}
+	var commit, buildTime string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			commit = s.Value
+		case "vcs.time":
+			buildTime = s.Value
+		}
+	}
+	return BuildFields(version, commit, buildTime)
+}
+
+// SetGlobalFields sets fields that are added to every log record created by
+// Loggers of this Logr, such as `HostFields`. Fields added to a Logger take
+// precedence over global fields with the same key. Each call replaces the
+// global fields set by any previous call; call with no fields to remove them.
+// Safe to call concurrently with logging.
+func (logr *Logr) SetGlobalFields(fields ...Field) {
+	globals := make([]Field, len(fields))
+	copy(globals, fields)
+	logr.globals.Store(globals)
+}
+
+// GlobalFields returns the fields set via `SetGlobalFields`.
+func (logr *Logr) GlobalFields() []Field {
+	globals := logr.globalFields()
+	fields := make([]Field, len(globals))
+	copy(fields, globals)
+	return fields
+}
+
+func (logr *Logr) globalFields() []Field {
+	if logr == nil {
+		return nil
+	}
+	globals, _ := logr.globals.Load().([]Field)
+	return globals
+}
+
+// addGlobalFields captures the Logr's global fields when the log record is
+// created; they are merged into `Fields`.
+func (rec *LogRec) addGlobalFields() {
+	rec.globals = rec.logger.logr.globalFields()
+}
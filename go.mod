@@ -1,11 +1,17 @@
 module github.com/mattermost/logr
 
-go 1.12
+go 1.18
 
 require (
 	github.com/francoispqt/gojay v1.2.13
+	github.com/go-logr/logr v1.2.4
 	github.com/stretchr/testify v1.2.2
 	github.com/wiggin77/cfg v1.0.2
 	github.com/wiggin77/merror v1.0.2
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
@@ -0,0 +1,47 @@
+package logr
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// DefGoroutineIDKey is the default field key for the goroutine ID added when
+// `Logr.EnableGoroutineID` is true.
+const DefGoroutineIDKey = "goroutine"
+
+var goroutinePrefix = []byte("goroutine ")
+
+// goroutineID returns the ID of the calling goroutine, parsed from the header
+// of its stack trace, e.g. "goroutine 42 [running]:". Returns zero if the
+// header cannot be parsed. Only the header is captured, into a stack buffer,
+// so this does not allocate.
+func goroutineID() int64 {
+	var arr [64]byte
+	b := arr[:runtime.Stack(arr[:], false)]
+	if !bytes.HasPrefix(b, goroutinePrefix) {
+		return 0
+	}
+	b = b[len(goroutinePrefix):]
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// addGoroutineID adds the goroutine ID field if enabled by the Logr.
+func (rec *LogRec) addGoroutineID() {
+	lgr := rec.logger.logr
+	if lgr == nil || !lgr.EnableGoroutineID {
+		return
+	}
+	key := lgr.GoroutineIDKey
+	if key == "" {
+		key = DefGoroutineIDKey
+	}
+	rec.logger = rec.logger.With(Int64(key, goroutineID()))
+}
@@ -0,0 +1,116 @@
+// Package gologr provides an adapter implementing the `github.com/go-logr/logr`
+// LogSink interface, so libraries that accept a go-logr Logger, such as
+// Kubernetes controllers, can output via a Logr's targets and formatters.
+// It is a separate package so go-logr is only linked into applications that
+// use it.
+package gologr
+
+import (
+	"fmt"
+
+	gologr "github.com/go-logr/logr"
+	"github.com/mattermost/logr"
+)
+
+const (
+	// DefNameKey is the field key containing the name added via `WithName`.
+	DefNameKey = "logger"
+
+	// DefErrorKey is the field key containing the error passed to `Error`.
+	DefErrorKey = "error"
+
+	// MissingValue is the value of a key passed without a value.
+	MissingValue = "(MISSING)"
+)
+
+// Sink is a go-logr LogSink that outputs via a Logr.
+//
+// go-logr verbosity levels map onto this package's levels: V(0) is Info,
+// V(1) is Debug and V(2) and above are Trace. Errors are output at Error level
+// with the error in a DefErrorKey field. Names added via `WithName` are joined
+// with `/` in a DefNameKey field.
+type Sink struct {
+	logger logr.Logger
+	name   string
+}
+
+// NewSink creates a LogSink that outputs via the Logr.
+func NewSink(lgr *logr.Logr) gologr.LogSink {
+	return &Sink{logger: lgr.NewLogger()}
+}
+
+// NewLogger creates a go-logr Logger that outputs via the Logr.
+func NewLogger(lgr *logr.Logr) gologr.Logger {
+	return gologr.New(NewSink(lgr))
+}
+
+// Init is called by go-logr when the Logger is created. Caller information
+// is not used.
+func (s *Sink) Init(info gologr.RuntimeInfo) {
+}
+
+// Enabled returns true if the level corresponding to the go-logr verbosity
+// level is enabled for any target.
+func (s *Sink) Enabled(level int) bool {
+	return s.logger.Logr().IsLevelEnabled(verbosityLevel(level)).Enabled
+}
+
+// Info outputs a log record at the level corresponding to the go-logr
+// verbosity level.
+func (s *Sink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.withValues(keysAndValues).Log(verbosityLevel(level), msg)
+}
+
+// Error outputs a log record at Error level including the error.
+func (s *Sink) Error(err error, msg string, keysAndValues ...interface{}) {
+	logger := s.withValues(keysAndValues)
+	if err != nil {
+		logger = logger.WithField(DefErrorKey, err)
+	}
+	logger.Error(msg)
+}
+
+// WithValues returns a LogSink that adds the key/value pairs to each log record.
+func (s *Sink) WithValues(keysAndValues ...interface{}) gologr.LogSink {
+	return &Sink{logger: s.withValues(keysAndValues), name: s.name}
+}
+
+// WithName returns a LogSink that adds name to the DefNameKey field, joined to
+// any existing name with `/`.
+func (s *Sink) WithName(name string) gologr.LogSink {
+	if s.name != "" {
+		name = s.name + "/" + name
+	}
+	return &Sink{logger: s.logger.WithField(DefNameKey, name), name: name}
+}
+
+// withValues returns the Logger with fields added for the key/value pairs.
+func (s *Sink) withValues(keysAndValues []interface{}) logr.Logger {
+	if len(keysAndValues) == 0 {
+		return s.logger
+	}
+	fields := make(logr.Fields, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keysAndValues[i])
+		}
+		var val interface{} = MissingValue
+		if i+1 < len(keysAndValues) {
+			val = keysAndValues[i+1]
+		}
+		fields[key] = val
+	}
+	return s.logger.WithFields(fields)
+}
+
+// verbosityLevel returns the level for a go-logr verbosity level.
+func verbosityLevel(v int) logr.Level {
+	switch {
+	case v <= 0:
+		return logr.Info
+	case v == 1:
+		return logr.Debug
+	}
+	return logr.Trace
+}
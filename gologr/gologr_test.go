@@ -0,0 +1,45 @@
+package gologr_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/gologr"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+)
+
+func TestSink(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := gologr.NewLogger(lgr).WithName("controller").WithName("pods").WithValues("ns", "default")
+	logger.Info("reconciled", "count", 3)
+	logger.V(1).Info("details")
+	logger.V(2).Info("noise")
+	logger.Error(errors.New("timeout"), "failed", "retry")
+
+	if !logger.V(1).Enabled() || logger.V(2).Enabled() {
+		t.Error("unexpected verbosity enabled")
+	}
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	want := "info | reconciled | count=3 logger=\"controller/pods\" ns=default\n" +
+		"debug | details | logger=\"controller/pods\" ns=default\n" +
+		"error | failed | error=timeout logger=\"controller/pods\" ns=default retry=\"(MISSING)\"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected: %q;  got: %q", want, got)
+	}
+}
@@ -0,0 +1,124 @@
+package logr
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefDisabledProbeInterval is the default time between attempts to write a
+// log record to a target disabled due to write errors.
+// See `Basic.SetDisableOnErrors`.
+const DefDisabledProbeInterval = 10 * time.Second
+
+// HealthReporter is implemented by targets that can report whether they are
+// currently able to output log records, such as targets embedding `Basic`.
+type HealthReporter interface {
+	// Healthy returns false while the target is disabled due to errors.
+	Healthy() bool
+}
+
+// targetHealth tracks consecutive write errors for a Basic target, disabling
+// the target after too many and probing until writes succeed again.
+type targetHealth struct {
+	mux           sync.Mutex
+	maxErrors     int
+	probeInterval time.Duration
+	consecutive   int
+	disabled      bool
+	nextProbe     time.Time
+	disabledDrops uint64
+}
+
+// SetDisableOnErrors disables this target after maxErrors consecutive write
+// failures, e.g. while a disk is full, so a failing sink stops consuming CPU
+// and flooding `OnLoggerError`. While disabled, log records are discarded and
+// counted, except that one log record is written every probeInterval to
+// detect recovery; the first successful write re-enables the target. Errors
+// from failed probes are not reported.
+// maxErrors of zero, the default, never disables the target. probeInterval
+// defaults to DefDisabledProbeInterval.
+// Should be called before the target is added to a Logr.
+func (b *Basic) SetDisableOnErrors(maxErrors int, probeInterval time.Duration) {
+	if probeInterval <= 0 {
+		probeInterval = DefDisabledProbeInterval
+	}
+	b.health.mux.Lock()
+	defer b.health.mux.Unlock()
+	b.health.maxErrors = maxErrors
+	b.health.probeInterval = probeInterval
+}
+
+// Healthy returns false while this target is disabled due to consecutive
+// write errors. See `SetDisableOnErrors`.
+func (b *Basic) Healthy() bool {
+	b.health.mux.Lock()
+	defer b.health.mux.Unlock()
+	return !b.health.disabled
+}
+
+// DisabledDrops returns the number of log records discarded while this target
+// was disabled due to write errors. See `SetDisableOnErrors`.
+func (b *Basic) DisabledDrops() uint64 {
+	b.health.mux.Lock()
+	defer b.health.mux.Unlock()
+	return b.health.disabledDrops
+}
+
+// shouldWrite returns false if this target is disabled and the log record
+// should be discarded rather than written as a probe.
+func (b *Basic) shouldWrite(lgr *Logr) bool {
+	h := &b.health
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if !h.disabled {
+		return true
+	}
+	if now := lgr.now(); !now.Before(h.nextProbe) {
+		h.nextProbe = now.Add(h.probeInterval)
+		return true
+	}
+	h.disabledDrops++
+	return false
+}
+
+// writeResult records the result of a write and returns the error to report,
+// which is nil on success or for a failed probe.
+func (b *Basic) writeResult(err error, lgr *Logr) error {
+	h := &b.health
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if h.maxErrors <= 0 {
+		return err
+	}
+	if err == nil {
+		h.consecutive = 0
+		h.disabled = false
+		return nil
+	}
+	if h.disabled {
+		return nil
+	}
+	h.consecutive++
+	if h.consecutive < h.maxErrors {
+		return err
+	}
+	h.disabled = true
+	h.nextProbe = lgr.now().Add(h.probeInterval)
+	return fmt.Errorf("target %v disabled after %d consecutive write errors: %w", b, h.consecutive, err)
+}
+
+// Healthy returns false if any target added to this Logr that implements
+// HealthReporter is unhealthy, e.g. disabled due to write errors.
+func (logr *Logr) Healthy() bool {
+	logr.tmux.RLock()
+	defer logr.tmux.RUnlock()
+	for _, t := range logr.targets {
+		if hr, ok := t.(HealthReporter); ok && !hr.Healthy() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/logr"
+)
+
+// DefRedactedHeaders are the HTTP headers always redacted by `Header`, since
+// they commonly carry credentials.
+var DefRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// Header creates a Field whose value is encoded as a nested object containing
+// the HTTP headers, with sorted keys. Headers with multiple values are joined
+// with ", ". The values of DefRedactedHeaders, and of any headers named in
+// redact, are output as `logr.RedactedValue`. Header names are case
+// insensitive. The headers are copied, so h can be modified after the logging
+// call.
+func Header(key string, h http.Header, redact ...string) logr.Field {
+	m := make(map[string]interface{}, len(h))
+	for name, vals := range h {
+		name = http.CanonicalHeaderKey(name)
+		if isRedactedHeader(name, redact) {
+			m[name] = logr.RedactedValue
+			continue
+		}
+		m[name] = strings.Join(vals, ", ")
+	}
+	return logr.Map(key, m)
+}
+
+// isRedactedHeader returns true if the canonical header name is in
+// DefRedactedHeaders or redact.
+func isRedactedHeader(name string, redact []string) bool {
+	for _, r := range DefRedactedHeaders {
+		if strings.EqualFold(name, r) {
+			return true
+		}
+	}
+	for _, r := range redact {
+		if strings.EqualFold(name, r) {
+			return true
+		}
+	}
+	return false
+}
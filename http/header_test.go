@@ -0,0 +1,68 @@
+package http_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	logrhttp "github.com/mattermost/logr/http"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+)
+
+func TestHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	h.Set("Authorization", "Bearer secret")
+	h.Add("Accept", "text/html")
+	h.Add("Accept", "application/json")
+	h.Set("X-Session", "abc123")
+	h["cookie"] = []string{"session=xyz"} // non-canonical
+
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		want      string
+	}{
+		{
+			name:      "json",
+			formatter: &format.JSON{DisableTimestamp: true},
+			want: `{"level":"info","msg":"request","headers":{"Accept":"text/html, application/json",` +
+				`"Authorization":"***","Content-Type":"application/json","Cookie":"***","X-Session":"***"}}` + "\n",
+		},
+		{
+			name:      "plain",
+			formatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+			want: `info | request | headers={Accept="text/html, application/json" Authorization="***" ` +
+				`Content-Type="application/json" Cookie="***" X-Session="***"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, tt.formatter, buf, 1000))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lgr.NewLogger().With(logrhttp.Header("headers", h, "x-session")).Info("request")
+
+			if err = lgr.Shutdown(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := buf.String()
+			if got != tt.want {
+				t.Errorf("expected: %q;  got: %q", tt.want, got)
+			}
+			if strings.Contains(got, "secret") || strings.Contains(got, "xyz") {
+				t.Errorf("redacted value output: %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Package http provides helpers for logging HTTP requests and responses as
+// structured log records, including a middleware that logs one record per
+// request.
+package http
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/logr"
+)
+
+const (
+	// KeyMethod is the field key for the request method.
+	KeyMethod = "method"
+	// KeyPath is the field key for the request URL path.
+	KeyPath = "path"
+	// KeyRemoteAddr is the field key for the client network address.
+	KeyRemoteAddr = "remote_addr"
+	// KeyUserAgent is the field key for the client user agent.
+	KeyUserAgent = "user_agent"
+	// KeyStatus is the field key for the response status code.
+	KeyStatus = "status"
+	// KeyBytes is the field key for the number of response body bytes written.
+	KeyBytes = "bytes"
+	// KeyDuration is the field key for the time taken to serve the request.
+	KeyDuration = "duration"
+
+	// DefaultMsg is the message used for records logged by Middleware.
+	DefaultMsg = "http request"
+)
+
+// RequestFields returns fields describing the request: method, path,
+// remote address and user agent.
+func RequestFields(r *http.Request) []logr.Field {
+	return appendRequestFields(make([]logr.Field, 0, 4), r)
+}
+
+// ResponseFields returns fields describing the response: status, bytes
+// written and duration.
+func ResponseFields(status int, bytes int64, duration time.Duration) []logr.Field {
+	return appendResponseFields(make([]logr.Field, 0, 3), status, bytes, duration)
+}
+
+func appendRequestFields(fields []logr.Field, r *http.Request) []logr.Field {
+	return append(fields,
+		logr.String(KeyMethod, r.Method),
+		logr.String(KeyPath, r.URL.Path),
+		logr.String(KeyRemoteAddr, r.RemoteAddr),
+		logr.String(KeyUserAgent, r.UserAgent()),
+	)
+}
+
+func appendResponseFields(fields []logr.Field, status int, bytes int64, duration time.Duration) []logr.Field {
+	return append(fields,
+		logr.Int(KeyStatus, status),
+		logr.Int64(KeyBytes, bytes),
+		logr.Duration(KeyDuration, duration),
+	)
+}
+
+// StatusLevel returns the level used by Middleware for a response status:
+// Error for 5xx, Warn for 4xx and Info otherwise.
+func StatusLevel(status int) logr.Level {
+	switch {
+	case status >= 500:
+		return logr.Error
+	case status >= 400:
+		return logr.Warn
+	}
+	return logr.Info
+}
+
+// Middleware returns a handler that calls next and then logs one record per
+// request containing the request and response fields. The level is chosen via
+// `StatusLevel`; fields are only built when that level is enabled.
+func Middleware(next http.Handler, logger logr.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r)
+
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		lvl := StatusLevel(status)
+		if lgr := logger.Logr(); lgr == nil || !lgr.IsLevelEnabled(lvl).Enabled {
+			return
+		}
+
+		var arr [7]logr.Field
+		fields := appendRequestFields(arr[:0], r)
+		fields = appendResponseFields(fields, status, rw.bytes, time.Since(start))
+		logger.With(fields...).Log(lvl, DefaultMsg)
+	})
+}
+
+// responseWriter records the status code and number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code and passes it on.
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes written and passes them on.
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the wrapped writer does.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the wrapped writer does.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return h.Hijack()
+}
+
+// Push implements http.Pusher if the wrapped writer does.
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := rw.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// Unwrap returns the wrapped writer, so that http.ResponseController can reach
+// any other interfaces it implements.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,132 @@
+package http_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	logrhttp "github.com/mattermost/logr/http"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+)
+
+func TestMiddleware(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true, DisableStacktrace: true}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("not here"))
+	})
+	mw := logrhttp.Middleware(handler, lgr.NewLogger())
+
+	req := httptest.NewRequest("GET", "/some/path?q=1", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	mw.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(buf.String())), &rec); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+
+	expected := map[string]interface{}{
+		"level":      "warn",
+		"msg":        logrhttp.DefaultMsg,
+		"method":     "GET",
+		"path":       "/some/path",
+		"user_agent": "test-agent",
+		"status":     float64(404),
+		"bytes":      float64(8),
+	}
+	for k, v := range expected {
+		if rec[k] != v {
+			t.Errorf("field %s: expected %v, got %v", k, v, rec[k])
+		}
+	}
+	if _, ok := rec["duration"]; !ok {
+		t.Error("missing duration field")
+	}
+	if _, ok := rec["remote_addr"]; !ok {
+		t.Error("missing remote_addr field")
+	}
+}
+
+func TestMiddlewareLevelDisabled(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, &format.Plain{}, buf, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mw := logrhttp.Middleware(handler, lgr.NewLogger())
+	mw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "" {
+		t.Errorf("expected no output, got %q", buf.String())
+	}
+}
+
+// hijackRecorder is a ResponseRecorder that also implements http.Hijacker.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func TestMiddlewareForwardsInterfaces(t *testing.T) {
+	lgr := &logr.Logr{}
+	defer lgr.Shutdown()
+
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() != rec {
+			t.Error("Unwrap does not return the wrapped writer")
+		}
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("writer does not implement http.Flusher")
+		}
+		if err := w.(http.Pusher).Push("/style.css", nil); err != http.ErrNotSupported {
+			t.Errorf("expected ErrNotSupported from Push, got %v", err)
+		}
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("writer does not implement http.Hijacker")
+		}
+		if _, _, err := h.Hijack(); err != nil {
+			t.Errorf("Hijack: %v", err)
+		}
+	})
+	logrhttp.Middleware(handler, lgr.NewLogger()).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if !rec.hijacked {
+		t.Error("Hijack was not forwarded")
+	}
+}
@@ -0,0 +1,134 @@
+package logr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key names recognized by the JSON ingest writer. These match the
+// default key names used by `format.JSON`.
+const (
+	ingestKeyTimestamp = "timestamp"
+	ingestKeyLevel     = "level"
+	ingestKeyMsg       = "msg"
+)
+
+// jsonIngestWriter parses JSON log lines and enqueues them as log records.
+type jsonIngestWriter struct {
+	mux          sync.Mutex
+	lgr          *Logr
+	defaultLevel Level
+	partial      []byte
+}
+
+// NewJSONIngestWriter creates an io.Writer that parses each line written to it
+// as a JSON log record (as produced by `format.JSON` with default key names) and
+// adds it to the Logr queue, preserving the level, message, timestamp and fields.
+// This can be used to unify the output of a subprocess with this Logr's targets.
+// Unknown levels map to defaultLevel. Malformed lines are logged verbatim as the
+// message at defaultLevel. Call Close when the source ends, e.g. when a
+// subprocess exits, to ingest any final line lacking a trailing newline.
+func NewJSONIngestWriter(lgr *Logr, defaultLevel Level) io.WriteCloser {
+	return &jsonIngestWriter{lgr: lgr, defaultLevel: defaultLevel}
+}
+
+// Write buffers p and ingests each complete line. Incomplete trailing data
+// is retained until the next call to Write or Close.
+func (w *jsonIngestWriter) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	data := p
+	if len(w.partial) > 0 {
+		data = append(w.partial, p...)
+		w.partial = nil
+	}
+
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			break
+		}
+		w.ingest(data[:idx])
+		data = data[idx+1:]
+	}
+
+	if len(data) > 0 {
+		w.partial = append([]byte(nil), data...)
+	}
+	return len(p), nil
+}
+
+// Close ingests any incomplete trailing data as a final line.
+func (w *jsonIngestWriter) Close() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if len(w.partial) > 0 {
+		w.ingest(w.partial)
+		w.partial = nil
+	}
+	return nil
+}
+
+// ingest converts a single line to a log record and enqueues it.
+func (w *jsonIngestWriter) ingest(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	lvl := w.defaultLevel
+	var msg string
+	var stamp time.Time
+	var fields Fields
+
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(line, &m); err != nil {
+		msg = string(line)
+	} else {
+		if s, ok := m[ingestKeyLevel].(string); ok {
+			lvl = w.parseLevel(s)
+		}
+		if s, ok := m[ingestKeyMsg].(string); ok {
+			msg = s
+		}
+		if s, ok := m[ingestKeyTimestamp].(string); ok {
+			if t, err := time.Parse(DefTimestampFormat, s); err == nil {
+				stamp = t
+			}
+		}
+		delete(m, ingestKeyLevel)
+		delete(m, ingestKeyMsg)
+		delete(m, ingestKeyTimestamp)
+		if len(m) > 0 {
+			fields = Fields(m)
+		}
+	}
+
+	status := w.lgr.IsLevelEnabled(lvl)
+	if !status.Enabled {
+		return
+	}
+
+	logger := w.lgr.NewLogger().WithFields(fields)
+	rec := NewLogRec(lvl, logger, "", []interface{}{msg}, false)
+	if !stamp.IsZero() {
+		rec.time = stamp
+	}
+	w.lgr.enqueue(rec)
+}
+
+// parseLevel returns the standard level matching name, or the default level.
+func (w *jsonIngestWriter) parseLevel(name string) Level {
+	for _, lvl := range stdLevels {
+		if strings.EqualFold(lvl.Name, name) {
+			return lvl
+		}
+	}
+	return w.defaultLevel
+}
@@ -0,0 +1,56 @@
+package logr_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONIngestWriter(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	w := logr.NewJSONIngestWriter(lgr, logr.Info)
+
+	_, err = io.WriteString(w, `{"timestamp":"2020-04-01 10:11:12.000 Z","level":"error","msg":"disk full","dev":"sda1"}`+"\n")
+	require.NoError(t, err)
+
+	// partial lines are buffered until complete.
+	_, err = io.WriteString(w, `{"level":"warn","msg":"split`)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, ` line"}`+"\n")
+	require.NoError(t, err)
+
+	_, err = io.WriteString(w, "not json at all\n")
+	require.NoError(t, err)
+
+	// debug is filtered out by the target.
+	_, err = io.WriteString(w, `{"level":"debug","msg":"XXX"}`+"\n")
+	require.NoError(t, err)
+
+	// Close ingests a final line lacking a newline.
+	_, err = io.WriteString(w, `{"level":"error","msg":"exited"}`)
+	require.NoError(t, err)
+	err = w.Close()
+	require.NoError(t, err)
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	output := buf.String()
+	assert.Contains(t, output, "2020-04-01 10:11:12.000 Z | error | disk full | dev=sda1")
+	assert.Contains(t, output, "warn | split line |")
+	assert.Contains(t, output, "info | not json at all |")
+	assert.Contains(t, output, "error | exited |")
+	assert.NotContains(t, output, "XXX")
+}
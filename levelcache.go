@@ -10,7 +10,13 @@ import (
 type LevelStatus struct {
 	Enabled    bool
 	Stacktrace bool
-	empty      bool
+
+	// StacktraceOptions determines how the stack trace is captured when
+	// Stacktrace is true.
+	StacktraceOptions StacktraceOptions
+
+	empty    bool
+	shutdown bool
 }
 
 type levelCache interface {
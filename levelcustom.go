@@ -1,6 +1,7 @@
 package logr
 
 import (
+	"sort"
 	"sync"
 )
 
@@ -43,3 +44,18 @@ func (st *CustomFilter) Add(levels ...Level) {
 		st.levels[s.ID] = s
 	}
 }
+
+// Levels returns the levels added to this filter, sorted by ID.
+func (st *CustomFilter) Levels() []Level {
+	st.mux.RLock()
+	defer st.mux.RUnlock()
+
+	levels := make([]Level, 0, len(st.levels))
+	for _, lvl := range st.levels {
+		levels = append(levels, lvl)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].ID < levels[j].ID
+	})
+	return levels
+}
@@ -1,6 +1,7 @@
 package logr_test
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync/atomic"
@@ -13,7 +14,7 @@ import (
 )
 
 var (
-	LoginLevel  = logr.Level{ID: 100, Name: "login ", Stacktrace: false}
+	LoginLevel  = logr.Level{ID: 100, Name: "login", Stacktrace: false}
 	LogoutLevel = logr.Level{ID: 101, Name: "logout", Stacktrace: false}
 	BadLevel    = logr.Level{ID: logr.MaxLevelID + 1, Name: "invalid", Stacktrace: false}
 )
@@ -26,7 +27,7 @@ func TestCustomLevel(t *testing.T) {
 	filter := &logr.CustomFilter{}
 	filter.Add(LoginLevel, LogoutLevel)
 
-	formatter := &format.Plain{Delim: " | "}
+	formatter := &format.Plain{Delim: " | ", LevelWidth: 6}
 	tgr := target.NewWriterTarget(filter, formatter, buf, 1000)
 	err := lgr.AddTarget(tgr)
 	if err != nil {
@@ -56,6 +57,9 @@ func TestCustomLevel(t *testing.T) {
 		t.Error("wrong level(s) output")
 	}
 
+	if !strings.Contains(output, " | login  | ") || !strings.Contains(output, " | logout | ") {
+		t.Error("level names not padded to LevelWidth")
+	}
 }
 
 func TestLevelIDTooLarge(t *testing.T) {
@@ -91,3 +95,45 @@ func TestLevelIDTooLarge(t *testing.T) {
 		t.Error("OnLoggerError should be called once")
 	}
 }
+
+func TestEnabledLevels(t *testing.T) {
+	lgr := &logr.Logr{}
+
+	custom := &logr.CustomFilter{}
+	custom.Add(LogoutLevel, LoginLevel, logr.Error)
+	customTarget := target.NewWriterTarget(custom, &format.Plain{}, &test.Buffer{}, 1000)
+
+	std := &logr.StdFilter{Lvl: logr.Warn, Stacktrace: logr.Panic}
+	stdTarget := target.NewWriterTarget(std, &format.Plain{}, &test.Buffer{}, 1000)
+
+	notAdded := target.NewWriterTarget(std, &format.Plain{}, &test.Buffer{}, 1000)
+	defer notAdded.Shutdown(context.Background())
+
+	err := lgr.AddTarget(customTarget, stdTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lgr.Shutdown()
+
+	if got := len(logr.StandardLevels()); got != 7 {
+		t.Errorf("expected 7 standard levels, got %d", got)
+	}
+
+	names := func(levels []logr.Level) string {
+		s := make([]string, 0, len(levels))
+		for _, lvl := range levels {
+			s = append(s, lvl.Name)
+		}
+		return strings.Join(s, ",")
+	}
+
+	if got := names(lgr.EnabledLevels(customTarget)); got != "error,login,logout" {
+		t.Errorf("unexpected custom target levels: %s", got)
+	}
+	if got := names(lgr.EnabledLevels(stdTarget)); got != "panic,fatal,error,warn" {
+		t.Errorf("unexpected std target levels: %s", got)
+	}
+	if got := lgr.EnabledLevels(notAdded); got != nil {
+		t.Errorf("expected nil for target not added, got %v", got)
+	}
+}
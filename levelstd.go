@@ -1,15 +1,42 @@
 package logr
 
+import "time"
+
 // StdFilter allows targets to filter via classic log levels where any level
 // beyond a certain verbosity/severity is enabled.
 type StdFilter struct {
 	Lvl        Level
 	Stacktrace Level
+
+	// StacktraceMaxDepth is the maximum number of stack frames captured for
+	// levels requiring a stack trace. Zero means DefaultMaxStackFrames.
+	StacktraceMaxDepth int
+
+	// StacktraceAllGoroutines captures the stacks of all goroutines for
+	// levels requiring a stack trace. This is expensive and typically only
+	// useful when Stacktrace is Panic or Fatal.
+	StacktraceAllGoroutines bool
+
+	// Exact enables only Lvl itself, rather than Lvl and all more severe
+	// levels, e.g. to route only warnings to a target. Stacktrace is
+	// unaffected.
+	Exact bool
+
+	// Clock, when not nil, returns the current time used by `Suppress` to
+	// start the suppression window, which is compared with the time of each
+	// log record. Set it to the same clock as `Logr.Clock`. Defaults to
+	// `time.Now`.
+	Clock func() time.Time
+
+	suppression *suppression
 }
 
-// IsEnabled returns true if the specified Level is at or above this verbosity. Also
-// determines if a stack trace is required.
+// IsEnabled returns true if the specified Level is at or above this verbosity,
+// or is exactly this level when Exact is true.
 func (lt StdFilter) IsEnabled(level Level) bool {
+	if lt.Exact {
+		return level.ID == lt.Lvl.ID
+	}
 	return level.ID <= lt.Lvl.ID
 }
 
@@ -18,6 +45,14 @@ func (lt StdFilter) IsStacktraceEnabled(level Level) bool {
 	return level.ID <= lt.Stacktrace.ID
 }
 
+// StacktraceOptions returns how stack traces are captured for the specified Level.
+func (lt StdFilter) StacktraceOptions(level Level) StacktraceOptions {
+	return StacktraceOptions{
+		MaxDepth:      lt.StacktraceMaxDepth,
+		AllGoroutines: lt.StacktraceAllGoroutines,
+	}
+}
+
 var (
 	// Panic is the highest level of severity. Logs the message and then panics.
 	Panic = Level{ID: 0, Name: "panic"}
@@ -35,3 +70,20 @@ var (
 	// Trace designates the highest verbosity of log output.
 	Trace = Level{ID: 6, Name: "trace"}
 )
+
+// forceStacktrace returns true for levels that always capture a stack trace,
+// regardless of filter, since the stack is the most important diagnostic for
+// them.
+func forceStacktrace(lvl Level) bool {
+	return lvl.ID == Panic.ID || lvl.ID == Fatal.ID
+}
+
+// stdLevels contains all the standard levels, in order of severity.
+var stdLevels = []Level{Panic, Fatal, Error, Warn, Info, Debug, Trace}
+
+// StandardLevels returns all the standard levels, in order of severity.
+func StandardLevels() []Level {
+	levels := make([]Level, len(stdLevels))
+	copy(levels, stdLevels)
+	return levels
+}
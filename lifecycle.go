@@ -0,0 +1,61 @@
+package logr
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefLifecycleKey is the field key identifying lifecycle log records
+	// output when `Logr.LogLifecycleEvents` is true. Its value is
+	// LifecycleStarted or LifecycleStopped.
+	DefLifecycleKey = "lifecycle"
+
+	// DefUptimeKey is the field key for the time since logging started,
+	// included in the stopped lifecycle log record.
+	DefUptimeKey = "uptime"
+
+	// DefRecordsKey is the field key for the number of log records accepted,
+	// included in the stopped lifecycle log record.
+	DefRecordsKey = "records"
+
+	// LifecycleStarted is the lifecycle field value when logging starts.
+	LifecycleStarted = "started"
+
+	// LifecycleStopped is the lifecycle field value when logging stops.
+	LifecycleStopped = "stopped"
+)
+
+// RecordsAccepted returns the number of log records accepted into the Logr
+// queue since it started.
+func (logr *Logr) RecordsAccepted() uint64 {
+	return atomic.LoadUint64(&logr.accepted)
+}
+
+// logStarted outputs the started lifecycle log record, if enabled.
+func (logr *Logr) logStarted() {
+	if !logr.LogLifecycleEvents {
+		return
+	}
+	logr.NewLogger().WithField(DefLifecycleKey, LifecycleStarted).Info("logging started")
+}
+
+// logStopped outputs the stopped lifecycle log record, if enabled and
+// logging was started.
+func (logr *Logr) logStopped() {
+	if !logr.LogLifecycleEvents {
+		return
+	}
+	logr.tmux.RLock()
+	started := logr.started
+	logr.tmux.RUnlock()
+	if started.IsZero() {
+		return
+	}
+	uptime := logr.now().Sub(started)
+	logr.NewLogger().WithFields(Fields{
+		DefLifecycleKey: LifecycleStopped,
+		DefUptimeKey:    uptime.Round(time.Millisecond),
+		DefRecordsKey:   logr.RecordsAccepted(),
+	}).Info("logging stopped")
+}
@@ -2,15 +2,42 @@ package logr
 
 import (
 	"fmt"
+	"time"
 )
 
 // Fields type, used to pass to `WithFields`.
 type Fields map[string]interface{}
 
+// FieldConflictPolicy determines how `Logger.WithFields` handles a new field
+// whose key already exists in the parent Logger.
+type FieldConflictPolicy int
+
+const (
+	// ConflictOverwrite replaces the parent field with the new field. This is the default.
+	ConflictOverwrite FieldConflictPolicy = iota
+	// ConflictKeepParent keeps the parent field and discards the new field.
+	ConflictKeepParent
+	// ConflictError replaces the parent field with the new field and reports the
+	// conflict via `Logr.OnLoggerError`.
+	ConflictError
+	// ConflictSuffix keeps both fields, renaming the new field by appending
+	// `_2`, `_3`, etc. to its key until it is unique.
+	ConflictSuffix
+)
+
 // Logger provides context for logging via fields.
+// A zero Logger discards all log records.
 type Logger struct {
 	logr   *Logr
 	fields Fields
+	timer  *timer
+	cancel *cancelWatch
+
+	// deadline set via `WithDeadline`.
+	deadline time.Duration
+
+	// typed fields added via `With`, in the order they were added.
+	typed []Field
 }
 
 // Logr returns the `Logr` instance that created this `Logger`.
@@ -27,7 +54,13 @@ func (logger Logger) WithField(key string, value interface{}) Logger {
 // WithFields creates a new `Logger` with any existing fields
 // plus the new ones.
 func (logger Logger) WithFields(fields Fields) Logger {
-	l := Logger{logr: logger.logr}
+	if len(logger.typed) > 0 {
+		// add the fields after the typed fields so the order they were added
+		// in is kept.
+		return logger.With(sortedFields(fields)...)
+	}
+
+	l := Logger{logr: logger.logr, timer: logger.timer, cancel: logger.cancel, deadline: logger.deadline}
 	// if parent has no fields then avoid creating a new map.
 	oldLen := len(logger.fields)
 	if oldLen == 0 {
@@ -39,21 +72,81 @@ func (logger Logger) WithFields(fields Fields) Logger {
 	for k, v := range logger.fields {
 		l.fields[k] = v
 	}
+
+	policy := logger.conflictPolicy()
 	for k, v := range fields {
+		if _, exists := logger.fields[k]; exists {
+			switch policy {
+			case ConflictKeepParent:
+				continue
+			case ConflictError:
+				logger.logr.ReportError(fmt.Errorf("field %q conflicts with existing field", k))
+			case ConflictSuffix:
+				k = l.uniqueKey(k)
+			}
+		}
 		l.fields[k] = v
 	}
 	return l
 }
 
+// allFields returns the Logger's fields merged with its typed fields, with
+// typed fields taking precedence. The map is only copied if there are typed fields.
+func (logger Logger) allFields() Fields {
+	if len(logger.typed) == 0 {
+		return logger.fields
+	}
+	flds := make(Fields, len(logger.fields)+len(logger.typed))
+	for k, v := range logger.fields {
+		flds[k] = v
+	}
+	for _, f := range logger.typed {
+		flds[f.Key] = fieldValue(f)
+	}
+	return flds
+}
+
+// conflictPolicy returns the Logr's FieldConflictPolicy.
+func (logger Logger) conflictPolicy() FieldConflictPolicy {
+	if logger.logr == nil {
+		return ConflictOverwrite
+	}
+	return logger.logr.FieldConflictPolicy
+}
+
+// hasField returns true if the Logger has a field, typed or not, with the key.
+func (logger Logger) hasField(key string) bool {
+	if _, exists := logger.fields[key]; exists {
+		return true
+	}
+	return indexField(logger.typed, key) >= 0
+}
+
+// uniqueKey returns key with the smallest numeric suffix, starting at `_2`,
+// that is not the key of an existing field.
+func (logger Logger) uniqueKey(key string) string {
+	for i := 2; ; i++ {
+		k := fmt.Sprintf("%s_%d", key, i)
+		if !logger.hasField(k) {
+			return k
+		}
+	}
+}
+
 // Log checks that the level matches one or more targets, and
 // if so, generates a log record that is added to the Logr queue.
 // Arguments are handled in the manner of fmt.Print.
 func (logger Logger) Log(lvl Level, args ...interface{}) {
-	status := logger.logr.IsLevelEnabled(lvl)
-	if status.Enabled {
-		rec := NewLogRec(lvl, logger, "", args, status.Stacktrace)
-		logger.logr.enqueue(rec)
-	}
+	logger.log(lvl, "", args, false)
+}
+
+// LogOK is the same as `Log` but reports whether the log record was accepted
+// into the Logr queue. False is returned if the record was dropped because the
+// queue was full (see `Logr.OnQueueFull` and `Logr.EnqueueTimeout`), the Logr
+// is shut down, or the level is not enabled for any target. Use this for
+// critical log records that need a fallback when they cannot be logged.
+func (logger Logger) LogOK(lvl Level, args ...interface{}) bool {
+	return logger.log(lvl, "", args, false)
 }
 
 // Trace is a convenience method equivalent to `Log(TraceLevel, args...)`.
@@ -97,7 +190,7 @@ func (logger Logger) Fatal(args ...interface{}) {
 // followed by a call to panic().
 func (logger Logger) Panic(args ...interface{}) {
 	logger.Log(Panic, args...)
-	panic(fmt.Sprint(args...))
+	logger.logr.panic(fmt.Sprint(args...))
 }
 
 //
@@ -108,11 +201,7 @@ func (logger Logger) Panic(args ...interface{}) {
 // if so, generates a log record that is added to the main
 // queue (channel). Arguments are handled in the manner of fmt.Printf.
 func (logger Logger) Logf(lvl Level, format string, args ...interface{}) {
-	status := logger.logr.IsLevelEnabled(lvl)
-	if status.Enabled {
-		rec := NewLogRec(lvl, logger, format, args, status.Stacktrace)
-		logger.logr.enqueue(rec)
-	}
+	logger.log(lvl, format, args, false)
 }
 
 // Tracef is a convenience method equivalent to `Logf(TraceLevel, args...)`.
@@ -156,6 +245,7 @@ func (logger Logger) Fatalf(format string, args ...interface{}) {
 // followed by a call to panic().
 func (logger Logger) Panicf(format string, args ...interface{}) {
 	logger.Logf(Panic, format, args...)
+	logger.logr.panic(fmt.Sprintf(format, args...))
 }
 
 //
@@ -166,12 +256,7 @@ func (logger Logger) Panicf(format string, args ...interface{}) {
 // if so, generates a log record that is added to the main
 // queue (channel). Arguments are handled in the manner of fmt.Println.
 func (logger Logger) Logln(lvl Level, args ...interface{}) {
-	status := logger.logr.IsLevelEnabled(lvl)
-	if status.Enabled {
-		rec := NewLogRec(lvl, logger, "", args, status.Stacktrace)
-		rec.newline = true
-		logger.logr.enqueue(rec)
-	}
+	logger.log(lvl, "", args, true)
 }
 
 // Traceln is a convenience method equivalent to `Logln(TraceLevel, args...)`.
@@ -215,4 +300,30 @@ func (logger Logger) Fatalln(args ...interface{}) {
 // followed by a call to panic().
 func (logger Logger) Panicln(args ...interface{}) {
 	logger.Logln(Panic, args...)
+	logger.logr.panic(fmt.Sprintln(args...))
+}
+
+// log creates a log record and adds it to the Logr queue if the level is
+// enabled for at least one target. Logging after the Logr has been shut
+// down is a no-op, reported via `OnLoggerError`.
+func (logger Logger) log(lvl Level, template string, args []interface{}, newline bool) bool {
+	if logger.logr == nil {
+		return false
+	}
+	if logger.cancel != nil && logger.cancel.suppresses(lvl) {
+		return false
+	}
+	status := logger.logr.IsLevelEnabled(lvl)
+	if !status.Enabled {
+		if status.shutdown {
+			logger.logr.ReportError(ErrLoggerShutdown)
+		}
+		return false
+	}
+	rec := newLogRecWithStatus(lvl, logger, template, args, status)
+	rec.newline = newline
+	if logger.deadline > 0 {
+		rec.deadline = rec.time.Add(logger.deadline)
+	}
+	return logger.logr.enqueue(rec)
 }
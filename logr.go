@@ -6,25 +6,81 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/wiggin77/cfg"
 	"github.com/wiggin77/merror"
 )
 
+var (
+	// ErrLoggerShutdown is returned or reported when an operation is attempted
+	// on a Logr that has been shut down.
+	ErrLoggerShutdown = errors.New("logr is shut down")
+
+	// ErrDuplicateTarget is returned when adding a target that has already been added.
+	ErrDuplicateTarget = errors.New("target already added")
+
+	// ErrTargetNotFound is returned when removing a target that was never added.
+	ErrTargetNotFound = errors.New("target not found")
+
+	// ErrFormatTimeout is returned by `Basic.FormatRecord` when formatting a
+	// log record exceeds `Logr.FormatTimeout`.
+	ErrFormatTimeout = errors.New("formatter timed out")
+)
+
+// IsDuplicateTargetError returns true if err is, or contains, ErrDuplicateTarget.
+func IsDuplicateTargetError(err error) bool {
+	if errors.Is(err, ErrDuplicateTarget) {
+		return true
+	}
+	// if a multi-error, return true if any of the errors
+	// are ErrDuplicateTarget
+	if merr, ok := err.(*merror.MError); ok {
+		for _, e := range merr.Errors() {
+			if errors.Is(e, ErrDuplicateTarget) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Logr maintains a list of log targets and accepts incoming
 // log records.
 type Logr struct {
+	queueDrops uint64 // accessed atomically, keep first for alignment
+	accepted   uint64 // accessed atomically
+	sequence   int64  // accessed atomically
+
+	lastBlockedNotify int64 // unix nanos, accessed atomically
+	blockedProducers  int32 // accessed atomically
+	degraded          int32 // accessed atomically
+
+	droppedMux sync.Mutex
+	dropped    chan *LogRec
+
+	globals atomic.Value // []Field
+
 	tmux    sync.RWMutex // target mutex
 	targets []Target
+	subs    []*subscription
 
 	mux                sync.RWMutex
 	maxQueueSizeActual int
 	in                 chan *LogRec
+	inMux              sync.RWMutex // held for reading while sending to in
+	inClosed           bool
 	done               chan struct{}
 	once               sync.Once
 	shutdown           bool
+	shutdownDone       chan struct{}
+	shutdownErr        error
+	stopOnce           sync.Once
+	nop                bool
 	lvlCache           levelCache
 
 	metricsInitOnce  sync.Once
@@ -37,6 +93,9 @@ type Logr struct {
 
 	bufferPool sync.Pool
 
+	// time the first target was added, for lifecycle events.
+	started time.Time
+
 	// MaxQueueSize is the maximum number of log records that can be queued.
 	// If exceeded, `OnQueueFull` is called which determines if the log
 	// record will be dropped or block until add is successful.
@@ -71,15 +130,95 @@ type Logr struct {
 	OnExit func(code int)
 
 	// OnPanic, when not nil, is called when a PanicXXX style log API is called.
-	// When nil, then the default behavior is to cleanly shut down this Logr and
-	// call `panic(err)`.
+	// When nil, then the default behavior is to flush this Logr and call
+	// `panic(err)`. The Logr is not shut down so a recovered panic can still
+	// be logged.
 	OnPanic func(err interface{})
 
+	// DisableExit, when true, prevents FatalXXX style log APIs from calling
+	// `os.Exit`. The log record is still written and this Logr is flushed,
+	// then `OnExit` is called if not nil. Useful for libraries embedding
+	// logr that must not terminate the host process.
+	DisableExit bool
+
+	// DisablePanic, when true, prevents PanicXXX style log APIs from calling
+	// `panic`. The log record is still written and this Logr is flushed,
+	// then `OnPanic` is called if not nil.
+	DisablePanic bool
+
+	// FieldConflictPolicy determines what happens when `Logger.WithFields` adds
+	// a field whose key already exists in the parent Logger.
+	// Defaults to ConflictOverwrite.
+	FieldConflictPolicy FieldConflictPolicy
+
+	// FallbackFormatter, when not nil, is used by `Basic.FormatRecord` when a
+	// target's formatter returns an error, e.g. due to a failing custom
+	// marshaler, so the log record is still output rather than lost. The
+	// fallback formats a degraded copy of the log record whose only field is
+	// the formatter error, keyed by DefFormatErrorKey. The error is also
+	// reported via `OnLoggerError`. `&logr.DefaultFormatter{}` is a reasonable
+	// choice.
+	FallbackFormatter Formatter
+
+	// FormatTimeout, when greater than zero, is the maximum time a target's
+	// formatter may take to format a log record via `Basic.FormatRecord`, so a
+	// pathological formatter or expensive `Template` func cannot stall the
+	// target's queue. A log record exceeding it is abandoned, or output via
+	// FallbackFormatter if not nil, and ErrFormatTimeout is reported via
+	// `OnLoggerError`.
+	// A formatter call cannot be interrupted, so this bounds the damage rather
+	// than stopping the call: the formatter continues in its own goroutine and
+	// its output is discarded. While it continues, the target's log records are
+	// treated as timed out without waiting, so at most one goroutine per target
+	// is blocked by a stalled formatter.
+	// Each target formats log records in its own goroutine when set, which adds
+	// overhead. Defaults to zero, no timeout.
+	FormatTimeout time.Duration
+
+	// Clock, when not nil, returns the current time used for log record
+	// timestamps and elapsed times. Defaults to `time.Now`. Useful for tests.
+	// Set `StdFilter.Clock` to the same function for filters that use
+	// `StdFilter.Suppress`.
+	Clock func() time.Time
+
+	// EnableGoroutineID adds a field, keyed by GoroutineIDKey, to each log
+	// record containing the ID of the goroutine that made the logging call.
+	// This is a debugging aid for concurrency issues; obtaining the ID costs
+	// roughly a microsecond per log record, only paid for enabled levels.
+	EnableGoroutineID bool
+
+	// EnableSequence stamps each log record with a sequence number, starting
+	// at 1 and incremented atomically as log records are queued, which
+	// formatters output keyed by their KeySequence option. The sequence is
+	// unique per Logr and increases monotonically for each goroutine, so gaps
+	// downstream reveal dropped log records.
+	EnableSequence bool
+
+	// GoroutineIDKey is the field key used when EnableGoroutineID is true.
+	// Defaults to DefGoroutineIDKey.
+	GoroutineIDKey string
+
 	// EnqueueTimeout is the amount of time a log record can take to be queued.
 	// This only applies to blocking enqueue which happen after `logr.OnQueueFull`
-	// is called and returns false.
+	// is called and returns false. When it elapses the log record is dropped and
+	// counted (see `QueueFullDrops`), and further log records arriving to a full
+	// queue are dropped without blocking until the queue has room again, so a
+	// wedged target cannot stall producers indefinitely.
 	EnqueueTimeout time.Duration
 
+	// OnBlockedProducer, when not nil, is called when a logging call blocks
+	// because the Logr queue is full, at most once per
+	// DefBlockedProducerInterval, so operators can observe backpressure.
+	// It is passed the number of currently blocked producers and the maximum
+	// queue size, and is called from the blocked goroutine.
+	OnBlockedProducer func(blocked int, maxQueueSize int)
+
+	// OnLevelChange, when not nil, is called after `SetLevel` changes the
+	// level of a target, e.g. so a component can start or stop collecting
+	// expensive debug data. It is called without any locks held, from the
+	// goroutine calling `SetLevel`.
+	OnLevelChange func(target Target, old, new Level)
+
 	// ShutdownTimeout is the amount of time `logr.Shutdown` can execute before
 	// timing out.
 	ShutdownTimeout time.Duration
@@ -107,6 +246,13 @@ type Logr struct {
 	// MetricsUpdateFreqMillis determines how often polled metrics are updated
 	// when metrics are enabled.
 	MetricsUpdateFreqMillis int64
+
+	// LogLifecycleEvents, when true, outputs an Info level log record when the
+	// first target is added and another during `Shutdown`, including the
+	// uptime and number of log records accepted. A missing stopped record then
+	// indicates the process did not shut down cleanly. Lifecycle records have
+	// a DefLifecycleKey field and are formatted like any other log record.
+	LogLifecycleEvents bool
 }
 
 // Configure adds/removes targets via the supplied `Config`.
@@ -156,26 +302,49 @@ func (logr *Logr) ensureInit() {
 }
 
 // AddTarget adds one or more targets to the logger which will receive
-// log records for outputting.
+// log records for outputting. A target that has already been added is
+// skipped and `ErrDuplicateTarget` is included in the returned error.
 func (logr *Logr) AddTarget(targets ...Target) error {
 	if logr.IsShutdown() {
-		return fmt.Errorf("AddTarget called after Logr shut down")
+		return ErrLoggerShutdown
+	}
+	if logr.nop {
+		return nil
 	}
 
 	logr.ensureInit()
 	metrics := logr.getMetricsCollector()
+
+	var first bool
+	defer func() {
+		// log after the level cache is reset so the new targets receive it.
+		if first {
+			logr.logStarted()
+		}
+	}()
 	defer logr.ResetLevelCache() // call this after tmux is released
 
 	logr.tmux.Lock()
 	defer logr.tmux.Unlock()
 
+	// Shutdown may have completed since the check above; targets added now
+	// would never be shut down.
+	if logr.IsShutdown() {
+		return ErrLoggerShutdown
+	}
+
 	errs := merror.New()
 	for _, t := range targets {
 		if t == nil {
 			continue
 		}
+		if logr.hasTarget(t) {
+			errs.Append(fmt.Errorf("%w: %v", ErrDuplicateTarget, t))
+			continue
+		}
 
 		logr.targets = append(logr.targets, t)
+		sortTargets(logr.targets)
 		if metrics != nil {
 			if tm, ok := t.(TargetWithMetrics); ok {
 				if err := tm.EnableMetrics(metrics, logr.MetricsUpdateFreqMillis); err != nil {
@@ -184,9 +353,47 @@ func (logr *Logr) AddTarget(targets ...Target) error {
 			}
 		}
 	}
+	if logr.started.IsZero() && len(logr.targets) > 0 {
+		logr.started = logr.now()
+		first = true
+	}
 	return errs.ErrorOrNil()
 }
 
+// sortTargets orders targets by descending priority, preserving the order
+// in which targets of equal priority were added.
+func sortTargets(targets []Target) {
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targetPriority(targets[i]) > targetPriority(targets[j])
+	})
+}
+
+func targetPriority(target Target) int {
+	if p, ok := target.(Prioritizer); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// hasTarget returns true if the target has already been added.
+// tmux must be held before calling this function.
+func (logr *Logr) hasTarget(target Target) bool {
+	for _, t := range logr.targets {
+		if sameTarget(t, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTarget returns true if a and b are the same target instance.
+func sameTarget(a, b Target) bool {
+	if !reflect.TypeOf(a).Comparable() || reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	return a == b
+}
+
 // NewLogger creates a Logger using defaults. A `Logger` is light-weight
 // enough to create on-demand, but typically one or more Loggers are
 // created and re-used.
@@ -195,11 +402,25 @@ func (logr *Logr) NewLogger() Logger {
 	return logger
 }
 
-var levelStatusDisabled = LevelStatus{}
+var (
+	levelStatusDisabled = LevelStatus{}
+	levelStatusShutdown = LevelStatus{shutdown: true}
+)
+
+// now returns the current time via `Clock`, or `time.Now` if not set.
+func (logr *Logr) now() time.Time {
+	if logr == nil || logr.Clock == nil {
+		return time.Now()
+	}
+	return logr.Clock()
+}
 
 // IsLevelEnabled returns true if at least one target has the specified
 // level enabled. The result is cached so that subsequent checks are fast.
 func (logr *Logr) IsLevelEnabled(lvl Level) LevelStatus {
+	if logr.nop {
+		return levelStatusDisabled
+	}
 	status, ok := logr.isLevelEnabledFromCache(lvl)
 	if ok {
 		return status
@@ -212,8 +433,23 @@ func (logr *Logr) IsLevelEnabled(lvl Level) LevelStatus {
 		if e {
 			status.Enabled = true
 			if s {
+				var opts StacktraceOptions
+				if so, ok := t.(StacktraceOptioner); ok {
+					opts = so.StacktraceOptions(lvl)
+				}
+				if status.Stacktrace {
+					opts = mergeStacktraceOptions(status.StacktraceOptions, opts)
+				}
+				status.Stacktrace = true
+				status.StacktraceOptions = opts
+			}
+		}
+	}
+	for _, sub := range logr.subs {
+		if sub.filter.IsEnabled(lvl) {
+			status.Enabled = true
+			if forceStacktrace(lvl) || sub.filter.IsStacktraceEnabled(lvl) {
 				status.Stacktrace = true
-				break // if both enabled then no sense checking more targets
 			}
 		}
 	}
@@ -227,13 +463,32 @@ func (logr *Logr) IsLevelEnabled(lvl Level) LevelStatus {
 	return status
 }
 
+// mergeStacktraceOptions combines the stack trace requirements of two targets
+// such that both are satisfied.
+func mergeStacktraceOptions(a, b StacktraceOptions) StacktraceOptions {
+	depthA, depthB := a.MaxDepth, b.MaxDepth
+	if depthA == 0 {
+		depthA = DefaultMaxStackFrames
+	}
+	if depthB == 0 {
+		depthB = DefaultMaxStackFrames
+	}
+	if depthB > depthA {
+		depthA = depthB
+	}
+	return StacktraceOptions{
+		MaxDepth:      depthA,
+		AllGoroutines: a.AllGoroutines || b.AllGoroutines,
+	}
+}
+
 func (logr *Logr) isLevelEnabledFromCache(lvl Level) (LevelStatus, bool) {
 	logr.mux.RLock()
 	defer logr.mux.RUnlock()
 
 	// Don't accept new log records after shutdown.
 	if logr.shutdown {
-		return levelStatusDisabled, true
+		return levelStatusShutdown, true
 	}
 
 	// Check cache. lvlCache may still be nil if no targets added.
@@ -287,6 +542,55 @@ func (logr *Logr) TargetInfos() []TargetInfo {
 	return infos
 }
 
+// Reopen calls `Reopen` on each target that implements Reopener, so file
+// targets close and reopen their files, and returns any errors combined.
+func (logr *Logr) Reopen() error {
+	logr.tmux.RLock()
+	targets := make([]Target, len(logr.targets))
+	copy(targets, logr.targets)
+	logr.tmux.RUnlock()
+
+	errs := merror.New()
+	for _, t := range targets {
+		if r, ok := t.(Reopener); ok {
+			if err := r.Reopen(); err != nil {
+				errs.Append(fmt.Errorf("reopen target %v: %w", t, err))
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// EnabledLevels returns the levels currently enabled for the target,
+// including any custom levels the target supports via LevelLister.
+// Returns nil if the target has not been added to this Logr.
+func (logr *Logr) EnabledLevels(target Target) []Level {
+	logr.tmux.RLock()
+	found := logr.hasTarget(target)
+	logr.tmux.RUnlock()
+	if !found {
+		return nil
+	}
+
+	candidates := StandardLevels()
+	if ll, ok := target.(LevelLister); ok {
+		candidates = append(candidates, ll.Levels()...)
+	}
+
+	levels := make([]Level, 0, len(candidates))
+	seen := make(map[LevelID]struct{}, len(candidates))
+	for _, lvl := range candidates {
+		if _, ok := seen[lvl.ID]; ok {
+			continue
+		}
+		seen[lvl.ID] = struct{}{}
+		if enabled, _ := target.IsLevelEnabled(lvl); enabled {
+			levels = append(levels, lvl)
+		}
+	}
+	return levels
+}
+
 // RemoveTargets safely removes one or more targets based on the filtering method.
 // f should return true to delete the target, false to keep it.
 // When removing a target, best effort is made to write any queued log records before
@@ -328,6 +632,38 @@ func (logr *Logr) RemoveTargets(cxt context.Context, f func(ti TargetInfo) bool)
 	return errs.ErrorOrNil()
 }
 
+// RemoveTarget safely removes a single target, leaving any other targets
+// unaffected. Best effort is made to write any queued log records before
+// closing the target, with `logr.ShutdownTimeout` determining how much time
+// can be spent. Returns `ErrTargetNotFound` if the target was never added.
+func (logr *Logr) RemoveTarget(target Target) error {
+	logr.tmux.Lock()
+	var found bool
+	cp := make([]Target, 0, len(logr.targets))
+	for _, t := range logr.targets {
+		if !found && sameTarget(t, target) {
+			found = true
+			continue
+		}
+		cp = append(cp, t)
+	}
+	logr.targets = cp
+	logr.tmux.Unlock()
+
+	if !found {
+		return ErrTargetNotFound
+	}
+
+	// call this after tmux is released.
+	logr.ResetLevelCache()
+
+	// The target no longer receives log records and can be shut down
+	// without blocking logging.
+	ctx, cancel := context.WithTimeout(context.Background(), logr.shutdownTimeout())
+	defer cancel()
+	return target.Shutdown(ctx)
+}
+
 // ResetLevelCache resets the cached results of `IsLevelEnabled`. This is
 // called any time a Target is added or a target's level is changed.
 func (logr *Logr) ResetLevelCache() {
@@ -350,29 +686,82 @@ func (logr *Logr) resetLevelCache() {
 // enqueue adds a log record to the logr queue. If the queue is full then
 // this function either blocks or the log record is dropped, depending on
 // the result of calling `OnQueueFull`.
-func (logr *Logr) enqueue(rec *LogRec) {
+func (logr *Logr) enqueue(rec *LogRec) bool {
 	if logr.in == nil {
 		logr.ReportError(fmt.Errorf("AddTarget or Configure must be called before enqueue"))
+		return false
+	}
+
+	if rec.flush == nil {
+		if logr.EnableSequence {
+			rec.seq = atomic.AddInt64(&logr.sequence, 1)
+		}
+		logr.writeSynchronous(rec)
+	}
+	rec.enqueued = time.Now()
+
+	// Shutdown may close the queue between the level check and here, so it
+	// is held off while sending.
+	logr.inMux.RLock()
+	defer logr.inMux.RUnlock()
+	if logr.inClosed {
+		logr.ReportError(ErrLoggerShutdown)
+		return false
 	}
 
 	select {
 	case logr.in <- rec:
+		if logr.isDegraded() {
+			logr.setDegraded(false) // the queue has room again
+		}
 	default:
 		if logr.OnQueueFull != nil && logr.OnQueueFull(rec, logr.maxQueueSizeActual) {
-			return // drop the record
+			logr.dropQueueFull(rec)
+			return false // drop the record
+		}
+		if logr.isDegraded() {
+			logr.dropQueueFull(rec)
+			return false
 		}
+		timeout, byDeadline := rec.enqueueTimeout(logr.enqueueTimeout())
+		unblock := logr.beginBlocked()
+		defer unblock()
 		select {
-		case <-time.After(logr.enqueueTimeout()):
+		case <-time.After(timeout):
+			if byDeadline {
+				logr.dropQueueFull(rec)
+				return false
+			}
+			logr.setDegraded(true)
+			logr.dropQueueFull(rec)
 			logr.ReportError(fmt.Errorf("enqueue timed out for log rec [%v]", rec))
+			return false
 		case logr.in <- rec: // block until success or timeout
 		}
 	}
+	if rec.flush == nil {
+		atomic.AddUint64(&logr.accepted, 1)
+	}
+	return true
 }
 
-// exit is called by one of the FatalXXX style APIS. If `logr.OnExit` is not nil
-// then that method is called, otherwise the default behavior is to shut down this
-// Logr cleanly then call `os.Exit(code)`.
+// exit is called by one of the FatalXXX style APIS. If `logr.DisableExit` is true
+// then this Logr is flushed and `logr.OnExit` is called if not nil. Otherwise if
+// `logr.OnExit` is not nil then that method is called, otherwise the default
+// behavior is to shut down this Logr cleanly then call `os.Exit(code)`.
 func (logr *Logr) exit(code int) {
+	if logr == nil {
+		os.Exit(code)
+	}
+	if logr.DisableExit {
+		if err := logr.Flush(); err != nil {
+			logr.ReportError(err)
+		}
+		if logr.OnExit != nil {
+			logr.OnExit(code)
+		}
+		return
+	}
 	if logr.OnExit != nil {
 		logr.OnExit(code)
 		return
@@ -384,16 +773,29 @@ func (logr *Logr) exit(code int) {
 	os.Exit(code)
 }
 
-// panic is called by one of the PanicXXX style APIS. If `logr.OnPanic` is not nil
-// then that method is called, otherwise the default behavior is to shut down this
-// Logr cleanly then call `panic(err)`.
+// panic is called by one of the PanicXXX style APIS. If `logr.DisablePanic` is true
+// then this Logr is flushed and `logr.OnPanic` is called if not nil. Otherwise if
+// `logr.OnPanic` is not nil then that method is called, otherwise the default
+// behavior is to flush this Logr then call `panic(err)`.
 func (logr *Logr) panic(err interface{}) {
+	if logr == nil {
+		panic(err)
+	}
+	if logr.DisablePanic {
+		if err := logr.Flush(); err != nil {
+			logr.ReportError(err)
+		}
+		if logr.OnPanic != nil {
+			logr.OnPanic(err)
+		}
+		return
+	}
 	if logr.OnPanic != nil {
 		logr.OnPanic(err)
 		return
 	}
 
-	if err := logr.Shutdown(); err != nil {
+	if err := logr.Flush(); err != nil {
 		logr.ReportError(err)
 	}
 	panic(err)
@@ -422,7 +824,7 @@ func (logr *Logr) FlushWithTimeout(ctx context.Context) error {
 	}
 
 	if logr.IsShutdown() {
-		return errors.New("Flush called on shut down Logr")
+		return ErrLoggerShutdown
 	}
 
 	rec := newFlushLogRec(logr.NewLogger())
@@ -448,9 +850,14 @@ func (logr *Logr) IsShutdown() bool {
 // Shutdown cleanly stops the logging engine after making best efforts
 // to flush all targets. Call this function right before application
 // exit - logr cannot be restarted once shut down.
+// Targets are shut down one at a time, in reverse of the order in which they
+// receive log records: lowest priority first, then most recently added first.
 // `logr.ShutdownTimeout` determines how long shutdown can execute before
 // timing out. Use `IsTimeoutError` to determine if the returned error is
 // due to a timeout.
+//
+// Shutdown is idempotent: calls made while or after another call shuts down
+// wait for it to complete and return its result.
 func (logr *Logr) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), logr.shutdownTimeout())
 	defer cancel()
@@ -461,17 +868,40 @@ func (logr *Logr) Shutdown() error {
 // to flush all targets. Call this function right before application
 // exit - logr cannot be restarted once shut down.
 // Use `IsTimeoutError` to determine if the returned error is due to a
-// timeout.
+// timeout. Like `Shutdown`, calls after the first wait for it to complete, or
+// for ctx to be done, and return its result.
 func (logr *Logr) ShutdownWithTimeout(ctx context.Context) error {
+	logr.stopOnce.Do(logr.logStopped)
+
 	logr.mux.Lock()
 	if logr.shutdown {
+		done := logr.shutdownDone
 		logr.mux.Unlock()
-		return errors.New("Shutdown called again after shut down")
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return newTimeoutError("timeout waiting for shutdown in progress")
+		}
+		logr.mux.RLock()
+		defer logr.mux.RUnlock()
+		return logr.shutdownErr
 	}
 	logr.shutdown = true
+	logr.shutdownDone = make(chan struct{})
 	logr.resetLevelCache()
 	logr.mux.Unlock()
 
+	err := logr.shutdownTargets(ctx)
+
+	logr.mux.Lock()
+	logr.shutdownErr = err
+	close(logr.shutdownDone)
+	logr.mux.Unlock()
+	return err
+}
+
+// shutdownTargets drains the Logr queue and shuts down all targets.
+func (logr *Logr) shutdownTargets(ctx context.Context) error {
 	logr.metricsCloseOnce.Do(func() {
 		if logr.metricsDone != nil {
 			close(logr.metricsDone)
@@ -482,7 +912,10 @@ func (logr *Logr) ShutdownWithTimeout(ctx context.Context) error {
 
 	// close the incoming channel and wait for read loop to exit.
 	if logr.in != nil {
+		logr.inMux.Lock()
+		logr.inClosed = true
 		close(logr.in)
+		logr.inMux.Unlock()
 		select {
 		case <-ctx.Done():
 			errs.Append(newTimeoutError("logr queue shutdown timeout"))
@@ -492,10 +925,14 @@ func (logr *Logr) ShutdownWithTimeout(ctx context.Context) error {
 
 	// logr.in channel should now be drained to targets and no more log records
 	// can be added.
+	logr.closeSubscriptions()
+
+	// shut down targets one at a time, in reverse of the order they receive
+	// log records, so final flushes don't interleave.
 	logr.tmux.RLock()
 	defer logr.tmux.RUnlock()
-	for _, t := range logr.targets {
-		err := t.Shutdown(ctx)
+	for i := len(logr.targets) - 1; i >= 0; i-- {
+		err := logr.targets[i].Shutdown(ctx)
 		if err != nil {
 			errs.Append(err)
 		}
@@ -513,6 +950,10 @@ func (logr *Logr) ReportError(err interface{}) {
 		fmt.Fprintln(os.Stderr, err)
 		return
 	}
+	if e, ok := err.(error); ok {
+		logr.OnLoggerError(e)
+		return
+	}
 	logr.OnLoggerError(fmt.Errorf("%v", err))
 }
 
@@ -607,6 +1048,56 @@ func (logr *Logr) getMetricsUpdateFreqMillis() int64 {
 	return logr.MetricsUpdateFreqMillis
 }
 
+// synchronousTarget is implemented by targets that can write log records in
+// the goroutine making the logging call. See `Basic.SetSynchronous`.
+type synchronousTarget interface {
+	IsSynchronous() bool
+}
+
+func isSynchronous(target Target) bool {
+	st, ok := target.(synchronousTarget)
+	return ok && st.IsSynchronous()
+}
+
+// writeSynchronous writes the log record to all synchronous targets, in the
+// calling goroutine. Queued targets receive the log record via fanout.
+func (logr *Logr) writeSynchronous(rec *LogRec) {
+	var target Target
+	defer func() {
+		if r := recover(); r != nil {
+			logr.ReportError(fmt.Errorf("synchronous write failed for target %s, %v", target, r))
+		}
+	}()
+
+	logr.tmux.RLock()
+	defer logr.tmux.RUnlock()
+	for _, target = range logr.targets {
+		if !isSynchronous(target) {
+			continue
+		}
+		if enabled, _ := target.IsLevelEnabled(rec.Level()); !enabled {
+			continue
+		}
+		rec.prep()
+		if accepts(target, rec) {
+			rec.syncLogged = true
+			target.Log(rec)
+		}
+	}
+}
+
+// accepts returns true unless the log record, whose level is enabled for the
+// target or filter v, is suppressed or rejected by a RecordFilter.
+func accepts(v interface{}, rec *LogRec) bool {
+	if isSuppressed(v, rec.Level(), rec.Time()) {
+		return false
+	}
+	if rf, ok := v.(RecordFilter); ok {
+		return rf.IsRecordEnabled(rec)
+	}
+	return true
+}
+
 // fanout pushes a LogRec to all targets.
 func (logr *Logr) fanout(rec *LogRec) {
 	var target Target
@@ -616,7 +1107,7 @@ func (logr *Logr) fanout(rec *LogRec) {
 		}
 	}()
 
-	var logged bool
+	logged := rec.syncLogged
 	defer func() {
 		if logged {
 			logr.incLoggedCounter() // call this after tmux is released
@@ -626,11 +1117,16 @@ func (logr *Logr) fanout(rec *LogRec) {
 	logr.tmux.RLock()
 	defer logr.tmux.RUnlock()
 	for _, target = range logr.targets {
-		if enabled, _ := target.IsLevelEnabled(rec.Level()); enabled {
+		if isSynchronous(target) {
+			continue // already written by writeSynchronous
+		}
+		if enabled, _ := target.IsLevelEnabled(rec.Level()); enabled && accepts(target, rec) {
 			target.Log(rec)
 			logged = true
 		}
 	}
+	target = nil
+	logr.publish(rec)
 }
 
 // flush drains the queue and notifies when done.
@@ -3,14 +3,21 @@ package logr_test
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/francoispqt/gojay"
 	"github.com/mattermost/logr"
 	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
 	"github.com/mattermost/logr/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFlush(t *testing.T) {
@@ -128,10 +135,10 @@ func TestLogAfterShutdown(t *testing.T) {
 	logger := lgr.NewLogger().WithField("test", "yes")
 	logger.Info("This shouldn't get logged")
 
-	// Second shutdown should error, but not crash.
+	// Second shutdown returns the first call's result, and shouldn't crash.
 	err = lgr.Shutdown()
-	if err == nil {
-		t.Errorf("Expected error calling shutdown after shutdown")
+	if err != nil {
+		t.Errorf("Expected second shutdown to return the first result, got %v", err)
 	}
 
 	output := buf.String()
@@ -180,3 +187,1091 @@ func TestRemoveTarget(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestAddTargetAfterShutdown(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+	lgr := &logr.Logr{}
+
+	err := lgr.AddTarget(test.NewSlowTarget(filter, formatter, &bytes.Buffer{}, 100))
+	require.NoError(t, err)
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	err = lgr.AddTarget(test.NewSlowTarget(filter, formatter, &bytes.Buffer{}, 100))
+	assert.True(t, errors.Is(err, logr.ErrLoggerShutdown))
+}
+
+// shutdownTracker records whether the target was shut down.
+type shutdownTracker struct {
+	*target.Writer
+	shutdown int32
+}
+
+func (st *shutdownTracker) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&st.shutdown, 1)
+	return st.Writer.Shutdown(ctx)
+}
+
+func TestAddTargetDuringShutdown(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+
+	for i := 0; i < 50; i++ {
+		lgr := &logr.Logr{}
+		err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, &test.Buffer{}, 100))
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = lgr.Shutdown()
+		}()
+
+		tgt := &shutdownTracker{Writer: target.NewWriterTarget(filter, formatter, &test.Buffer{}, 100)}
+		err = lgr.AddTarget(tgt)
+		<-done
+
+		// a target accepted during shutdown must still be shut down.
+		if err == nil {
+			assert.EqualValues(t, 1, atomic.LoadInt32(&tgt.shutdown))
+		} else {
+			assert.True(t, errors.Is(err, logr.ErrLoggerShutdown))
+		}
+	}
+}
+
+func TestLogDuringShutdown(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+	lgr := &logr.Logr{}
+
+	var other int32
+	lgr.OnLoggerError = func(err error) {
+		if !errors.Is(err, logr.ErrLoggerShutdown) {
+			atomic.AddInt32(&other, 1)
+		}
+	}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, &test.Buffer{}, 100))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	logger := lgr.NewLogger()
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				logger.Info("racing shutdown")
+			}
+		}()
+	}
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+	wg.Wait()
+
+	assert.EqualValues(t, 0, atomic.LoadInt32(&other))
+}
+
+func TestLogAfterShutdownReportsError(t *testing.T) {
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+	lgr := &logr.Logr{}
+
+	var count int32
+	lgr.OnLoggerError = func(err error) {
+		if errors.Is(err, logr.ErrLoggerShutdown) {
+			atomic.AddInt32(&count, 1)
+		}
+	}
+
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	assert.NotPanics(t, func() {
+		logger.Info("This shouldn't get logged")
+		logger.Errorf("Nor %s", "this")
+		logger.Warnln("Nor this")
+	})
+
+	assert.EqualValues(t, 3, atomic.LoadInt32(&count))
+	assert.NotContains(t, buf.String(), "shouldn't get logged")
+}
+
+func TestAddTargetDuplicate(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+	buf := &test.Buffer{}
+	tgt := target.NewWriterTarget(filter, formatter, buf, 100)
+
+	lgr := &logr.Logr{}
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+
+	err = lgr.AddTarget(tgt)
+	assert.True(t, logr.IsDuplicateTargetError(err), "got %v", err)
+	assert.Len(t, lgr.TargetInfos(), 1)
+
+	lgr.NewLogger().Info("only once")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, strings.Count(buf.String(), "only once"))
+}
+
+func TestRemoveSingleTarget(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+
+	buf1 := &test.Buffer{}
+	target1 := test.NewSlowTarget(filter, formatter, buf1, 3000)
+	target1.Delay = time.Millisecond * 2
+
+	buf2 := &test.Buffer{}
+	target2 := test.NewSlowTarget(filter, formatter, buf2, 3000)
+	target2.Delay = time.Millisecond * 2
+
+	lgr := &logr.Logr{}
+	err := lgr.AddTarget(target1, target2)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Info("before removal")
+
+	err = lgr.Flush()
+	require.NoError(t, err)
+
+	err = lgr.RemoveTarget(target2)
+	require.NoError(t, err)
+
+	err = lgr.RemoveTarget(target2)
+	assert.Equal(t, logr.ErrTargetNotFound, err)
+
+	logger.Info("after removal")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Contains(t, buf1.String(), "before removal")
+	assert.Contains(t, buf1.String(), "after removal")
+	assert.Contains(t, buf2.String(), "before removal")
+	assert.NotContains(t, buf2.String(), "after removal")
+}
+
+// blockingWriter blocks all writes until unblocked.
+type blockingWriter struct {
+	unblock chan struct{}
+}
+
+func (w blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	return len(p), nil
+}
+
+func TestLogOK(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+	lgr := &logr.Logr{
+		MaxQueueSize: 1,
+		OnQueueFull: func(rec *logr.LogRec, maxQueueSize int) bool {
+			return true // drop
+		},
+	}
+
+	w := blockingWriter{unblock: make(chan struct{})}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, w, 1))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	assert.False(t, logger.LogOK(logr.Debug, "level not enabled"))
+	assert.True(t, logger.LogOK(logr.Info, "accepted"))
+
+	// fill the Logr and target queues until a record is dropped.
+	dropped := false
+	for i := 0; i < 100 && !dropped; i++ {
+		dropped = !logger.LogOK(logr.Info, "filling queue")
+	}
+	assert.True(t, dropped, "expected a record to be dropped")
+
+	close(w.unblock)
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.False(t, logger.LogOK(logr.Info, "after shutdown"))
+}
+
+func TestTargetPriority(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, DisableLevel: true}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	lgr := &logr.Logr{}
+
+	var mux sync.Mutex
+	var order []string
+	recorder := func(name string) func([]byte, *logr.LogRec) {
+		return func(formatted []byte, rec *logr.LogRec) {
+			mux.Lock()
+			defer mux.Unlock()
+			order = append(order, name+":"+rec.Msg())
+		}
+	}
+
+	remote := target.NewFuncTarget(filter, formatter, recorder("remote"), 100)
+	remote.SetName("remote")
+	durable := target.NewFuncTarget(filter, formatter, recorder("durable"), 100)
+	durable.SetName("durable")
+	durable.SetPriority(10)
+	durable.SetSynchronous(true)
+
+	err := lgr.AddTarget(remote, durable)
+	require.NoError(t, err)
+
+	infos := lgr.TargetInfos()
+	require.Len(t, infos, 2)
+	assert.Equal(t, "durable", infos[0].Name)
+	assert.Equal(t, "remote", infos[1].Name)
+
+	logger := lgr.NewLogger()
+	logger.Info("one")
+	logger.Info("two")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	mux.Lock()
+	defer mux.Unlock()
+	require.Len(t, order, 4)
+	indexOf := func(s string) int {
+		for i, o := range order {
+			if o == s {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, msg := range []string{"one", "two"} {
+		assert.True(t, indexOf("durable:"+msg) < indexOf("remote:"+msg), "durable target must be written first")
+	}
+	assert.Equal(t, "durable:one", order[0])
+}
+
+func TestDisableExitAndPanic(t *testing.T) {
+	var exitCode int
+	var panicErr interface{}
+	lgr := &logr.Logr{
+		DisableExit:  true,
+		DisablePanic: true,
+		OnExit:       func(code int) { exitCode = code },
+		OnPanic:      func(err interface{}) { panicErr = err },
+	}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+
+	logger.Fatal("fatal msg")
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, buf.String(), "fatal msg", "log record should be flushed before OnExit")
+
+	assert.NotPanics(t, func() { logger.Panicf("panic %d", 42) })
+	assert.Equal(t, "panic 42", panicErr)
+	assert.Contains(t, buf.String(), "panic 42")
+
+	// Logr remains usable.
+	logger.Info("still logging")
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "still logging")
+}
+
+func TestPanic(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	assert.PanicsWithValue(t, "boom", func() { logger.Panic("boom") })
+	assert.Contains(t, buf.String(), "boom")
+}
+
+func TestFieldConflictPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   logr.FieldConflictPolicy
+		expected string
+		errors   int
+	}{
+		{name: "overwrite", policy: logr.ConflictOverwrite, expected: "id=child"},
+		{name: "keep parent", policy: logr.ConflictKeepParent, expected: "id=parent"},
+		{name: "error", policy: logr.ConflictError, expected: "id=child", errors: 1},
+		{name: "suffix", policy: logr.ConflictSuffix, expected: "id=parent id_2=child id_3=grandchild"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errCount int32
+			lgr := &logr.Logr{
+				FieldConflictPolicy: tt.policy,
+				OnLoggerError:       func(err error) { atomic.AddInt32(&errCount, 1) },
+			}
+			buf := &test.Buffer{}
+			formatter := &format.Plain{DisableTimestamp: true, DisableLevel: true, Delim: " | "}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+			require.NoError(t, err)
+
+			logger := lgr.NewLogger().WithField("id", "parent")
+			logger = logger.WithFields(logr.Fields{"id": "child"})
+			if tt.policy == logr.ConflictSuffix {
+				logger = logger.WithField("id", "grandchild")
+			}
+			logger.Info("msg")
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, "msg | "+tt.expected+"\n", buf.String())
+			assert.Equal(t, int32(tt.errors), atomic.LoadInt32(&errCount))
+		})
+	}
+}
+
+func TestNop(t *testing.T) {
+	lgr := logr.Nop()
+	assert.True(t, lgr.IsNop())
+
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Trace, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, &format.Plain{}, buf, 100))
+	require.NoError(t, err)
+
+	for _, lvl := range logr.StandardLevels() {
+		assert.False(t, lgr.IsLevelEnabled(lvl).Enabled, lvl.Name)
+	}
+
+	logger := logr.NopLogger().WithField("name", "nop")
+	logger.Error("discarded")
+	assert.False(t, logger.LogOK(logr.Error, "discarded"))
+	lgr.NewLogger().Info("discarded")
+
+	assert.Zero(t, testing.AllocsPerRun(100, func() { logger.Info() }))
+
+	err = lgr.Flush()
+	require.NoError(t, err)
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestWithTimer(t *testing.T) {
+	var mux sync.Mutex
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	advance := func(d time.Duration) {
+		mux.Lock()
+		defer mux.Unlock()
+		now = now.Add(d)
+	}
+
+	lgr := &logr.Logr{
+		Clock: func() time.Time {
+			mux.Lock()
+			defer mux.Unlock()
+			return now
+		},
+	}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, DisableLevel: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger().WithTimer()
+	advance(1500 * time.Millisecond)
+	logger.WithField("phase", "load").Info("one")
+	advance(time.Second)
+	logger.Info("two")
+	logger.Mark()
+	advance(300 * time.Millisecond)
+	logger.Info("three")
+	lgr.NewLogger().Info("no timer")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	expected := "one | elapsed=1.5s phase=load\n" +
+		"two | elapsed=2.5s\n" +
+		"three | elapsed=300ms\n" +
+		"no timer | \n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestSuppress(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Info("before")
+
+	filter.Suppress(logr.Info, 50*time.Millisecond)
+	filter.Suppress(logr.Debug, time.Hour)
+	assert.True(t, filter.IsSuppressed(logr.Info, time.Now()))
+	logger.Info("suppressed")
+	logger.Debug("suppressed")
+	logger.Warn("not suppressed")
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, filter.IsSuppressed(logr.Info, time.Now()))
+	logger.Info("expired")
+
+	filter.Unsuppress(logr.Debug)
+	logger.Debug("unsuppressed")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	expected := "info | before | \n" +
+		"warn | not suppressed | \n" +
+		"info | expired | \n" +
+		"debug | unsuppressed | \n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestSuppressClock(t *testing.T) {
+	now := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	lgr := &logr.Logr{Clock: clock}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic, Clock: clock}
+	tgt := target.NewWriterTarget(filter, formatter, buf, 100)
+	tgt.SetSynchronous(true)
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	filter.Suppress(logr.Info, time.Minute)
+	logger.Info("suppressed")
+
+	now = now.Add(time.Minute)
+	logger.Info("expired")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, "info | expired | \n", buf.String())
+}
+
+func TestSuppressCombined(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	combined := logr.AndFilter(filter, &logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic})
+	err := lgr.AddTarget(target.NewWriterTarget(combined, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			logger.Warn("not suppressed")
+		}
+	}()
+	// suppress while the target is reading the filter.
+	filter.Suppress(logr.Info, time.Hour)
+	logger.Info("suppressed")
+	wg.Wait()
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.NotContains(t, buf.String(), "info |")
+	assert.Equal(t, 100, strings.Count(buf.String(), "warn | not suppressed |"))
+}
+
+type failingFormatter struct{}
+
+func (f failingFormatter) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	buf.WriteString("partial output")
+	return nil, errors.New("cannot format")
+}
+
+type panickingMarshaler struct{}
+
+func (m panickingMarshaler) MarshalJSONObject(enc *gojay.Encoder) {
+	panic("bad marshaler")
+}
+
+func (m panickingMarshaler) IsNil() bool {
+	return false
+}
+
+func TestFallbackFormatter(t *testing.T) {
+	var errCount int32
+	lgr := &logr.Logr{
+		FallbackFormatter: &format.Plain{DisableTimestamp: true, Delim: " | "},
+		OnLoggerError:     func(err error) { atomic.AddInt32(&errCount, 1) },
+	}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	failBuf := &test.Buffer{}
+	jsonBuf := &test.Buffer{}
+	err := lgr.AddTarget(
+		target.NewWriterTarget(filter, failingFormatter{}, failBuf, 100),
+		target.NewWriterTarget(filter, &format.JSON{DisableTimestamp: true}, jsonBuf, 100),
+	)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.With(logr.Object("obj", panickingMarshaler{})).Info("degraded")
+	logger.Info("fine")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, "info | degraded | format_error=\"cannot format\"\n"+
+		"info | fine | format_error=\"cannot format\"\n", failBuf.String())
+	assert.Equal(t, "info | degraded | format_error=\"formatter panic: bad marshaler\"\n"+
+		`{"level":"info","msg":"fine"}`+"\n", jsonBuf.String())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&errCount))
+}
+
+func TestFormatterUnwrapped(t *testing.T) {
+	lgr := &logr.Logr{
+		FallbackFormatter: &logr.DefaultFormatter{},
+		FormatTimeout:     time.Second,
+	}
+	formatter := &format.JSON{DisableTimestamp: true}
+	tgt := target.NewWriterTarget(nil, formatter, &test.Buffer{}, 100)
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+	defer lgr.Shutdown()
+
+	json, ok := tgt.Formatter().(*format.JSON)
+	require.True(t, ok, "expected *format.JSON, got %T", tgt.Formatter())
+	assert.True(t, formatter == json)
+	assert.Equal(t, "application/json", logr.ContentTypeOf(tgt.Formatter()))
+}
+
+// stallingFormatter blocks formatting log records with message "stall" until
+// release is closed, counting the calls blocked.
+type stallingFormatter struct {
+	release chan struct{}
+	stalled *int32
+}
+
+func (f stallingFormatter) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	if rec.Msg() == "stall" {
+		atomic.AddInt32(f.stalled, 1)
+		<-f.release
+	}
+	buf.WriteString(rec.Msg())
+	buf.WriteString("\n")
+	return buf, nil
+}
+
+func TestFormatTimeout(t *testing.T) {
+	var mux sync.Mutex
+	var errs []error
+	lgr := &logr.Logr{
+		FormatTimeout: 50 * time.Millisecond,
+		OnLoggerError: func(err error) {
+			mux.Lock()
+			defer mux.Unlock()
+			errs = append(errs, err)
+		},
+	}
+	release := make(chan struct{})
+	var stalled int32
+
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	buf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, stallingFormatter{release: release, stalled: &stalled}, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Info("before")
+	logger.Info("stall")
+	logger.Info("stall")
+	logger.Info("skipped")
+
+	err = lgr.Flush()
+	require.NoError(t, err)
+
+	// records are skipped while the stalled call continues, so only one
+	// formatter call is ever blocked.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&stalled))
+
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+	logger.Info("after")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	// the stalled log record is abandoned and the queue keeps draining.
+	assert.Equal(t, "before\nafter\n", buf.String())
+
+	mux.Lock()
+	defer mux.Unlock()
+	require.Len(t, errs, 3)
+	for _, err := range errs {
+		assert.True(t, errors.Is(err, logr.ErrFormatTimeout), err.Error())
+	}
+}
+
+func TestSynchronousTarget(t *testing.T) {
+	lgr := &logr.Logr{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+
+	syncBuf := &test.Buffer{}
+	syncTarget := target.NewWriterTarget(filter, formatter, syncBuf, 100)
+	syncTarget.SetSynchronous(true)
+	asyncBuf := &test.Buffer{}
+	err := lgr.AddTarget(syncTarget, target.NewWriterTarget(filter, formatter, asyncBuf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Info("one")
+	// output is visible as soon as the logging call returns.
+	assert.Equal(t, "info | one | \n", syncBuf.String())
+	logger.Debug("not enabled")
+	logger.Warn("two")
+	assert.Equal(t, "info | one | \nwarn | two | \n", syncBuf.String())
+
+	// flushing waits for the queued target without deadlocking on the
+	// synchronous one.
+	err = lgr.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, "info | one | \nwarn | two | \n", asyncBuf.String())
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	// each target receives each log record exactly once.
+	assert.Equal(t, "info | one | \nwarn | two | \n", syncBuf.String())
+	assert.Equal(t, "info | one | \nwarn | two | \n", asyncBuf.String())
+}
+
+func TestSamplingFilterNeverSampleAbove(t *testing.T) {
+	stdFilter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	tests := []struct {
+		name   string
+		filter *logr.SamplingFilter
+	}{
+		{
+			name:   "constructor",
+			filter: logr.NewSamplingFilter(stdFilter, 10),
+		},
+		{
+			// a zero NeverSampleAbove defaults to Error.
+			name:   "literal",
+			filter: &logr.SamplingFilter{Filter: stdFilter, Thereafter: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+			tt.filter.Tick = time.Hour
+			tt.filter.First = 5
+			err := lgr.AddTarget(target.NewWriterTarget(tt.filter, formatter, buf, 1000))
+			require.NoError(t, err)
+
+			logger := lgr.NewLogger()
+			for i := 0; i < 100; i++ {
+				logger.Error("disk full")
+				logger.Info("cache miss")
+			}
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			output := buf.String()
+			assert.Equal(t, 100, strings.Count(output, "error | disk full"))
+			assert.Equal(t, 5+9, strings.Count(output, "info | cache miss"))
+		})
+	}
+}
+
+type shutdownRecorder struct {
+	*target.Writer
+	name  string
+	mux   *sync.Mutex
+	order *[]string
+}
+
+func (sr shutdownRecorder) Shutdown(ctx context.Context) error {
+	sr.mux.Lock()
+	*sr.order = append(*sr.order, sr.name)
+	sr.mux.Unlock()
+	return sr.Writer.Shutdown(ctx)
+}
+
+func TestShutdownOrder(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true}
+
+	var mux sync.Mutex
+	var order []string
+	newTarget := func(name string, priority int) shutdownRecorder {
+		w := target.NewWriterTarget(filter, formatter, &test.Buffer{}, 100)
+		w.SetPriority(priority)
+		return shutdownRecorder{Writer: w, name: name, mux: &mux, order: &order}
+	}
+
+	err := lgr.AddTarget(newTarget("first", 0), newTarget("second", 0), newTarget("high", 10))
+	require.NoError(t, err)
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	mux.Lock()
+	defer mux.Unlock()
+	assert.Equal(t, []string{"second", "first", "high"}, order)
+}
+
+func TestGoroutineID(t *testing.T) {
+	lgr := &logr.Logr{EnableGoroutineID: true, GoroutineIDKey: "gid"}
+	formatter := &format.Plain{DisableTimestamp: true}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+
+	var mux sync.Mutex
+	ids := make(map[string]int64)
+	fn := func(formatted []byte, rec *logr.LogRec) {
+		mux.Lock()
+		defer mux.Unlock()
+		f, ok := rec.Fields()["gid"].(logr.Field)
+		require.True(t, ok, "gid field missing")
+		ids[rec.Msg()] = f.Integer
+	}
+	err := lgr.AddTarget(target.NewFuncTarget(filter, formatter, fn, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Info("main")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		logger.Info("other")
+	}()
+	wg.Wait()
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	mux.Lock()
+	defer mux.Unlock()
+	require.Len(t, ids, 2)
+	assert.NotZero(t, ids["main"])
+	assert.NotZero(t, ids["other"])
+	assert.NotEqual(t, ids["main"], ids["other"])
+}
+
+func TestClone(t *testing.T) {
+	lgr := &logr.Logr{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	buf := &test.Buffer{}
+	tgt := target.NewWriterTarget(filter, formatter, buf, 1000)
+	tgt.SetName("main")
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+
+	_, err = lgr.Clone()
+	require.Error(t, err, "sharing writers must be explicit")
+
+	cloneBuf := &test.Buffer{}
+	clone, err := lgr.Clone(logr.CloneWriter(tgt, cloneBuf))
+	require.NoError(t, err)
+
+	infos := clone.TargetInfos()
+	require.Len(t, infos, 1)
+	assert.Equal(t, "main", infos[0].Name)
+
+	lgr.NewLogger().Info("original")
+	clone.NewLogger().WithField("tenant", "acme").Info("clone")
+	clone.NewLogger().Debug("filtered")
+
+	// shutting down the clone does not affect the original.
+	err = clone.Shutdown()
+	require.NoError(t, err)
+	lgr.NewLogger().Info("still running")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, "info | original | \ninfo | still running | \n", buf.String())
+	assert.Equal(t, "info | clone | tenant=acme\n", cloneBuf.String())
+}
+
+func TestDroppedRecords(t *testing.T) {
+	lgr := &logr.Logr{}
+	lgr.OnTargetQueueFull = func(target logr.Target, rec *logr.LogRec, maxQueueSize int) bool {
+		return true // drop
+	}
+	dropped := lgr.DroppedRecords()
+
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true}
+	tgt := test.NewSlowTarget(filter, formatter, &test.Buffer{}, 1)
+	tgt.Delay = time.Millisecond * 50
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger().WithField("id", 7)
+	for i := 0; i < 10; i++ {
+		logger.Infof("record %d", i)
+	}
+
+	select {
+	case rec := <-dropped:
+		assert.Contains(t, rec.Msg(), "record ")
+		assert.Equal(t, 7, rec.Fields()["id"])
+	case <-time.After(time.Second * 5):
+		t.Error("timed out waiting for dropped record")
+	}
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	// the channel is bounded and never blocks logging.
+	assert.True(t, len(dropped) <= logr.DefaultDroppedRecordsBuffer)
+}
+
+// templateFormatter outputs the message template and args of each log record.
+type templateFormatter struct{}
+
+func (f templateFormatter) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	if buf == nil {
+		buf = &bytes.Buffer{}
+	}
+	fmt.Fprintf(buf, "%q %v %q\n", rec.Template(), rec.Args(), rec.Msg())
+	return buf, nil
+}
+
+func TestLogRecTemplateArgs(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, templateFormatter{}, buf, 1000))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Infof("user %s logged in from %d", "bob", 7)
+	logger.Infof("user %s logged in from %d", "alice", 9)
+	logger.Info("no", "template")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	want := `"user %s logged in from %d" [bob 7] "user bob logged in from 7"` + "\n" +
+		`"user %s logged in from %d" [alice 9] "user alice logged in from 9"` + "\n" +
+		`"" [no template] "notemplate"` + "\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestStdFilterExact(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Warn, Stacktrace: logr.Panic, Exact: true}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Error("error")
+	logger.Warn("warn")
+	logger.Info("info")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, "warn | warn | \n", buf.String())
+}
+
+func TestLoggerWithDeadline(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	fastBuf := &test.Buffer{}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, fastBuf, 100))
+	require.NoError(t, err)
+
+	slowBuf := &test.Buffer{}
+	slow := test.NewSlowTarget(filter, formatter, slowBuf, 1)
+	slow.Delay = time.Millisecond * 50
+	err = lgr.AddTarget(slow)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger().WithDeadline(time.Millisecond * 5)
+	_, ok := logr.NewLogRec(logr.Info, lgr.NewLogger(), "", nil, false).Deadline()
+	assert.False(t, ok)
+
+	const count = 10
+	start := time.Now()
+	for i := 0; i < count; i++ {
+		logger.Infof("record %d", i)
+	}
+	err = lgr.Flush()
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second, "deadline should bound blocking")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, count, strings.Count(fastBuf.String(), "record"), "fast target gets all records")
+	slowCount := strings.Count(slowBuf.String(), "record")
+	assert.True(t, slowCount >= 1 && slowCount < count, "slow target should drop late records, got %d", slowCount)
+}
+
+func TestLogLifecycleEvents(t *testing.T) {
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	var mux sync.Mutex
+	lgr := &logr.Logr{
+		LogLifecycleEvents: true,
+		Clock: func() time.Time {
+			mux.Lock()
+			defer mux.Unlock()
+			return now
+		},
+	}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	// only the first target add starts logging.
+	err = lgr.AddTarget(target.NewWriterTarget(filter, formatter, &test.Buffer{}, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Info("one")
+	logger.Info("two")
+
+	mux.Lock()
+	now = now.Add(time.Millisecond * 1500)
+	mux.Unlock()
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	want := "info | logging started | lifecycle=started\n" +
+		"info | one | \n" +
+		"info | two | \n" +
+		"info | logging stopped | lifecycle=stopped records=3 uptime=1.5s\n"
+	assert.Equal(t, want, buf.String())
+	assert.Equal(t, uint64(4), lgr.RecordsAccepted())
+}
+
+func TestShutdownConcurrent(t *testing.T) {
+	lgr := &logr.Logr{LogLifecycleEvents: true}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	slow := test.NewSlowTarget(filter, formatter, buf, 100)
+	slow.Delay = time.Millisecond
+	err := lgr.AddTarget(slow)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	for i := 0; i < 20; i++ {
+		logger.Info("record")
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- lgr.Shutdown()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, lgr.Shutdown())
+	assert.Equal(t, 20, strings.Count(buf.String(), "info | record"))
+	assert.Equal(t, 1, strings.Count(buf.String(), "logging stopped"))
+}
+
+func TestSetLevelOnLevelChange(t *testing.T) {
+	type change struct {
+		target   logr.Target
+		old, new logr.Level
+	}
+	changes := make(chan change, 10)
+
+	lgr := &logr.Logr{}
+	lgr.OnLevelChange = func(target logr.Target, old, new logr.Level) {
+		// safe to use the Logr; no locks are held.
+		assert.True(t, lgr.IsLevelEnabled(new).Enabled)
+		changes <- change{target: target, old: old, new: new}
+	}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	tgt := target.NewWriterTarget(filter, formatter, buf, 100)
+	tgt.SetSynchronous(true)
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Debug("hidden")
+
+	err = lgr.SetLevel(tgt, logr.Debug)
+	require.NoError(t, err)
+	logger.Debug("shown")
+
+	// unchanged level does not notify.
+	err = lgr.SetLevel(tgt, logr.Debug)
+	require.NoError(t, err)
+
+	other := target.NewWriterTarget(filter, formatter, buf, 100)
+	assert.Equal(t, logr.ErrTargetNotFound, lgr.SetLevel(other, logr.Debug))
+	other.Shutdown(context.Background())
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+	close(changes)
+
+	var got []change
+	for c := range changes {
+		got = append(got, c)
+	}
+	require.Len(t, got, 1)
+	assert.Equal(t, logr.Target(tgt), got[0].target)
+	assert.Equal(t, logr.Info, got[0].old)
+	assert.Equal(t, logr.Debug, got[0].new)
+	assert.Equal(t, "debug | shown | \n", buf.String())
+	assert.Equal(t, logr.Info, filter.Lvl, "caller's filter is not modified")
+}
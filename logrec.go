@@ -2,7 +2,9 @@ package logr
 
 import (
 	"fmt"
+	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -12,22 +14,30 @@ var (
 	logrPkg string
 )
 
+const (
+	// stackFrameSlack is the number of extra stack frames captured to allow
+	// for leading logr frames that are removed by `prep`.
+	stackFrameSlack = 10
+
+	// maxAllStacksBytes caps the size of an all goroutines stack dump.
+	maxAllStacksBytes = 8 * 1024 * 1024
+)
+
 func init() {
 	// Calc current package name
-	pcs := make([]uintptr, 2)
-	_ = runtime.Callers(0, pcs)
-	tmp := runtime.FuncForPC(pcs[1]).Name()
-	logrPkg = getPackageName(tmp)
+	logrPkg = reflect.TypeOf(LogRec{}).PkgPath()
 }
 
 // LogRec collects raw, unformatted data to be logged.
 // TODO:  pool these?  how to reliably know when targets are done with them? Copy for each target?
 type LogRec struct {
-	mux  sync.RWMutex
-	time time.Time
+	mux      sync.RWMutex
+	time     time.Time
+	enqueued time.Time
 
-	level  Level
-	logger Logger
+	level   Level
+	logger  Logger
+	globals []Field
 
 	template string
 	newline  bool
@@ -35,25 +45,92 @@ type LogRec struct {
 
 	stackPC    []uintptr
 	stackCount int
+	maxFrames  int
+	allStacks  []byte
 
 	// flushes Logr and target queues when not nil.
 	flush chan struct{}
 
+	// set when written to synchronous targets by the logging goroutine.
+	syncLogged bool
+
+	// set via `Logger.WithDeadline`; zero for no deadline.
+	deadline time.Time
+
+	// set when queued if `Logr.EnableSequence` is true; zero for none.
+	seq int64
+
+	// set for log records of recovered panics, see `Logger.RecoverAndLog`.
+	recovered bool
+
 	// remaining fields calculated by `prep`
-	msg    string
-	frames []runtime.Frame
+	prepped bool
+	msg     string
+	frames  []runtime.Frame
+
+	// fields merged with typed fields, calculated by `Fields`.
+	fields Fields
 }
 
 // NewLogRec creates a new LogRec with the current time and optional stack trace.
 func NewLogRec(lvl Level, logger Logger, template string, args []interface{}, incStacktrace bool) *LogRec {
-	rec := &LogRec{time: time.Now(), logger: logger, level: lvl, template: template, args: args}
+	rec := &LogRec{time: logger.logr.now(), logger: logger, level: lvl, template: template, args: args}
+	rec.addElapsed()
+	rec.addGoroutineID()
+	rec.addCancelled()
+	rec.addGlobalFields()
 	if incStacktrace {
-		rec.stackPC = make([]uintptr, DefaultMaxStackFrames)
-		rec.stackCount = runtime.Callers(2, rec.stackPC)
+		rec.captureStack(StacktraceOptions{})
+	}
+	return rec
+}
+
+// newLogRecWithStatus creates a new LogRec with the current time, capturing a
+// stack trace per the level status.
+func newLogRecWithStatus(lvl Level, logger Logger, template string, args []interface{}, status LevelStatus) *LogRec {
+	rec := &LogRec{time: logger.logr.now(), logger: logger, level: lvl, template: template, args: args}
+	rec.addElapsed()
+	rec.addGoroutineID()
+	rec.addCancelled()
+	rec.addGlobalFields()
+	if status.Stacktrace {
+		rec.captureStack(status.StacktraceOptions)
 	}
 	return rec
 }
 
+// captureStack captures the current goroutine's stack, and optionally the
+// stacks of all goroutines.
+func (rec *LogRec) captureStack(opts StacktraceOptions) {
+	rec.maxFrames = opts.MaxDepth
+	if rec.maxFrames <= 0 {
+		rec.maxFrames = DefaultMaxStackFrames
+	}
+	rec.stackPC = make([]uintptr, rec.maxFrames+stackFrameSlack)
+	rec.stackCount = runtime.Callers(3, rec.stackPC)
+
+	if opts.AllGoroutines {
+		rec.allStacks = captureAllStacks()
+	}
+}
+
+// hasStack returns true if a stack trace was captured for this log record.
+func (rec *LogRec) hasStack() bool {
+	return rec.stackCount > 0 || len(rec.allStacks) > 0
+}
+
+// captureAllStacks returns a dump of all goroutine stacks.
+func captureAllStacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxAllStacksBytes {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
 // newFlushLogRec creates a LogRec that flushes the Logr queue and
 // any target queues that support flushing.
 func newFlushLogRec(logger Logger) *LogRec {
@@ -66,6 +143,11 @@ func (rec *LogRec) prep() {
 	rec.mux.Lock()
 	defer rec.mux.Unlock()
 
+	if rec.prepped {
+		return
+	}
+	rec.prepped = true
+
 	// resolve args
 	if rec.template == "" {
 		if rec.newline {
@@ -98,6 +180,13 @@ func (rec *LogRec) prep() {
 			}
 		}
 		rec.frames = rec.frames[start:]
+		if rec.recovered {
+			rec.frames = trimPanicFrames(rec.frames)
+		}
+
+		if rec.maxFrames > 0 && len(rec.frames) > rec.maxFrames {
+			rec.frames = rec.frames[:rec.maxFrames]
+		}
 	}
 }
 
@@ -110,18 +199,39 @@ func (rec *LogRec) WithTime(time time.Time) *LogRec {
 
 	return &LogRec{
 		time:       time,
+		enqueued:   rec.enqueued,
 		level:      rec.level,
 		logger:     rec.logger,
+		globals:    rec.globals,
 		template:   rec.template,
 		newline:    rec.newline,
 		args:       rec.args,
+		prepped:    rec.prepped,
 		msg:        rec.msg,
 		stackPC:    rec.stackPC,
 		stackCount: rec.stackCount,
+		maxFrames:  rec.maxFrames,
+		allStacks:  rec.allStacks,
 		frames:     rec.frames,
+		recovered:  rec.recovered,
+		deadline:   rec.deadline,
+		seq:        rec.seq,
 	}
 }
 
+// Sequence returns the sequence number of this log record and true, or false
+// if `Logr.EnableSequence` was not set when it was queued.
+func (rec *LogRec) Sequence() (int64, bool) {
+	// no locking needed as this field is set before the record is shared.
+	return rec.seq, rec.seq != 0
+}
+
+// IsFlush returns true if this log record is a request to flush queued log
+// records, rather than a log record to output. See `Queue`.
+func (rec *LogRec) IsFlush() bool {
+	return rec.flush != nil
+}
+
 // Logger returns the `Logger` that created this `LogRec`.
 func (rec *LogRec) Logger() Logger {
 	return rec.logger
@@ -133,16 +243,172 @@ func (rec *LogRec) Time() time.Time {
 	return rec.time
 }
 
+// EnqueueTime returns the time this log record was added to the Logr queue.
+func (rec *LogRec) EnqueueTime() time.Time {
+	// no locking needed as this field is not mutated after enqueue.
+	return rec.enqueued
+}
+
+// Template returns the format template used to create this log record's
+// message, or an empty string if no template was used (e.g. `Logger.Info`).
+func (rec *LogRec) Template() string {
+	// no locking needed as this field is not mutated.
+	return rec.template
+}
+
+// Args returns the arguments used to create this log record's message, either
+// with `Template` or, when no template was used, in the manner of fmt.Print.
+// Together with `Template` this allows formatters to group log records by
+// template rather than by interpolated message. The returned slice must not
+// be modified.
+func (rec *LogRec) Args() []interface{} {
+	// no locking needed as this field is not mutated.
+	return rec.args
+}
+
 // Level returns this log record's Level.
 func (rec *LogRec) Level() Level {
 	// no locking needed as this field is not mutated.
 	return rec.level
 }
 
-// Fields returns this log record's Fields.
+// Fields returns this log record's Fields, including any typed fields added
+// via `Logger.With` and any global fields set via `Logr.SetGlobalFields`.
+// When there are typed or global fields they are merged into a new map, so
+// formatters should use `EachField` instead.
 func (rec *LogRec) Fields() Fields {
+	// no locking needed as the logger and globals are not mutated.
+	if len(rec.logger.typed) == 0 && len(rec.globals) == 0 {
+		return rec.logger.fields
+	}
+
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+	if rec.fields == nil {
+		rec.fields = rec.mergeFields()
+	}
+	return rec.fields
+}
+
+// mergeFields returns the Logr's global fields merged with the Logger's fields,
+// which take precedence.
+func (rec *LogRec) mergeFields() Fields {
+	if len(rec.globals) == 0 {
+		return rec.logger.allFields()
+	}
+	flds := make(Fields, len(rec.globals)+len(rec.logger.fields)+len(rec.logger.typed))
+	for _, f := range rec.globals {
+		flds[f.Key] = fieldValue(f)
+	}
+	for k, v := range rec.logger.fields {
+		flds[k] = v
+	}
+	for _, f := range rec.logger.typed {
+		flds[f.Key] = fieldValue(f)
+	}
+	return flds
+}
+
+// HasFields returns true if this log record has any fields, including typed
+// and global fields.
+func (rec *LogRec) HasFields() bool {
+	// no locking needed as the logger and globals are not mutated.
+	return len(rec.logger.fields) > 0 || len(rec.logger.typed) > 0 || len(rec.globals) > 0
+}
+
+// EachField calls fn for each of this log record's fields, sorted by key,
+// without merging them into a map. The fields are the same as those returned
+// by `Fields`. See `EachFieldInOrder` to keep the order typed fields were
+// added in.
+func (rec *LogRec) EachField(fn func(key string, val interface{})) {
+	// no locking needed as the logger and globals are not mutated.
+	fields := rec.logger.fields
+	typed := rec.logger.typed
+
+	keys := make([]string, 0, len(fields)+len(typed)+len(rec.globals))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	for _, f := range typed {
+		if _, ok := fields[f.Key]; !ok {
+			keys = append(keys, f.Key)
+		}
+	}
+	for i, f := range rec.globals {
+		if _, ok := fields[f.Key]; ok || indexField(typed, f.Key) >= 0 || indexField(rec.globals[i+1:], f.Key) >= 0 {
+			continue
+		}
+		keys = append(keys, f.Key)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		val, _ := rec.LookupField(k)
+		fn(k, val)
+	}
+}
+
+// EachFieldInOrder calls fn for each of this log record's fields, without
+// merging them into a map: global fields in the order they were set, then
+// fields added via `Logger.WithFields` sorted by key, then typed fields added
+// via `Logger.With` in the order they were added. A field is skipped when one
+// that follows it has the same key, so the fields are the same as those
+// returned by `Fields`.
+func (rec *LogRec) EachFieldInOrder(fn func(key string, val interface{})) {
+	// no locking needed as the logger and globals are not mutated.
+	fields := rec.logger.fields
+	typed := rec.logger.typed
+
+	for i, f := range rec.globals {
+		if _, ok := fields[f.Key]; ok || indexField(typed, f.Key) >= 0 || indexField(rec.globals[i+1:], f.Key) >= 0 {
+			continue
+		}
+		fn(f.Key, fieldValue(f))
+	}
+
+	if len(fields) > 0 {
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			if indexField(typed, k) < 0 {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fn(k, fields[k])
+		}
+	}
+
+	for _, f := range typed {
+		fn(f.Key, fieldValue(f))
+	}
+}
+
+// LookupField returns the value of the field with the specified key, with the
+// same precedence as `Fields`, without merging the fields into a map.
+func (rec *LogRec) LookupField(key string) (interface{}, bool) {
+	// no locking needed as the logger and globals are not mutated.
+	if i := indexField(rec.logger.typed, key); i >= 0 {
+		return fieldValue(rec.logger.typed[i]), true
+	}
+	if val, ok := rec.logger.fields[key]; ok {
+		return val, true
+	}
+	for i := len(rec.globals) - 1; i >= 0; i-- {
+		if rec.globals[i].Key == key {
+			return fieldValue(rec.globals[i]), true
+		}
+	}
+	return nil, false
+}
+
+// TypedFields returns the typed fields added via `Logger.With`, and fields
+// added via `Logger.WithFields` after them, in the order they were added.
+// These are also included in `Fields`. The returned slice must not be
+// modified.
+func (rec *LogRec) TypedFields() []Field {
 	// no locking needed as this field is not mutated.
-	return rec.logger.fields
+	return rec.logger.typed
 }
 
 // Msg returns this log record's message text.
@@ -152,6 +418,13 @@ func (rec *LogRec) Msg() string {
 	return rec.msg
 }
 
+// Newline returns true if this log record was created by one of the LoglnXXX
+// style APIs, in which case `Msg` ends with a newline.
+func (rec *LogRec) Newline() bool {
+	// no locking needed as this field is not mutated.
+	return rec.newline
+}
+
 // StackFrames returns this log record's stack frames or
 // nil if no stack trace was required.
 func (rec *LogRec) StackFrames() []runtime.Frame {
@@ -160,6 +433,13 @@ func (rec *LogRec) StackFrames() []runtime.Frame {
 	return rec.frames
 }
 
+// AllStacks returns a dump of all goroutine stacks captured for this log
+// record, or nil if not required. See `StacktraceOptions.AllGoroutines`.
+func (rec *LogRec) AllStacks() []byte {
+	// no locking needed as this field is not mutated.
+	return rec.allStacks
+}
+
 // String returns a string representation of this log record.
 func (rec *LogRec) String() string {
 	if rec.flush != nil {
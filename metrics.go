@@ -30,6 +30,13 @@ type Gauge interface {
 	Sub(float64)
 }
 
+// Histogram is a metrics sink that samples observations into buckets.
+// Implementations are external to Logr and provided via `QueueLatencyCollector`.
+type Histogram interface {
+	// Observe adds a single observation to the histogram.
+	Observe(float64)
+}
+
 // MetricsCollector provides a way for users of this Logr package to have metrics pushed
 // in an efficient way to any backend, e.g. Prometheus.
 // For each target added to Logr, the supplied MetricsCollector will provide a Gauge
@@ -47,6 +54,14 @@ type MetricsCollector interface {
 	BlockedCounter(target string) (Counter, error)
 }
 
+// QueueLatencyCollector can optionally be implemented by a MetricsCollector to
+// receive, per target, the time in seconds each log record waited between being
+// added to the Logr queue and being dequeued by the target for writing.
+type QueueLatencyCollector interface {
+	// QueueLatencyHistogram returns a Histogram that will be updated by the named target.
+	QueueLatencyHistogram(target string) (Histogram, error)
+}
+
 // TargetWithMetrics is a target that provides metrics.
 type TargetWithMetrics interface {
 	EnableMetrics(collector MetricsCollector, updateFreqMillis int64) error
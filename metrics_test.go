@@ -2,7 +2,9 @@ package logr_test
 
 import (
 	"bytes"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mattermost/logr"
 	"github.com/mattermost/logr/format"
@@ -169,8 +171,45 @@ func TestLogr_SetMetricsCollector(t *testing.T) {
 		require.EqualValues(t, 2, metricsTarget1.Logged)
 		require.EqualValues(t, 2, metricsTarget2.Logged)
 
+		require.EqualValues(t, 2, metricsTarget1.Latencies)
+		require.EqualValues(t, 2, metricsTarget2.Latencies)
+
 		require.EqualValues(t, 0, metricsLogr.Errors)
 		require.EqualValues(t, 0, metricsTarget1.Errors)
 		require.EqualValues(t, 0, metricsTarget2.Errors)
 	})
 }
+
+func TestBasic_SetOnDequeueLatency(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+
+	lgr := &logr.Logr{}
+	tgt := test.NewSlowTarget(filter, formatter, &bytes.Buffer{}, 100)
+	tgt.Delay = time.Millisecond * 20
+
+	var mux sync.Mutex
+	var waits []time.Duration
+	tgt.SetOnDequeueLatency(func(d time.Duration) {
+		mux.Lock()
+		defer mux.Unlock()
+		waits = append(waits, d)
+	})
+
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	for i := 0; i < 5; i++ {
+		logger.Info("Houston, we have a problem.")
+	}
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	mux.Lock()
+	defer mux.Unlock()
+	require.Len(t, waits, 5)
+	// the last record had to wait for the slow target to write the first four.
+	require.True(t, waits[4] >= tgt.Delay*3, "last wait %v", waits[4])
+}
@@ -0,0 +1,22 @@
+package logr
+
+// Nop returns a Logr that discards all log records. Every level is reported as
+// disabled so log records are never built or queued, making logging calls
+// essentially free. Targets added to it are ignored.
+//
+// This is the recommended default for libraries that accept a `*Logr` or
+// `Logger` from their consumer, avoiding nil checks wherever logging occurs.
+func Nop() *Logr {
+	return &Logr{nop: true}
+}
+
+// NopLogger returns a Logger, created from a Logr returned by `Nop`, that
+// discards all log records.
+func NopLogger() Logger {
+	return Nop().NewLogger()
+}
+
+// IsNop returns true if this Logr was created via `Nop`.
+func (logr *Logr) IsNop() bool {
+	return logr.nop
+}
@@ -0,0 +1,90 @@
+package logr
+
+import "time"
+
+// Queue holds log records between a target's `Log` method and the goroutine
+// that writes them. The default, created by `NewChannelQueue`, is a bounded
+// FIFO channel. Custom implementations, such as an unbounded queue capped by
+// memory or a queue ordered by level, can be used by passing a QueueFactory to
+// `Basic.StartWithQueue`.
+//
+// Implementations must honor the following:
+//   - Enqueue may be called by any number of goroutines concurrently with a
+//     single goroutine calling Dequeue and Len.
+//   - A log record accepted by Enqueue must be returned by Dequeue exactly
+//     once; records can only be lost by Enqueue returning false.
+//   - Log records may be reordered, e.g. by level, but a flush request (see
+//     `LogRec.IsFlush`) must not be dequeued before any record enqueued ahead
+//     of it, otherwise `Logr.Flush` can return before those records are written.
+//   - Flush requests should always be accepted; if dropped, `Logr.Flush` waits
+//     until its timeout.
+type Queue interface {
+	// Enqueue adds a log record to the queue. If the queue is full, Enqueue
+	// blocks for up to timeout waiting for room; a timeout of zero or less
+	// does not block. Returns false if the log record was not added.
+	// Enqueue is not called after Close.
+	Enqueue(rec *LogRec, timeout time.Duration) bool
+
+	// Dequeue removes and returns the next log record, blocking until one is
+	// available. Returns false once the queue is closed and empty.
+	Dequeue() (*LogRec, bool)
+
+	// Len returns the number of log records in the queue.
+	Len() int
+
+	// Close indicates no more log records will be enqueued. Log records
+	// already in the queue are still returned by Dequeue.
+	Close()
+}
+
+// QueueFactory creates a Queue holding up to maxQueued log records. Each
+// target gets its own Queue; see `Basic.StartWithQueue`.
+type QueueFactory func(maxQueued int) Queue
+
+// channelQueue is a bounded FIFO Queue backed by a buffered channel.
+type channelQueue struct {
+	ch chan *LogRec
+}
+
+// NewChannelQueue creates a bounded FIFO Queue, backed by a buffered channel,
+// holding up to maxQueued log records. This is the default Queue for targets.
+func NewChannelQueue(maxQueued int) Queue {
+	return channelQueue{ch: make(chan *LogRec, maxQueued)}
+}
+
+// Enqueue adds a log record, waiting up to timeout if the queue is full.
+func (q channelQueue) Enqueue(rec *LogRec, timeout time.Duration) bool {
+	select {
+	case q.ch <- rec:
+		return true
+	default:
+	}
+	if timeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case q.ch <- rec:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Dequeue removes the oldest log record, blocking until one is available.
+func (q channelQueue) Dequeue() (*LogRec, bool) {
+	rec, ok := <-q.ch
+	return rec, ok
+}
+
+// Len returns the number of log records in the queue.
+func (q channelQueue) Len() int {
+	return len(q.ch)
+}
+
+// Close closes the channel.
+func (q channelQueue) Close() {
+	close(q.ch)
+}
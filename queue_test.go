@@ -0,0 +1,156 @@
+package logr_test
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// levelQueue is a Queue that dequeues the most severe log record first,
+// treating flush requests as barriers.
+type levelQueue struct {
+	mux    sync.Mutex
+	cond   *sync.Cond
+	recs   []*logr.LogRec
+	max    int
+	closed bool
+}
+
+func newLevelQueue(maxQueued int) logr.Queue {
+	q := &levelQueue{max: maxQueued}
+	q.cond = sync.NewCond(&q.mux)
+	return q
+}
+
+func (q *levelQueue) Enqueue(rec *logr.LogRec, timeout time.Duration) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if len(q.recs) >= q.max && !rec.IsFlush() {
+		return false
+	}
+	q.recs = append(q.recs, rec)
+	q.cond.Signal()
+	return true
+}
+
+func (q *levelQueue) Dequeue() (*logr.LogRec, bool) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	for len(q.recs) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.recs) == 0 {
+		return nil, false
+	}
+	best := 0
+	for i, rec := range q.recs {
+		if rec.IsFlush() {
+			break // records after a flush request must wait for it
+		}
+		if rec.Level().ID < q.recs[best].Level().ID {
+			best = i
+		}
+	}
+	rec := q.recs[best]
+	q.recs = append(q.recs[:best], q.recs[best+1:]...)
+	return rec, true
+}
+
+func (q *levelQueue) Len() int {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	return len(q.recs)
+}
+
+func (q *levelQueue) Close() {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// gatedTarget writes log record messages once the gate is opened.
+type gatedTarget struct {
+	logr.Basic
+	gate chan struct{}
+	mux  sync.Mutex
+	msgs []string
+}
+
+func (gt *gatedTarget) Write(rec *logr.LogRec) error {
+	<-gt.gate
+	gt.mux.Lock()
+	defer gt.mux.Unlock()
+	gt.msgs = append(gt.msgs, rec.Msg())
+	return nil
+}
+
+func (gt *gatedTarget) CloneTarget(out io.Writer) (logr.Target, error) {
+	clone := &gatedTarget{gate: gt.gate}
+	clone.StartClone(&gt.Basic, clone, clone)
+	return clone, nil
+}
+
+func TestQueueFactory(t *testing.T) {
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	gt := &gatedTarget{gate: make(chan struct{})}
+	gt.StartWithQueue(gt, gt, filter, nil, newLevelQueue, 100)
+
+	lgr := &logr.Logr{}
+	err := lgr.AddTarget(gt)
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Info("blocker") // dequeued immediately, blocking the writer
+	time.Sleep(50 * time.Millisecond)
+	logger.Info("info")
+	logger.Warn("warn")
+	logger.Error("error")
+
+	flushed := make(chan error)
+	go func() { flushed <- lgr.Flush() }()
+	time.Sleep(50 * time.Millisecond)
+	logger.Error("after flush")
+	close(gt.gate)
+	require.NoError(t, <-flushed)
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	gt.mux.Lock()
+	defer gt.mux.Unlock()
+	assert.Equal(t, []string{"blocker", "error", "warn", "info", "after flush"}, gt.msgs)
+}
+
+func TestQueueFactoryClone(t *testing.T) {
+	var created int32
+	factory := func(maxQueued int) logr.Queue {
+		atomic.AddInt32(&created, 1)
+		return newLevelQueue(maxQueued)
+	}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	gt := &gatedTarget{gate: make(chan struct{})}
+	gt.StartWithQueue(gt, gt, filter, nil, factory, 100)
+	close(gt.gate)
+
+	lgr := &logr.Logr{}
+	err := lgr.AddTarget(gt)
+	require.NoError(t, err)
+
+	// the clone's queue is created by the same factory.
+	clone, err := lgr.Clone(logr.CloneShareWriters())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&created))
+
+	clone.NewLogger().Info("clone")
+	err = clone.Shutdown()
+	require.NoError(t, err)
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+}
@@ -0,0 +1,84 @@
+package logr
+
+import (
+	"runtime"
+	"strings"
+)
+
+const (
+	// DefPanicKey is the field key for the recovered panic value in log
+	// records created by `Logger.RecoverAndLog`.
+	DefPanicKey = "panic"
+
+	// recoveredMsg is the message of log records created by `Logger.RecoverAndLog`.
+	recoveredMsg = "recovered from panic"
+)
+
+// RecoverAndLog recovers from a panic, if any, and logs it at Error level with
+// the panic value in a field keyed by DefPanicKey. The panic is not propagated.
+// It must be deferred directly, e.g. `defer logger.RecoverAndLog()`.
+//
+// A stack trace starting at the site of the panic, rather than the deferred
+// call, is captured and is output by targets whose filter enables stack traces
+// for the level.
+func (logger Logger) RecoverAndLog() {
+	if r := recover(); r != nil {
+		logger.logRecovered(Error, r)
+	}
+}
+
+// RecoverAndLogLevel is the same as `RecoverAndLog` but logs at the specified
+// level and, if repanic is true, flushes the Logr and panics again with the
+// same value once the log record is written.
+// It must be deferred directly, e.g. `defer logger.RecoverAndLogLevel(logr.Fatal, true)`.
+func (logger Logger) RecoverAndLogLevel(lvl Level, repanic bool) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	logger.logRecovered(lvl, r)
+	if repanic {
+		if logger.logr != nil {
+			_ = logger.logr.Flush()
+		}
+		panic(r)
+	}
+}
+
+// logRecovered logs a recovered panic value, capturing the stack of the
+// panicking goroutine.
+func (logger Logger) logRecovered(lvl Level, val interface{}) {
+	if logger.logr == nil {
+		return
+	}
+	status := logger.logr.IsLevelEnabled(lvl)
+	if !status.Enabled {
+		if status.shutdown {
+			logger.logr.ReportError(ErrLoggerShutdown)
+		}
+		return
+	}
+	status.Stacktrace = true
+
+	rec := newLogRecWithStatus(lvl, logger.WithField(DefPanicKey, val), "", []interface{}{recoveredMsg}, status)
+	rec.recovered = true
+	logger.logr.enqueue(rec)
+}
+
+// trimPanicFrames removes the frames preceding the panic site, namely those of
+// the deferred recovery and the runtime's panic handling, so the stack trace
+// starts where the panic occurred.
+func trimPanicFrames(frames []runtime.Frame) []runtime.Frame {
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i].Function != "runtime.gopanic" {
+			continue
+		}
+		// skip runtime frames such as runtime.panicmem for runtime errors.
+		i++
+		for i < len(frames) && strings.HasPrefix(frames[i].Function, "runtime.") {
+			i++
+		}
+		return frames[i:]
+	}
+	return frames
+}
@@ -0,0 +1,134 @@
+package logr
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefSamplingTick is the default interval over which SamplingFilter
+	// counts identical log records.
+	DefSamplingTick = time.Second
+
+	// DefSamplingFirst is the default number of identical log records
+	// output per tick by SamplingFilter before sampling starts.
+	DefSamplingFirst = 100
+)
+
+// SamplingFilter wraps a Filter, rate-limiting identical log records.
+// Records are identical when they have the same level and message template,
+// or message when no template was used. Within each tick the first `First`
+// identical records are output, then every `Thereafter`th record; zero
+// `Thereafter` drops the rest of the tick.
+//
+// Records at `NeverSampleAbove` or more severe are never sampled, so errors
+// are not lost while the same message is being rate-limited.
+//
+// A SamplingFilter keeps counts, so each target should be given its own
+// SamplingFilter. Use `NewSamplingFilter` to create one.
+type SamplingFilter struct {
+	Filter     Filter
+	Tick       time.Duration
+	First      uint64
+	Thereafter uint64
+
+	// NeverSampleAbove is the least severe level that is never sampled.
+	// Defaults to Error when left as the zero Level; use Panic to sample
+	// all other levels.
+	NeverSampleAbove Level
+
+	mux     sync.Mutex
+	resetAt time.Time
+	counts  map[sampleKey]uint64
+}
+
+type sampleKey struct {
+	lvl LevelID
+	msg string
+}
+
+// NewSamplingFilter creates a SamplingFilter wrapping filter, with default
+// `Tick` and `First`, and `NeverSampleAbove` set to Error.
+func NewSamplingFilter(filter Filter, thereafter uint64) *SamplingFilter {
+	return &SamplingFilter{
+		Filter:           filter,
+		Tick:             DefSamplingTick,
+		First:            DefSamplingFirst,
+		Thereafter:       thereafter,
+		NeverSampleAbove: Error,
+	}
+}
+
+// IsEnabled returns true if the wrapped filter enables the level.
+func (sf *SamplingFilter) IsEnabled(lvl Level) bool {
+	return sf.Filter.IsEnabled(lvl)
+}
+
+// IsStacktraceEnabled returns true if the wrapped filter requires a stack
+// trace for the level.
+func (sf *SamplingFilter) IsStacktraceEnabled(lvl Level) bool {
+	return sf.Filter.IsStacktraceEnabled(lvl)
+}
+
+// StacktraceOptions returns the wrapped filter's stack trace options.
+func (sf *SamplingFilter) StacktraceOptions(lvl Level) StacktraceOptions {
+	if so, ok := sf.Filter.(StacktraceOptioner); ok {
+		return so.StacktraceOptions(lvl)
+	}
+	return StacktraceOptions{}
+}
+
+// Levels returns the custom levels supported by the wrapped filter, if any.
+func (sf *SamplingFilter) Levels() []Level {
+	if ll, ok := sf.Filter.(LevelLister); ok {
+		return ll.Levels()
+	}
+	return nil
+}
+
+// IsSuppressed returns true if the wrapped filter suppresses the level.
+func (sf *SamplingFilter) IsSuppressed(lvl Level, t time.Time) bool {
+	return isSuppressed(sf.Filter, lvl, t)
+}
+
+// IsRecordEnabled returns false if the log record is dropped by sampling.
+// Safe for concurrent use.
+func (sf *SamplingFilter) IsRecordEnabled(rec *LogRec) bool {
+	if rf, ok := sf.Filter.(RecordFilter); ok && !rf.IsRecordEnabled(rec) {
+		return false
+	}
+
+	lvl := rec.Level()
+	never := sf.NeverSampleAbove
+	if never.Name == "" {
+		never = Error
+	}
+	if lvl.ID <= never.ID {
+		return true
+	}
+
+	key := sampleKey{lvl: lvl.ID, msg: rec.Template()}
+	if key.msg == "" {
+		key.msg = rec.Msg()
+	}
+
+	sf.mux.Lock()
+	defer sf.mux.Unlock()
+
+	t := rec.Time()
+	if sf.counts == nil || !t.Before(sf.resetAt) {
+		tick := sf.Tick
+		if tick <= 0 {
+			tick = DefSamplingTick
+		}
+		sf.counts = make(map[sampleKey]uint64)
+		sf.resetAt = t.Add(tick)
+	}
+
+	n := sf.counts[key] + 1
+	sf.counts[key] = n
+	if n <= sf.First {
+		return true
+	}
+	return sf.Thereafter > 0 && (n-sf.First)%sf.Thereafter == 0
+}
@@ -0,0 +1,81 @@
+package logr_test
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnableSequence(t *testing.T) {
+	lgr := &logr.Logr{EnableSequence: true}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true, DisableLevel: true, DisableMsg: true}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	require.NoError(t, err)
+
+	const goroutines = 10
+	const loops = 100
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			logger := lgr.NewLogger().WithField("g", g)
+			for i := 0; i < loops; i++ {
+				logger.WithField("i", i).Info("")
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	type record struct {
+		Seq int64 `json:"seq"`
+		G   int   `json:"g"`
+		I   int   `json:"i"`
+	}
+	seen := make(map[int64]bool)
+	last := make(map[int]int64)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, goroutines*loops)
+	for _, line := range lines {
+		var rec record
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		assert.False(t, seen[rec.Seq], "duplicate sequence %d", rec.Seq)
+		seen[rec.Seq] = true
+		assert.True(t, rec.Seq > last[rec.G], "sequence not increasing for goroutine %d", rec.G)
+		last[rec.G] = rec.Seq
+	}
+	for seq := int64(1); seq <= goroutines*loops; seq++ {
+		assert.True(t, seen[seq], "missing sequence %d", seq)
+	}
+}
+
+func TestEnableSequencePlain(t *testing.T) {
+	lgr := &logr.Logr{EnableSequence: true}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Info("first")
+	logger.WithField("user", "bob").Info("second")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, "info | first | seq=1\ninfo | second | seq=2 user=bob\n", buf.String())
+}
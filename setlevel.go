@@ -0,0 +1,69 @@
+package logr
+
+import (
+	"fmt"
+)
+
+// LevelSetter is implemented by targets whose level can be changed while the
+// target is in use. See `Logr.SetLevel`.
+type LevelSetter interface {
+	// SetLevel changes the level and returns the previous level.
+	SetLevel(lvl Level) (old Level, err error)
+}
+
+// SetLevel changes the level of this target's filter, which must be a
+// `StdFilter` or `*StdFilter`, and returns the previous level. A
+// `*StdFilter` is copied rather than modified, so later changes made via the
+// original pointer no longer apply to this target.
+// Use `Logr.SetLevel` for targets already added to a Logr, so its level
+// cache is reset.
+func (b *Basic) SetLevel(lvl Level) (Level, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	switch sf := b.filter.(type) {
+	case StdFilter:
+		old := sf.Lvl
+		sf.Lvl = lvl
+		b.filter = sf
+		return old, nil
+	case *StdFilter:
+		old := sf.Lvl
+		cp := *sf
+		cp.Lvl = lvl
+		b.filter = &cp
+		return old, nil
+	}
+	return Level{}, fmt.Errorf("filter %T does not support changing level", b.filter)
+}
+
+// SetLevel changes the level of a target added to this Logr while logging
+// continues, e.g. to temporarily enable debug output, and resets the level
+// cache. The target must implement LevelSetter, as targets embedding `Basic`
+// with a `StdFilter` do. `OnLevelChange` is called if the level changed.
+func (logr *Logr) SetLevel(target Target, lvl Level) error {
+	ls, ok := target.(LevelSetter)
+	if !ok {
+		return fmt.Errorf("target %v does not support changing level", target)
+	}
+
+	logr.tmux.RLock()
+	found := logr.hasTarget(target)
+	logr.tmux.RUnlock()
+	if !found {
+		return ErrTargetNotFound
+	}
+
+	old, err := ls.SetLevel(lvl)
+	if err != nil {
+		return err
+	}
+	logr.ResetLevelCache()
+
+	// called without holding any locks so the callback can safely use
+	// this Logr, including changing levels.
+	if logr.OnLevelChange != nil && old.ID != lvl.ID {
+		logr.OnLevelChange(target, old, lvl)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package logr
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// FlushOnSignal installs a handler that shuts down the Logr, flushing all
+// queued log records, when one of the signals arrives. The signal is then
+// re-raised so the default behavior (typically process termination) occurs.
+// If no signals are provided then `os.Interrupt` and `syscall.SIGTERM` are used.
+// Shutdown is bounded by `Logr.ShutdownTimeout`.
+//
+// The returned function removes the handler; it is safe to call more than once.
+func FlushOnSignal(lgr *Logr, sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sig...)
+
+	go func() {
+		select {
+		case s := <-ch:
+			signal.Stop(ch)
+			if err := lgr.Shutdown(); err != nil {
+				lgr.ReportError(err)
+			}
+			reraise(s)
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// ReopenOnSignal installs a handler that calls `Logr.Reopen` each time one of
+// the signals arrives, so file targets reopen their files after an external
+// tool such as `logrotate` renames them. Otherwise log records continue to be
+// written to the renamed, and possibly deleted, file. If no signals are provided
+// then `syscall.SIGHUP` is used. Errors are reported via `Logr.ReportError`.
+//
+// The returned function removes the handler; it is safe to call more than once.
+func ReopenOnSignal(lgr *Logr, sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sig...)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := lgr.Reopen(); err != nil {
+					lgr.ReportError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// reraise sends the signal to the current process now that the handler is
+// removed. If the signal cannot be sent, e.g. on platforms that do not support
+// sending signals, the process exits.
+func reraise(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+//go:build !windows && !nacl && !plan9
+// +build !windows,!nacl,!plan9
+
+package logr_test
+
+import (
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SIGWINCH is used since its default behavior is to be ignored, so
+// re-raising it does not terminate the test.
+
+func TestFlushOnSignal(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	require.NoError(t, err)
+
+	stop := logr.FlushOnSignal(lgr, syscall.SIGWINCH)
+	defer stop()
+
+	lgr.NewLogger().Info("last words")
+
+	err = syscall.Kill(syscall.Getpid(), syscall.SIGWINCH)
+	require.NoError(t, err)
+
+	for i := 0; i < 500 && !lgr.IsShutdown(); i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	assert.True(t, lgr.IsShutdown())
+	assert.Contains(t, buf.String(), "last words")
+}
+
+func TestFlushOnSignalStop(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, &format.Plain{}, &test.Buffer{}, 1000))
+	require.NoError(t, err)
+
+	stop := logr.FlushOnSignal(lgr, syscall.SIGWINCH)
+	stop()
+	stop()
+
+	err = syscall.Kill(syscall.Getpid(), syscall.SIGWINCH)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond * 100)
+	assert.False(t, lgr.IsShutdown())
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+}
+
+// reopenCounter counts calls to Reopen.
+type reopenCounter struct {
+	*target.Writer
+	count int32
+}
+
+func (rc *reopenCounter) Reopen() error {
+	atomic.AddInt32(&rc.count, 1)
+	return nil
+}
+
+func TestReopenOnSignal(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	tgt := &reopenCounter{Writer: target.NewWriterTarget(filter, &format.Plain{}, &test.Buffer{}, 1000)}
+	err := lgr.AddTarget(tgt)
+	require.NoError(t, err)
+
+	stop := logr.ReopenOnSignal(lgr, syscall.SIGWINCH)
+
+	for want := int32(1); want <= 2; want++ {
+		err = syscall.Kill(syscall.Getpid(), syscall.SIGWINCH)
+		require.NoError(t, err)
+		for i := 0; i < 500 && atomic.LoadInt32(&tgt.count) < want; i++ {
+			time.Sleep(time.Millisecond * 10)
+		}
+		assert.Equal(t, want, atomic.LoadInt32(&tgt.count))
+	}
+
+	stop()
+	stop()
+	assert.False(t, lgr.IsShutdown())
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+}
@@ -0,0 +1,169 @@
+package logr
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefStacktraceRefKey is the field key for the reference added by
+	// StackOnceFilter to log records, linking repeats to the first record
+	// output with a full stack trace.
+	DefStacktraceRefKey = "stacktrace_ref"
+
+	// DefStackOnceMaxKeys is the default maximum number of distinct log
+	// records remembered by StackOnceFilter.
+	DefStackOnceMaxKeys = 10000
+)
+
+// RecordStacktracer is implemented by Filters that decide whether to output
+// the stack trace of individual log records, e.g. to output a stack trace only
+// the first time a message is seen. It is only consulted for log records with
+// a stack trace captured by the logging call.
+type RecordStacktracer interface {
+	// RecordStacktrace returns true if the log record's stack trace should be
+	// output. A non-empty ref is added to the log record as a field, keyed by
+	// key, so log records without a stack trace can be matched to one with.
+	RecordStacktrace(rec *LogRec) (keep bool, key string, ref string)
+}
+
+// StackOnceFilter wraps a Filter, outputting a stack trace only the first time
+// a log record is seen. Records are identical when they have the same level
+// and message template, or message when no template was used. Repeats are
+// output without a stack trace; instead the first record and all repeats are
+// given the same `stacktrace_ref` field, so the stack trace of a repeat can be
+// found by searching for its reference.
+//
+// Only stack traces captured by the logging call are omitted; stack traces
+// attached to errors logged as fields are output as usual.
+//
+// A StackOnceFilter remembers records seen, so each target should be given
+// its own StackOnceFilter. Use `NewStackOnceFilter` to create one.
+type StackOnceFilter struct {
+	Filter Filter
+
+	// Key is the field key for the stack trace reference.
+	// Defaults to DefStacktraceRefKey.
+	Key string
+
+	// MaxKeys is the maximum number of distinct records remembered. When
+	// exceeded, all are forgotten and the next occurrence of each is output
+	// with a stack trace again. Defaults to DefStackOnceMaxKeys.
+	MaxKeys int
+
+	// Reset forgets all records seen after this interval, so a stack trace is
+	// periodically output again for recurring records. Zero never resets.
+	Reset time.Duration
+
+	mux     sync.Mutex
+	resetAt time.Time
+	seen    map[sampleKey]struct{}
+}
+
+// NewStackOnceFilter creates a StackOnceFilter wrapping filter.
+func NewStackOnceFilter(filter Filter) *StackOnceFilter {
+	return &StackOnceFilter{Filter: filter}
+}
+
+// IsEnabled returns true if the wrapped filter enables the level.
+func (sf *StackOnceFilter) IsEnabled(lvl Level) bool {
+	return sf.Filter.IsEnabled(lvl)
+}
+
+// IsStacktraceEnabled returns true if the wrapped filter requires a stack
+// trace for the level.
+func (sf *StackOnceFilter) IsStacktraceEnabled(lvl Level) bool {
+	return sf.Filter.IsStacktraceEnabled(lvl)
+}
+
+// StacktraceOptions returns the wrapped filter's stack trace options.
+func (sf *StackOnceFilter) StacktraceOptions(lvl Level) StacktraceOptions {
+	if so, ok := sf.Filter.(StacktraceOptioner); ok {
+		return so.StacktraceOptions(lvl)
+	}
+	return StacktraceOptions{}
+}
+
+// Levels returns the custom levels supported by the wrapped filter, if any.
+func (sf *StackOnceFilter) Levels() []Level {
+	if ll, ok := sf.Filter.(LevelLister); ok {
+		return ll.Levels()
+	}
+	return nil
+}
+
+// IsSuppressed returns true if the wrapped filter suppresses the level.
+func (sf *StackOnceFilter) IsSuppressed(lvl Level, t time.Time) bool {
+	return isSuppressed(sf.Filter, lvl, t)
+}
+
+// IsRecordEnabled returns true if the wrapped filter accepts the log record.
+func (sf *StackOnceFilter) IsRecordEnabled(rec *LogRec) bool {
+	if rf, ok := sf.Filter.(RecordFilter); ok {
+		return rf.IsRecordEnabled(rec)
+	}
+	return true
+}
+
+// RecordStacktrace returns true the first time the log record is seen.
+// Safe for concurrent use.
+func (sf *StackOnceFilter) RecordStacktrace(rec *LogRec) (keep bool, key string, ref string) {
+	lvl := rec.Level()
+	k := sampleKey{lvl: lvl.ID, msg: rec.Template()}
+	if k.msg == "" {
+		k.msg = rec.Msg()
+	}
+
+	key = sf.Key
+	if key == "" {
+		key = DefStacktraceRefKey
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%s", k.lvl, k.msg)
+	ref = fmt.Sprintf("%08x", h.Sum32())
+
+	sf.mux.Lock()
+	defer sf.mux.Unlock()
+
+	t := rec.Time()
+	maxKeys := sf.MaxKeys
+	if maxKeys <= 0 {
+		maxKeys = DefStackOnceMaxKeys
+	}
+	if sf.seen == nil || len(sf.seen) >= maxKeys || (sf.Reset > 0 && !t.Before(sf.resetAt)) {
+		sf.seen = make(map[sampleKey]struct{})
+		sf.resetAt = t.Add(sf.Reset)
+	}
+
+	if _, ok := sf.seen[k]; ok {
+		return false, key, ref
+	}
+	sf.seen[k] = struct{}{}
+	return true, key, ref
+}
+
+// recordStacktrace applies the filter's RecordStacktracer, if any, returning
+// the log record to output.
+func recordStacktrace(filter Filter, rec *LogRec) *LogRec {
+	rs, ok := filter.(RecordStacktracer)
+	if !ok || !rec.hasStack() {
+		return rec
+	}
+	keep, key, ref := rs.RecordStacktrace(rec)
+	if keep && ref == "" {
+		return rec
+	}
+	out := rec.WithTime(rec.Time())
+	if ref != "" {
+		out.logger = out.logger.WithField(key, ref)
+	}
+	if !keep {
+		out.stackPC = nil
+		out.stackCount = 0
+		out.allStacks = nil
+		out.frames = nil
+	}
+	return out
+}
@@ -0,0 +1,255 @@
+package logr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStacktraceOptions(t *testing.T) {
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	t.Run("max depth", func(t *testing.T) {
+		lgr := &logr.Logr{}
+		buf := &test.Buffer{}
+		filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Error, StacktraceMaxDepth: 2}
+		err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+		require.NoError(t, err)
+
+		lgr.NewLogger().Error("shallow stack")
+
+		err = lgr.Shutdown()
+		require.NoError(t, err)
+
+		var fileLines int
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.HasPrefix(line, "      ") {
+				fileLines++
+			}
+		}
+		assert.Equal(t, 2, fileLines)
+		assert.NotContains(t, buf.String(), "goroutine ")
+	})
+
+	t.Run("all goroutines", func(t *testing.T) {
+		lgr := &logr.Logr{}
+		buf := &test.Buffer{}
+		filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Error, StacktraceAllGoroutines: true}
+		err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+		require.NoError(t, err)
+
+		lgr.NewLogger().Error("full dump")
+		lgr.NewLogger().Warn("no dump")
+
+		err = lgr.Shutdown()
+		require.NoError(t, err)
+
+		output := buf.String()
+		assert.Contains(t, output, "goroutine ")
+		assert.Contains(t, output, "TestStacktraceOptions")
+		assert.Equal(t, 1, strings.Count(output, "[running]"))
+	})
+}
+
+func TestPanicForcesStacktrace(t *testing.T) {
+	lgr := &logr.Logr{DisablePanic: true, DisableExit: true}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.CustomFilter{}
+	filter.Add(logr.Panic, logr.Fatal, logr.Error) // no stack trace levels
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	logger.Error("no stack")
+	logger.Panic("panic stack")
+	logger.Fatal("fatal stack")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	output := buf.String()
+	parts := strings.Split(output, "panic stack")
+	require.Len(t, parts, 2)
+	assert.NotContains(t, parts[0], "TestPanicForcesStacktrace", "error record should have no stack trace")
+	// frames start at the call site, not inside logr.
+	assert.Contains(t, parts[1], "logr_test.TestPanicForcesStacktrace")
+	assert.NotContains(t, parts[1], "logr.Logger.Panic")
+	assert.Contains(t, strings.Split(parts[1], "fatal stack")[1], "TestPanicForcesStacktrace")
+}
+
+func panicSite() {
+	panic("boom")
+}
+
+func nilMapSite() {
+	var m map[string]int
+	m["x"] = 1
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	tests := []struct {
+		name string
+		site string
+		f    func(logger logr.Logger)
+	}{
+		{
+			name: "panic",
+			site: "logr_test.panicSite",
+			f: func(logger logr.Logger) {
+				defer logger.RecoverAndLog()
+				panicSite()
+			},
+		},
+		{
+			name: "runtime error",
+			site: "logr_test.nilMapSite",
+			f: func(logger logr.Logger) {
+				defer logger.RecoverAndLog()
+				nilMapSite()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Error}
+			formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+			err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+			require.NoError(t, err)
+
+			tt.f(lgr.NewLogger())
+
+			err = lgr.Shutdown()
+			require.NoError(t, err)
+
+			lines := strings.Split(buf.String(), "\n")
+			require.True(t, len(lines) > 2, buf.String())
+			assert.True(t, strings.HasPrefix(lines[0], "error | recovered from panic | panic="), lines[0])
+			// the stack trace starts at the panic site.
+			assert.True(t, strings.HasSuffix(lines[1], tt.site), lines[1])
+		})
+	}
+}
+
+func TestRecoverAndLogRepanic(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Warn, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	var recovered interface{}
+	func() {
+		defer func() {
+			recovered = recover()
+		}()
+		defer lgr.NewLogger().RecoverAndLogLevel(logr.Warn, true)
+		panicSite()
+	}()
+	assert.Equal(t, "boom", recovered)
+
+	// the log record is flushed before panicking again.
+	assert.Equal(t, "warn | recovered from panic | panic=boom\n", buf.String())
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+}
+
+func TestRecoverLoggerPanic(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 100))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	func() {
+		defer logger.RecoverAndLog()
+		logger.Panic("boom")
+	}()
+
+	// the Logr is flushed, not shut down, so logging continues.
+	assert.False(t, lgr.IsShutdown())
+	logger.Info("after panic")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	output := buf.String()
+	assert.Contains(t, output, "panic | boom |")
+	assert.Contains(t, output, "error | recovered from panic | panic=boom\n")
+	assert.Contains(t, output, "info | after panic |")
+}
+
+func TestAlwaysStacktrace(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	alertBuf := &test.Buffer{}
+	alert := target.NewWriterTarget(filter, formatter, alertBuf, 100)
+	alert.SetAlwaysStacktrace(true)
+
+	consoleBuf := &test.Buffer{}
+	console := target.NewWriterTarget(filter, formatter, consoleBuf, 100)
+
+	err := lgr.AddTarget(alert, console)
+	require.NoError(t, err)
+
+	lgr.NewLogger().Info("disk full")
+	lgr.NewLogger().Debug("filtered")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(alertBuf.String(), "info | disk full | \n  "), alertBuf.String())
+	assert.Contains(t, alertBuf.String(), "TestAlwaysStacktrace")
+	assert.Equal(t, "info | disk full | \n", consoleBuf.String())
+}
+
+func TestStackOnceFilter(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := logr.NewStackOnceFilter(&logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error})
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	require.NoError(t, err)
+
+	logger := lgr.NewLogger()
+	for i := 0; i < 100; i++ {
+		logger.Errorf("db timeout after %d retries", i)
+	}
+	logger.Error("different error")
+	logger.Info("no stack")
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	output := buf.String()
+	assert.Equal(t, 2, strings.Count(output, "TestStackOnceFilter"), "expected one stack per distinct error")
+
+	var refs []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "error | db timeout") {
+			idx := strings.Index(line, logr.DefStacktraceRefKey+"=")
+			require.NotEqual(t, -1, idx, line)
+			refs = append(refs, line[idx:])
+		}
+	}
+	require.Len(t, refs, 100)
+	for _, ref := range refs {
+		assert.Equal(t, refs[0], ref)
+	}
+	assert.Contains(t, output, "info | no stack | \n")
+}
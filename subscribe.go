@@ -0,0 +1,112 @@
+package logr
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// DefaultSubscriptionBuffer is the number of log records buffered for each
+	// subscriber before log records are dropped for that subscriber.
+	DefaultSubscriptionBuffer = 1000
+)
+
+// subscription is an in-process consumer of log records.
+type subscription struct {
+	drops  uint64 // accessed atomically, keep first for alignment
+	filter Filter
+	ch     chan *LogRec
+}
+
+// Subscribe returns a channel that receives a copy of every log record
+// enabled by the filter, such as for a live tail endpoint, and a function that
+// ends the subscription and closes the channel. Subscribers that do not keep up
+// never block logging; log records are dropped for that subscriber instead.
+// See `SubscriptionDrops`. The channel is also closed when the Logr is shut down.
+func (logr *Logr) Subscribe(filter Filter) (<-chan *LogRec, func()) {
+	prepareSuppression(filter)
+	sub := &subscription{
+		filter: filter,
+		ch:     make(chan *LogRec, DefaultSubscriptionBuffer),
+	}
+
+	logr.ensureInit()
+	defer logr.ResetLevelCache() // call this after tmux is released
+
+	// check for shutdown while holding tmux so the subscription cannot be
+	// added after closeSubscriptions has run.
+	logr.tmux.Lock()
+	if logr.IsShutdown() {
+		logr.tmux.Unlock()
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	logr.subs = append(logr.subs, sub)
+	logr.tmux.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			logr.removeSubscription(sub)
+		})
+	}
+	return sub.ch, unsubscribe
+}
+
+// SubscriptionDrops returns the number of log records dropped because the
+// subscriber's channel was full, for the subscription with channel ch.
+// Zero is returned once the subscription has ended.
+func (logr *Logr) SubscriptionDrops(ch <-chan *LogRec) uint64 {
+	logr.tmux.RLock()
+	defer logr.tmux.RUnlock()
+
+	for _, sub := range logr.subs {
+		if sub.ch == ch {
+			return atomic.LoadUint64(&sub.drops)
+		}
+	}
+	return 0
+}
+
+// removeSubscription removes the subscription, if still present, and closes
+// its channel.
+func (logr *Logr) removeSubscription(sub *subscription) {
+	defer logr.ResetLevelCache() // call this after tmux is released
+
+	logr.tmux.Lock()
+	defer logr.tmux.Unlock()
+
+	for i, s := range logr.subs {
+		if s == sub {
+			logr.subs = append(logr.subs[:i], logr.subs[i+1:]...)
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+// closeSubscriptions closes all subscriber channels.
+func (logr *Logr) closeSubscriptions() {
+	logr.tmux.Lock()
+	defer logr.tmux.Unlock()
+
+	for _, sub := range logr.subs {
+		close(sub.ch)
+	}
+	logr.subs = nil
+}
+
+// publish sends a copy of the log record to each subscriber whose filter
+// enables it, without blocking. Must be called with tmux read locked.
+func (logr *Logr) publish(rec *LogRec) {
+	for _, sub := range logr.subs {
+		if !sub.filter.IsEnabled(rec.Level()) || !accepts(sub.filter, rec) {
+			continue
+		}
+		select {
+		case sub.ch <- rec.WithTime(rec.Time()):
+		default:
+			atomic.AddUint64(&sub.drops, 1)
+		}
+	}
+}
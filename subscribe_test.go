@@ -0,0 +1,119 @@
+package logr_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribe(t *testing.T) {
+	lgr := &logr.Logr{}
+	logger := lgr.NewLogger().WithField("name", "subscribe")
+
+	errs, unsubErrs := lgr.Subscribe(&logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic})
+	all, unsubAll := lgr.Subscribe(&logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic})
+	defer unsubAll()
+
+	assert.True(t, lgr.IsLevelEnabled(logr.Debug).Enabled, "subscriber should enable level")
+	assert.False(t, lgr.IsLevelEnabled(logr.Trace).Enabled)
+
+	logger.Debug("debug msg")
+	logger.Error("error msg")
+
+	rec := <-all
+	assert.Equal(t, "debug msg", rec.Msg())
+	assert.Equal(t, "subscribe", rec.Fields()["name"])
+	rec = <-all
+	assert.Equal(t, "error msg", rec.Msg())
+
+	rec = <-errs
+	assert.Equal(t, "error msg", rec.Msg())
+
+	unsubErrs()
+	unsubErrs() // must be safe to call more than once
+	_, ok := <-errs
+	assert.False(t, ok, "channel should be closed after unsubscribe")
+
+	err := lgr.Shutdown()
+	require.NoError(t, err)
+
+	_, ok = <-all
+	assert.False(t, ok, "channel should be closed after shutdown")
+}
+
+func TestSubscribeSlowConsumer(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, formatter, buf, 1000))
+	require.NoError(t, err)
+
+	// never read from the channel.
+	slow, unsubscribe := lgr.Subscribe(filter)
+	defer unsubscribe()
+
+	// this one enables none of the records so drops nothing.
+	errs, unsubErrs := lgr.Subscribe(&logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic})
+	defer unsubErrs()
+
+	logger := lgr.NewLogger()
+	count := logr.DefaultSubscriptionBuffer + 10
+	for i := 0; i < count; i++ {
+		logger.Info("msg")
+	}
+
+	err = lgr.Flush()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(10), lgr.SubscriptionDrops(slow))
+	assert.Equal(t, uint64(0), lgr.SubscriptionDrops(errs))
+
+	err = lgr.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, count, strings.Count(buf.String(), "msg"), "target should receive all records")
+	assert.Equal(t, uint64(0), lgr.SubscriptionDrops(slow), "no drops once the subscription has ended")
+}
+
+func TestSubscribeDuringShutdown(t *testing.T) {
+	lgr := &logr.Logr{}
+	lgr.NewLogger().Info("start")
+
+	const count = 100
+	chans := make(chan (<-chan *logr.LogRec), count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ch, _ := lgr.Subscribe(&logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic})
+			chans <- ch
+		}()
+	}
+
+	err := lgr.Shutdown()
+	require.NoError(t, err)
+	wg.Wait()
+	close(chans)
+
+	// every channel is closed, whether subscribed before or after shutdown.
+	for ch := range chans {
+		select {
+		case _, ok := <-ch:
+			for ok {
+				_, ok = <-ch
+			}
+		case <-time.After(time.Second):
+			t.Fatal("subscription channel not closed after shutdown")
+		}
+	}
+}
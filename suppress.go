@@ -0,0 +1,116 @@
+package logr
+
+import (
+	"sync"
+	"time"
+)
+
+// Suppressor is implemented by Filters and Targets that can temporarily
+// suppress levels that are otherwise enabled. Suppression is checked for
+// each log record, using the time the log record was created, rather than
+// cached, so it ends promptly when it expires.
+type Suppressor interface {
+	IsSuppressed(lvl Level, t time.Time) bool
+}
+
+// suppression tracks the time windows during which levels are suppressed.
+type suppression struct {
+	mux     sync.RWMutex
+	windows map[LevelID]window
+}
+
+type window struct {
+	from  time.Time
+	until time.Time
+}
+
+func newSuppression() *suppression {
+	return &suppression{windows: make(map[LevelID]window)}
+}
+
+// prepareSuppression creates the suppression state of each StdFilter within
+// the filter before it is used, so `Suppress` never modifies a StdFilter
+// while its value receiver methods copy it. Called when a filter is passed to
+// a target, a subscription or a combinator.
+func prepareSuppression(filter Filter) {
+	switch f := filter.(type) {
+	case *StdFilter:
+		if f.suppression == nil {
+			f.suppression = newSuppression()
+		}
+	case andFilter:
+		for _, sub := range f {
+			prepareSuppression(sub)
+		}
+	case orFilter:
+		for _, sub := range f {
+			prepareSuppression(sub)
+		}
+	case *SamplingFilter:
+		prepareSuppression(f.Filter)
+	case *StackOnceFilter:
+		prepareSuppression(f.Filter)
+	}
+}
+
+// Suppress disables the level for the duration d, after which it is enabled
+// again, e.g. to reduce noise during a planned migration. Suppression is
+// independent of `Lvl`: a level is output only when enabled by `Lvl` and not
+// suppressed, so changing `Lvl` neither ends nor extends a suppression.
+// The window starts at the time returned by `Clock`, so set it when the Logr
+// has a `Clock`. Suppressing a level again replaces the previous duration. A
+// duration of zero or less is equivalent to `Unsuppress`. Safe for concurrent
+// use once the filter has been passed to a target, `Logr.Subscribe` or a
+// combinator.
+func (lt *StdFilter) Suppress(lvl Level, d time.Duration) {
+	if d <= 0 {
+		lt.Unsuppress(lvl)
+		return
+	}
+
+	prepareSuppression(lt)
+	s := lt.suppression
+
+	now := time.Now()
+	if lt.Clock != nil {
+		now = lt.Clock()
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.windows[lvl.ID] = window{from: now, until: now.Add(d)}
+}
+
+// Unsuppress ends any suppression of the level immediately.
+// Safe for concurrent use.
+func (lt *StdFilter) Unsuppress(lvl Level) {
+	s := lt.suppression
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.windows, lvl.ID)
+}
+
+// IsSuppressed returns true if the level is suppressed, via `Suppress`, at
+// time t. Log records created before `Suppress` was called are not suppressed.
+func (lt *StdFilter) IsSuppressed(lvl Level, t time.Time) bool {
+	s := lt.suppression
+	if s == nil {
+		return false
+	}
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	w, ok := s.windows[lvl.ID]
+	return ok && !t.Before(w.from) && t.Before(w.until)
+}
+
+// isSuppressed returns true if v implements Suppressor and the level
+// is suppressed at time t.
+func isSuppressed(v interface{}, lvl Level, t time.Time) bool {
+	if s, ok := v.(Suppressor); ok {
+		return s.IsSuppressed(lvl, t)
+	}
+	return false
+}
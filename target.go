@@ -1,6 +1,7 @@
 package logr
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -35,6 +36,46 @@ type RecordWriter interface {
 	Write(rec *LogRec) error
 }
 
+// Prioritizer can be implemented by a Target to control the order in which
+// targets receive each log record. Targets with higher priority receive log
+// records before targets with lower priority. The default priority is zero.
+type Prioritizer interface {
+	Priority() int
+}
+
+// Reopener can be implemented by a Target that writes to a named file, to
+// close and reopen the file, e.g. after an external tool such as `logrotate`
+// has renamed it. See `Logr.Reopen` and `ReopenOnSignal`.
+type Reopener interface {
+	Reopen() error
+}
+
+// Forward passes a log record to child targets on behalf of a wrapper target,
+// such as a tee, that does not queue log records itself. Log records are only
+// passed to children whose level is enabled. Flush requests are passed to
+// each child in turn, without blocking, and complete once all children are
+// flushed.
+func Forward(rec *LogRec, targets ...Target) {
+	if rec.flush == nil {
+		for _, target := range targets {
+			if enabled, _ := target.IsLevelEnabled(rec.Level()); enabled && accepts(target, rec) {
+				target.Log(rec)
+			}
+		}
+		return
+	}
+
+	// the caller waits for the flush after Log returns.
+	go func() {
+		for _, target := range targets {
+			flushRec := newFlushLogRec(rec.Logger())
+			target.Log(flushRec)
+			<-flushRec.flush
+		}
+		rec.flush <- struct{}{}
+	}()
+}
+
 // Basic provides the basic functionality of a Target that can be used
 // to more easily compose your own Targets. To use, just embed Basic
 // in your target type, implement `RecordWriter`, and call `(*Basic).Start`.
@@ -43,13 +84,29 @@ type Basic struct {
 
 	filter    Filter
 	formatter Formatter
-
-	in   chan *LogRec
-	done chan struct{}
-	w    RecordWriter
-
-	mux  sync.RWMutex
-	name string
+	// worker formats log records when `Logr.FormatTimeout` is set.
+	worker formatWorker
+
+	queue        Queue
+	queueFactory QueueFactory
+	maxQueued    int
+	done         chan struct{}
+	closeOnce    sync.Once
+	w            RecordWriter
+
+	mux         sync.RWMutex
+	name        string
+	priority    int
+	synchronous bool
+	alwaysStack bool
+	recordSep   []byte
+	linePrefix  []byte
+	lineSuffix  []byte
+
+	// wmux serializes writes when synchronous writes are enabled.
+	wmux sync.Mutex
+
+	health targetHealth
 
 	metrics        bool
 	queueSizeGauge Gauge
@@ -57,23 +114,43 @@ type Basic struct {
 	errorCounter   Counter
 	droppedCounter Counter
 	blockedCounter Counter
+	latencyHisto   Histogram
+
+	onDequeueLatency func(time.Duration)
 
 	metricsUpdateFreqMillis int64
 }
 
 // Start initializes this target helper and starts accepting log records for processing.
+// Up to maxQueued log records are held in a channel queue, see `NewChannelQueue`.
 func (b *Basic) Start(target Target, rw RecordWriter, filter Filter, formatter Formatter, maxQueued int) {
+	b.StartWithQueue(target, rw, filter, formatter, nil, maxQueued)
+}
+
+// StartWithQueue is the same as `Start` but holds log records in a queue
+// created by factory, e.g. a queue ordered by level. A nil factory creates a
+// channel queue, see `NewChannelQueue`. maxQueued is passed to the factory and
+// reported to `Logr.OnTargetQueueFull`. Target constructors can accept a
+// QueueFactory and pass it here; `StartClone` creates the clone's queue with
+// the same factory.
+func (b *Basic) StartWithQueue(target Target, rw RecordWriter, filter Filter, formatter Formatter, factory QueueFactory, maxQueued int) {
+	if factory == nil {
+		factory = NewChannelQueue
+	}
 	if filter == nil {
 		filter = &StdFilter{Lvl: Fatal}
 	}
 	if formatter == nil {
 		formatter = &DefaultFormatter{}
 	}
+	prepareSuppression(filter)
 
 	b.target = target
 	b.filter = filter
 	b.formatter = formatter
-	b.in = make(chan *LogRec, maxQueued)
+	b.queue = factory(maxQueued)
+	b.queueFactory = factory
+	b.maxQueued = maxQueued
 	b.done = make(chan struct{}, 1)
 	b.w = rw
 	go b.start()
@@ -83,56 +160,300 @@ func (b *Basic) Start(target Target, rw RecordWriter, filter Filter, formatter F
 	}
 }
 
+// StartClone initializes this target helper with the same filter, formatter,
+// queue size, name, priority, synchronous and stack trace settings as src, and
+// starts accepting log records for processing. Log records are held in a new
+// queue created by the same QueueFactory as src. Used to implement `TargetCloner`.
+func (b *Basic) StartClone(src *Basic, target Target, rw RecordWriter) {
+	src.mux.RLock()
+	b.name = src.name
+	b.priority = src.priority
+	b.synchronous = src.synchronous
+	b.alwaysStack = src.alwaysStack
+	b.recordSep = src.recordSep
+	b.linePrefix = src.linePrefix
+	b.lineSuffix = src.lineSuffix
+	src.mux.RUnlock()
+
+	src.health.mux.Lock()
+	b.health.maxErrors = src.health.maxErrors
+	b.health.probeInterval = src.health.probeInterval
+	src.health.mux.Unlock()
+
+	b.StartWithQueue(target, rw, src.getFilter(), src.formatter, src.queueFactory, src.maxQueued)
+}
+
 func (b *Basic) SetName(name string) {
 	b.mux.Lock()
 	defer b.mux.Unlock()
 	b.name = name
 }
 
+// SetPriority sets the priority of this target relative to others added to the
+// same Logr; targets with higher priority receive each log record first.
+// Must be called before the target is added to a Logr.
+func (b *Basic) SetPriority(priority int) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.priority = priority
+}
+
+// Priority returns the priority of this target. See `SetPriority`.
+func (b *Basic) Priority() int {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.priority
+}
+
+// SetSynchronous determines if log records are written immediately, in the
+// goroutine making the logging call, instead of being queued. Output ordering
+// is then deterministic and visible as soon as the logging call returns, without
+// calling `Logr.Flush` or `Logr.Shutdown`, which is useful for tests and CLIs.
+// This also ensures a log record is written to this target before it is passed
+// to queued targets, at the expense of slowing each logging call by this
+// target's write time. Writes are serialized across goroutines.
+// Should be called before the target is added to a Logr.
+func (b *Basic) SetSynchronous(sync bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.synchronous = sync
+}
+
+// IsSynchronous returns true if this target writes log records in the
+// goroutine making the logging call. See `SetSynchronous`.
+func (b *Basic) IsSynchronous() bool {
+	return b.isSynchronous()
+}
+
+func (b *Basic) isSynchronous() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.synchronous
+}
+
+// SetAlwaysStacktrace determines if this target requires a stack trace for
+// every log record it emits, regardless of its filter, e.g. for alerting or
+// crash reporting targets. Stack traces are captured once per log record when
+// any target requires one, so other targets are unaffected.
+// Should be called before the target is added to a Logr.
+func (b *Basic) SetAlwaysStacktrace(always bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.alwaysStack = always
+}
+
+// SetRecordSeparator sets the bytes output after each formatted log record,
+// e.g. `[]byte("\r\n")`, or an empty, non-nil slice for none when the
+// transport provides its own framing. nil restores the default,
+// DefRecordSeparator. When set, the newline ending each formatted log record
+// is replaced by the separator via `EndRecord`.
+// Should be called before the target is added to a Logr.
+func (b *Basic) SetRecordSeparator(sep []byte) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if sep == nil {
+		b.recordSep = nil
+		return
+	}
+	b.recordSep = append([]byte{}, sep...)
+}
+
+// RecordSeparator returns the bytes output after each formatted log record.
+// See `SetRecordSeparator`.
+func (b *Basic) RecordSeparator() []byte {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	if b.recordSep == nil {
+		return defRecordSeparator
+	}
+	return b.recordSep
+}
+
+// EndRecord completes a log record formatted into buf, outputting the line
+// suffix and record separator in place of the newline the formatter ended
+// the record with. buf is left as is when neither has been set, so targets
+// calling EndRecord output exactly what the formatter did by default.
+func (b *Basic) EndRecord(buf *bytes.Buffer) {
+	b.mux.RLock()
+	suffix, sep := b.lineSuffix, b.recordSep
+	b.mux.RUnlock()
+
+	if suffix == nil && sep == nil {
+		return
+	}
+	if sep == nil {
+		sep = defRecordSeparator
+	}
+	if n := buf.Len(); n > 0 && buf.Bytes()[n-1] == '\n' {
+		buf.Truncate(n - 1)
+	}
+	buf.Write(suffix)
+	buf.Write(sep)
+}
+
+// SetLinePrefix sets bytes output before each formatted log record, e.g. a
+// source token required by an ingestion service, without needing a wrapping
+// formatter. nil or empty for none, the default.
+// Should be called before the target is added to a Logr.
+func (b *Basic) SetLinePrefix(prefix []byte) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.linePrefix = copyBytes(prefix)
+}
+
+// LinePrefix returns the bytes output before each formatted log record.
+// See `SetLinePrefix`.
+func (b *Basic) LinePrefix() []byte {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.linePrefix
+}
+
+// SetLineSuffix sets bytes output after each formatted log record and before
+// the record separator, via `EndRecord`. nil or empty for none, the default.
+// Should be called before the target is added to a Logr.
+func (b *Basic) SetLineSuffix(suffix []byte) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.lineSuffix = copyBytes(suffix)
+}
+
+// LineSuffix returns the bytes output after each formatted log record.
+// See `SetLineSuffix`.
+func (b *Basic) LineSuffix() []byte {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.lineSuffix
+}
+
+func copyBytes(b []byte) []byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
 // IsLevelEnabled returns true if this target should emit
 // logs for the specified level. Also determines if
-// a stack trace is required.
+// a stack trace is required. Panic and Fatal always require
+// a stack trace regardless of the filter, as do all levels
+// when `SetAlwaysStacktrace` is enabled.
 func (b *Basic) IsLevelEnabled(lvl Level) (enabled bool, stacktrace bool) {
-	return b.filter.IsEnabled(lvl), b.filter.IsStacktraceEnabled(lvl)
+	filter := b.getFilter()
+	enabled = filter.IsEnabled(lvl)
+	return enabled, forceStacktrace(lvl) || filter.IsStacktraceEnabled(lvl) || (enabled && b.isAlwaysStacktrace())
+}
+
+// getFilter returns the filter, which can be replaced by `SetLevel`.
+func (b *Basic) getFilter() Filter {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.filter
+}
+
+func (b *Basic) isAlwaysStacktrace() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.alwaysStack
+}
+
+// StacktraceOptions returns how stack traces are captured for the specified
+// Level, as determined by this target's filter.
+func (b *Basic) StacktraceOptions(lvl Level) StacktraceOptions {
+	if so, ok := b.getFilter().(StacktraceOptioner); ok {
+		return so.StacktraceOptions(lvl)
+	}
+	return StacktraceOptions{}
+}
+
+// Levels returns the custom levels supported by this target's filter, if
+// the filter implements LevelLister.
+func (b *Basic) Levels() []Level {
+	if ll, ok := b.getFilter().(LevelLister); ok {
+		return ll.Levels()
+	}
+	return nil
+}
+
+// IsRecordEnabled returns true if this target's filter accepts the log record,
+// if the filter implements RecordFilter. Otherwise returns true.
+func (b *Basic) IsRecordEnabled(rec *LogRec) bool {
+	if rf, ok := b.getFilter().(RecordFilter); ok {
+		return rf.IsRecordEnabled(rec)
+	}
+	return true
 }
 
-// Formatter returns the Formatter associated with this Target.
+// IsSuppressed returns true if the level is temporarily suppressed at time t
+// by this target's filter, if the filter implements Suppressor.
+func (b *Basic) IsSuppressed(lvl Level, t time.Time) bool {
+	return isSuppressed(b.getFilter(), lvl, t)
+}
+
+// Formatter returns the Formatter associated with this Target, as passed to
+// `Start`. Use `FormatRecord` to format log records with it.
 func (b *Basic) Formatter() Formatter {
 	return b.formatter
 }
 
+// FormatRecord formats the log record using the target's formatter. If the
+// formatter returns an error or panics then `Logr.FallbackFormatter`, when not
+// nil, is used instead, and `Logr.FormatTimeout` is enforced when set.
+// RecordWriters call it rather than `Formatter().Format`.
+func (b *Basic) FormatRecord(rec *LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	return formatRecord(b.formatter, &b.worker, rec, stacktrace, buf)
+}
+
 // Shutdown stops processing log records after making best
 // effort to flush queue.
 func (b *Basic) Shutdown(ctx context.Context) error {
-	// close the incoming channel and wait for read loop to exit.
-	close(b.in)
+	// close the queue and wait for read loop to exit.
+	b.closeOnce.Do(b.queue.Close)
 	select {
 	case <-ctx.Done():
 	case <-b.done:
 	}
 
-	// b.in channel should now be drained.
+	b.worker.stop()
+
+	// queue should now be drained.
 	return nil
 }
 
 // Log outputs the log record to this targets destination.
 func (b *Basic) Log(rec *LogRec) {
-	lgr := rec.Logger().Logr()
-	select {
-	case b.in <- rec:
-	default:
-		handler := lgr.OnTargetQueueFull
-		if handler != nil && handler(b.target, rec, cap(b.in)) {
-			b.incDroppedCounter()
-			return // drop the record
+	if b.isSynchronous() {
+		if rec.flush != nil {
+			// nothing is queued; the caller waits for the flush after Log returns.
+			go func() { rec.flush <- struct{}{} }()
+		} else {
+			b.write(rec)
 		}
-		b.incBlockedCounter()
+		return
+	}
 
-		select {
-		case <-time.After(lgr.enqueueTimeout()):
-			lgr.ReportError(fmt.Errorf("target enqueue timeout for log rec [%v]", rec))
-		case b.in <- rec: // block until success or timeout
+	if b.queue.Enqueue(rec, 0) {
+		return
+	}
+
+	lgr := rec.Logger().Logr()
+	handler := lgr.OnTargetQueueFull
+	if handler != nil && handler(b.target, rec, b.maxQueued) {
+		b.incDroppedCounter()
+		lgr.notifyDropped(rec)
+		return // drop the record
+	}
+	b.incBlockedCounter()
+
+	// block until success or timeout
+	timeout, byDeadline := rec.enqueueTimeout(lgr.enqueueTimeout())
+	if !b.queue.Enqueue(rec, timeout) {
+		lgr.notifyDropped(rec)
+		if byDeadline {
+			b.incDroppedCounter()
+			return
 		}
+		lgr.ReportError(fmt.Errorf("target enqueue timeout for log rec [%v]", rec))
 	}
 }
 
@@ -163,9 +484,49 @@ func (b *Basic) EnableMetrics(collector MetricsCollector, updateFreqMillis int64
 	if b.blockedCounter, err = collector.BlockedCounter(name); err != nil {
 		return err
 	}
+	if lc, ok := collector.(QueueLatencyCollector); ok {
+		if b.latencyHisto, err = lc.QueueLatencyHistogram(name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// SetOnDequeueLatency sets a function that is called with the amount of time
+// each log record waited between being added to the Logr queue and being
+// dequeued by this target for writing. This can be used to detect backpressure.
+// The function is called from the target's goroutine and should return quickly.
+func (b *Basic) SetOnDequeueLatency(f func(time.Duration)) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.onDequeueLatency = f
+}
+
+// observeDequeueLatency reports how long the log record waited to be dequeued.
+func (b *Basic) observeDequeueLatency(rec *LogRec) {
+	b.mux.RLock()
+	histo := b.latencyHisto
+	handler := b.onDequeueLatency
+	b.mux.RUnlock()
+
+	if histo == nil && handler == nil {
+		return
+	}
+
+	enqueued := rec.EnqueueTime()
+	if enqueued.IsZero() {
+		return
+	}
+	wait := time.Since(enqueued)
+
+	if histo != nil {
+		histo.Observe(wait.Seconds())
+	}
+	if handler != nil {
+		handler(wait)
+	}
+}
+
 func (b *Basic) hasMetrics() bool {
 	b.mux.RLock()
 	defer b.mux.RUnlock()
@@ -233,22 +594,50 @@ func (b *Basic) start() {
 		}
 	}()
 
-	for rec := range b.in {
+	for {
+		rec, ok := b.queue.Dequeue()
+		if !ok {
+			break
+		}
 		if rec.flush != nil {
 			b.flush(rec.flush)
 		} else {
-			err := b.w.Write(rec)
-			if err != nil {
-				b.incErrorCounter()
-				rec.Logger().Logr().ReportError(err)
-			} else {
-				b.incLoggedCounter()
-			}
+			b.write(rec)
 		}
 	}
 	close(b.done)
 }
 
+// write outputs a log record via the RecordWriter.
+func (b *Basic) write(rec *LogRec) {
+	b.observeDequeueLatency(rec)
+
+	lgr := rec.Logger().Logr()
+	if !b.shouldWrite(lgr) {
+		b.incDroppedCounter()
+		return
+	}
+
+	err := b.writeLocked(recordStacktrace(b.getFilter(), rec))
+
+	if err != nil {
+		b.incErrorCounter()
+	} else {
+		b.incLoggedCounter()
+	}
+	if err = b.writeResult(err, lgr); err != nil {
+		lgr.ReportError(err)
+	}
+}
+
+// writeLocked calls the RecordWriter while holding wmux, releasing it even
+// if the RecordWriter panics.
+func (b *Basic) writeLocked(rec *LogRec) error {
+	b.wmux.Lock()
+	defer b.wmux.Unlock()
+	return b.w.Write(rec)
+}
+
 // startMetricsUpdater updates the metrics for any polled values every `MetricsUpdateFreqSecs` seconds until
 // target is closed.
 func (b *Basic) startMetricsUpdater() {
@@ -265,7 +654,7 @@ func (b *Basic) startMetricsUpdater() {
 		case <-b.done:
 			return
 		case <-time.After(time.Duration(updateFreq) * time.Millisecond):
-			b.setQueueSizeGauge(float64(len(b.in)))
+			b.setQueueSizeGauge(float64(b.queue.Len()))
 		}
 	}
 }
@@ -278,22 +667,16 @@ func (b *Basic) getMetricsUpdateFreqMillis() int64 {
 
 // flush drains the queue and notifies when done.
 func (b *Basic) flush(done chan<- struct{}) {
-	for {
-		var rec *LogRec
-		var err error
-		select {
-		case rec = <-b.in:
-			// ignore any redundant flush records.
-			if rec.flush == nil {
-				err = b.w.Write(rec)
-				if err != nil {
-					b.incErrorCounter()
-					rec.Logger().Logr().ReportError(err)
-				}
-			}
-		default:
-			done <- struct{}{}
-			return
+	// only this goroutine dequeues, so Dequeue cannot block while Len > 0.
+	for b.queue.Len() > 0 {
+		rec, ok := b.queue.Dequeue()
+		if !ok {
+			break
+		}
+		// ignore any redundant flush records.
+		if rec.flush == nil {
+			b.write(rec)
 		}
 	}
+	done <- struct{}{}
 }
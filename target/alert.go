@@ -0,0 +1,249 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/wiggin77/merror"
+)
+
+const (
+	// DefaultAlertDedupWindow is the default period during which alerts for
+	// the same message template are suppressed after the first is sent.
+	DefaultAlertDedupWindow = 5 * time.Minute
+
+	// DefaultAlertTimeout is the default time allowed to deliver an alert.
+	DefaultAlertTimeout = 10 * time.Second
+
+	// maxPendingAlerts is the number of alerts that can await delivery before
+	// new alerts are dropped.
+	maxPendingAlerts = 100
+)
+
+// AlertParams provides parameters for delivering alerts to a webhook.
+type AlertParams struct {
+	// URL is the webhook URL. Alerts are POSTed as JSON `{"text": "..."}`,
+	// which is accepted by Slack incoming webhooks and many others.
+	URL string
+
+	// DedupWindow is the period during which further alerts with the same
+	// message template are suppressed after one is sent. The next alert sent
+	// for the template reports how many were suppressed.
+	// Defaults to DefaultAlertDedupWindow.
+	DedupWindow time.Duration
+
+	// Cooldown is the minimum time between any two alerts, regardless of
+	// message template. Zero means no cooldown.
+	Cooldown time.Duration
+
+	// Timeout is the time allowed to deliver each alert.
+	// Defaults to DefaultAlertTimeout.
+	Timeout time.Duration
+
+	// Client is an optional http.Client used to deliver alerts.
+	Client *http.Client
+}
+
+// Alert sends a concise alert to a webhook for each log record that passes
+// the filter, typically Error and above, with deduplication so an error storm
+// does not flood the alert channel. Alerts are delivered from a separate
+// goroutine so slow or failing webhooks never block logging; delivery failures
+// are reported via `Logr.OnLoggerError`.
+type Alert struct {
+	logr.Basic
+	params AlertParams
+	client *http.Client
+
+	mux        sync.Mutex
+	lastSent   time.Time
+	dedup      map[string]*alertState
+	suppressed int
+
+	alerts     chan alertMsg
+	alertsOnce sync.Once
+	done       chan struct{}
+}
+
+type alertState struct {
+	sent       time.Time
+	suppressed int
+}
+
+type alertMsg struct {
+	text string
+	lgr  *logr.Logr
+}
+
+// NewAlertTarget creates a target that delivers alerts to a webhook.
+func NewAlertTarget(filter logr.Filter, params *AlertParams) *Alert {
+	a := &Alert{
+		params: *params,
+		client: params.Client,
+		dedup:  make(map[string]*alertState),
+		alerts: make(chan alertMsg, maxPendingAlerts),
+		done:   make(chan struct{}),
+	}
+	if a.params.DedupWindow == 0 {
+		a.params.DedupWindow = DefaultAlertDedupWindow
+	}
+	if a.params.Timeout == 0 {
+		a.params.Timeout = DefaultAlertTimeout
+	}
+	if a.client == nil {
+		a.client = &http.Client{Timeout: a.params.Timeout}
+	}
+
+	a.Basic.Start(a, a, filter, &alertFormatter{}, 1000)
+	go a.startSender()
+	return a
+}
+
+// Shutdown stops processing log records, then waits for pending alerts
+// to be delivered.
+func (a *Alert) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+
+	err := a.Basic.Shutdown(ctx)
+	errs.Append(err)
+
+	a.alertsOnce.Do(func() { close(a.alerts) })
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		errs.Append(fmt.Errorf("alert delivery incomplete: %w", ctx.Err()))
+	}
+	return errs.ErrorOrNil()
+}
+
+// Write formats the log record as a concise alert and queues it for delivery,
+// unless suppressed by deduplication or cooldown.
+func (a *Alert) Write(rec *logr.LogRec) error {
+	suppressed, ok := a.shouldSend(rec, time.Now())
+	if !ok {
+		return nil
+	}
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf, err := a.FormatRecord(rec, false, buf)
+	if err != nil {
+		return err
+	}
+	text := buf.String()
+	if suppressed > 0 {
+		text = fmt.Sprintf("%s (%d similar alerts suppressed)", text, suppressed)
+	}
+
+	select {
+	case a.alerts <- alertMsg{text: text, lgr: rec.Logger().Logr()}:
+	default:
+		return fmt.Errorf("alert dropped, %d alerts pending delivery", maxPendingAlerts)
+	}
+	return nil
+}
+
+// shouldSend applies deduplication and cooldown, returning true if an alert
+// should be sent for the log record along with the number of alerts
+// suppressed for the same message template since the last one was sent.
+func (a *Alert) shouldSend(rec *logr.LogRec, now time.Time) (int, bool) {
+	key := rec.Template()
+	if key == "" {
+		key = rec.Msg()
+	}
+	key = rec.Level().Name + ":" + key
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	state, ok := a.dedup[key]
+	if !ok {
+		state = &alertState{}
+		a.dedup[key] = state
+	}
+
+	if (ok && now.Sub(state.sent) < a.params.DedupWindow) ||
+		(a.params.Cooldown > 0 && now.Sub(a.lastSent) < a.params.Cooldown) {
+		state.suppressed++
+		return 0, false
+	}
+
+	suppressed := state.suppressed
+	state.sent = now
+	state.suppressed = 0
+	a.lastSent = now
+
+	// forget templates that have not alerted within the window.
+	for k, s := range a.dedup {
+		if now.Sub(s.sent) >= a.params.DedupWindow && s.suppressed == 0 {
+			delete(a.dedup, k)
+		}
+	}
+	return suppressed, true
+}
+
+// startSender delivers alerts until the alerts channel is closed.
+func (a *Alert) startSender() {
+	defer close(a.done)
+	for msg := range a.alerts {
+		if err := a.send(msg.text); err != nil {
+			msg.lgr.ReportError(err)
+		}
+	}
+}
+
+func (a *Alert) send(text string) error {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{Text: text})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.params.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, a.params.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("alert request fail: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("alert delivery fail: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert delivery fail: %s", resp.Status)
+	}
+	return nil
+}
+
+// alertFormatter formats a log record as a concise, single line alert.
+type alertFormatter struct{}
+
+// Format converts a log record to a concise alert, e.g. `[ERROR] msg key=val`.
+func (f *alertFormatter) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	if buf == nil {
+		buf = &bytes.Buffer{}
+	}
+	fmt.Fprintf(buf, "[%s] %s", strings.ToUpper(rec.Level().Name), rec.Msg())
+	if fields := rec.Fields(); len(fields) > 0 {
+		buf.WriteString(" ")
+		logr.WriteFields(buf, fields, " ")
+	}
+	return buf, nil
+}
@@ -0,0 +1,101 @@
+package target_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/target"
+)
+
+func TestAlertTarget(t *testing.T) {
+	var mux sync.Mutex
+	var alerts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		mux.Lock()
+		alerts = append(alerts, body.Text)
+		mux.Unlock()
+	}))
+	defer server.Close()
+
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+	tgt := target.NewAlertTarget(filter, &target.AlertParams{URL: server.URL})
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := lgr.NewLogger().WithField("db", "main")
+	for i := 0; i < 500; i++ {
+		logger.Errorf("connection lost after %d retries", i)
+	}
+	logger.Error("disk full")
+	logger.Warn("XXX")
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	// a second Shutdown, e.g. after RemoveTarget, is a no-op.
+	if err := tgt.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	want := []string{
+		"[ERROR] connection lost after 0 retries db=main",
+		"[ERROR] disk full db=main",
+	}
+	if len(alerts) != len(want) {
+		t.Fatalf("expected %d alerts, got %d: %q", len(want), len(alerts), alerts)
+	}
+	for i := range want {
+		if alerts[i] != want[i] {
+			t.Errorf("expected %q, got %q", want[i], alerts[i])
+		}
+	}
+}
+
+func TestAlertTargetDeliveryFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	var mux sync.Mutex
+	var errs []error
+	lgr := &logr.Logr{
+		OnLoggerError: func(err error) {
+			mux.Lock()
+			defer mux.Unlock()
+			errs = append(errs, err)
+		},
+	}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+	tgt := target.NewAlertTarget(filter, &target.AlertParams{URL: server.URL})
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	lgr.NewLogger().Error("boom")
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "500") {
+		t.Errorf("expected delivery error, got %v", errs)
+	}
+}
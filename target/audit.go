@@ -0,0 +1,211 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/wiggin77/merror"
+)
+
+const (
+	// DefAuditSyncInterval is the default maximum time a log record written
+	// by an AuditFile target can wait to be synced to disk when
+	// `SyncEveryRecord` is false.
+	DefAuditSyncInterval = time.Second
+
+	// DefAuditFilePerm is the default permission of files created by an
+	// AuditFile target.
+	DefAuditFilePerm os.FileMode = 0600
+)
+
+// AuditFileOptions configures an AuditFile target.
+type AuditFileOptions struct {
+	// Filename is the file to append log records to. Required.
+	Filename string
+
+	// Perm is the permission used if the file is created.
+	// Defaults to DefAuditFilePerm.
+	Perm os.FileMode
+
+	// SyncEveryRecord calls `File.Sync` after each log record is written, so
+	// each record is on stable storage before the next is written. This
+	// typically limits throughput to hundreds or low thousands of log records
+	// per second, depending on the storage device.
+	SyncEveryRecord bool
+
+	// SyncInterval is the maximum time a written log record can wait to be
+	// synced to disk when SyncEveryRecord is false. Records written within an
+	// interval are synced together, trading a window of possible loss for
+	// throughput. Defaults to DefAuditSyncInterval.
+	SyncInterval time.Duration
+
+	// Synchronous writes each log record in the goroutine making the logging
+	// call instead of queueing it; see `logr.Basic.SetSynchronous`. Combined
+	// with SyncEveryRecord, a logging call returns only once its log record
+	// is on stable storage. Every logging call that is enabled for this target
+	// then blocks for the duration of a disk sync, and calls from multiple
+	// goroutines are serialized.
+	Synchronous bool
+
+	// RecordSeparator is output after each log record.
+	// Defaults to `logr.DefRecordSeparator`.
+	RecordSeparator []byte
+
+	// LinePrefix is output before each formatted log record, e.g. a source
+	// token required by an ingestion service. Defaults to none.
+	LinePrefix []byte
+
+	// LineSuffix is output after each formatted log record, before the
+	// RecordSeparator. Defaults to none.
+	LineSuffix []byte
+}
+
+// AuditFile outputs log records to a single, non-rotated file, syncing writes
+// to stable storage. Use it for audit and compliance logs where durability
+// matters more than throughput; use File for general purpose logging.
+// Write and sync errors are reported via `Logr.ReportError`.
+type AuditFile struct {
+	logr.Basic
+
+	mux          sync.Mutex
+	file         *os.File
+	filename     string
+	perm         os.FileMode
+	syncEvery    bool
+	syncInterval time.Duration
+	syncTimer    *time.Timer
+	closed       bool
+}
+
+// NewAuditFileTarget creates a target that appends log records to a file,
+// syncing them to stable storage per the options.
+func NewAuditFileTarget(filter logr.Filter, formatter logr.Formatter, opts AuditFileOptions, maxQueue int) (*AuditFile, error) {
+	if opts.Filename == "" {
+		return nil, errors.New("audit filename cannot be empty")
+	}
+	perm := opts.Perm
+	if perm == 0 {
+		perm = DefAuditFilePerm
+	}
+	interval := opts.SyncInterval
+	if interval <= 0 {
+		interval = DefAuditSyncInterval
+	}
+
+	file, err := os.OpenFile(opts.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AuditFile{
+		file:         file,
+		filename:     opts.Filename,
+		perm:         perm,
+		syncEvery:    opts.SyncEveryRecord,
+		syncInterval: interval,
+	}
+	a.Basic.SetSynchronous(opts.Synchronous)
+	a.Basic.SetRecordSeparator(opts.RecordSeparator)
+	a.Basic.SetLinePrefix(opts.LinePrefix)
+	a.Basic.SetLineSuffix(opts.LineSuffix)
+	a.Basic.Start(a, a, filter, formatter, maxQueue)
+	return a, nil
+}
+
+// Write converts the log record to bytes, via the Formatter, and appends them
+// to the file, syncing per the options.
+func (a *AuditFile) Write(rec *logr.LogRec) error {
+	_, stacktrace := a.IsLevelEnabled(rec.Level())
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf.Write(a.LinePrefix())
+	buf, err := a.FormatRecord(rec, stacktrace, buf)
+	if err != nil {
+		return err
+	}
+	a.EndRecord(buf)
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.closed {
+		return errors.New("audit file closed")
+	}
+	if _, err = a.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if a.syncEvery {
+		return a.file.Sync()
+	}
+	if a.syncTimer == nil {
+		a.syncTimer = time.AfterFunc(a.syncInterval, func() {
+			if err := a.Sync(); err != nil {
+				rec.Logger().Logr().ReportError(err)
+			}
+		})
+	}
+	return nil
+}
+
+// Sync commits all log records written so far to stable storage. Log records
+// still queued are not written; call `Logr.Flush` first to include them.
+func (a *AuditFile) Sync() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.syncLocked()
+}
+
+func (a *AuditFile) syncLocked() error {
+	if a.syncTimer != nil {
+		a.syncTimer.Stop()
+		a.syncTimer = nil
+	}
+	if a.closed {
+		return nil
+	}
+	return a.file.Sync()
+}
+
+// Reopen syncs and closes the file, then reopens it by name, e.g. after
+// `logrotate` has renamed it. If the file cannot be reopened then the current
+// file remains open and an error is returned.
+func (a *AuditFile) Reopen() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if a.closed {
+		return errors.New("audit file closed")
+	}
+	file, err := os.OpenFile(a.filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, a.perm)
+	if err != nil {
+		return err
+	}
+	errs := merror.New()
+	errs.Append(a.syncLocked())
+	errs.Append(a.file.Close())
+	a.file = file
+	return errs.ErrorOrNil()
+}
+
+// Shutdown writes any remaining log records, syncs and closes the file.
+func (a *AuditFile) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+
+	err := a.Basic.Shutdown(ctx)
+	errs.Append(err)
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	errs.Append(a.syncLocked())
+	errs.Append(a.file.Close())
+	a.closed = true
+
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,94 @@
+package target_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+)
+
+func TestAuditFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logr_audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	readFile := func(filename string) string {
+		b, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	t.Run("synchronous, sync every record", func(t *testing.T) {
+		filename := filepath.Join(dir, "sync.log")
+		opts := target.AuditFileOptions{Filename: filename, SyncEveryRecord: true, Synchronous: true}
+		tgt, err := target.NewAuditFileTarget(filter, formatter, opts, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lgr := &logr.Logr{}
+		if err := lgr.AddTarget(tgt); err != nil {
+			t.Fatal(err)
+		}
+
+		lgr.NewLogger().WithField("user", "bob").Info("login")
+
+		// the record is on disk as soon as the logging call returns.
+		if got := readFile(filename); got != "info | login | user=bob\n" {
+			t.Errorf("unexpected file contents: %q", got)
+		}
+		if err := lgr.Shutdown(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("sync interval", func(t *testing.T) {
+		filename := filepath.Join(dir, "interval.log")
+		opts := target.AuditFileOptions{Filename: filename, SyncInterval: time.Millisecond * 10}
+		tgt, err := target.NewAuditFileTarget(filter, formatter, opts, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lgr := &logr.Logr{}
+		if err := lgr.AddTarget(tgt); err != nil {
+			t.Fatal(err)
+		}
+
+		logger := lgr.NewLogger()
+		for i := 0; i < 10; i++ {
+			logger.Info("record")
+		}
+		if err := lgr.Flush(); err != nil {
+			t.Error(err)
+		}
+		if err := tgt.Sync(); err != nil {
+			t.Error(err)
+		}
+		time.Sleep(time.Millisecond * 20) // allow the interval sync to run
+		if err := lgr.Shutdown(); err != nil {
+			t.Error(err)
+		}
+
+		if got := strings.Count(readFile(filename), "info | record"); got != 10 {
+			t.Errorf("expected 10 records, got %d", got)
+		}
+	})
+
+	t.Run("empty filename", func(t *testing.T) {
+		if _, err := target.NewAuditFileTarget(filter, formatter, target.AuditFileOptions{}, 100); err == nil {
+			t.Error("expected error for empty filename")
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package target
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/wiggin77/merror"
+)
+
+const (
+	// DefBufferedWriterSize is the default buffer size, in bytes, of a
+	// BufferedWriter target.
+	DefBufferedWriterSize = 32 * 1024
+
+	// DefBufferedFlushInterval is the default maximum time a log record can
+	// remain in a BufferedWriter target's buffer before being written.
+	DefBufferedFlushInterval = time.Second
+)
+
+// BufferedWriter outputs log records to an `io.Writer` via a buffer, reducing
+// the number of writes, e.g. syscalls when writing to a file. The buffer is
+// written when full, when a record at Error level or more severe is written,
+// at least once per flush interval, and on shutdown. Records still in the
+// buffer are lost if the process crashes; use Writer when that is unacceptable.
+// Write errors are reported via `Logr.ReportError`.
+type BufferedWriter struct {
+	logr.Basic
+
+	mux      sync.Mutex
+	buf      *bufio.Writer
+	interval time.Duration
+	timer    *time.Timer
+	closed   bool
+}
+
+// NewBufferedWriterTarget creates a target that outputs log records to an
+// io.Writer via a buffer of bufSize bytes, flushing at least once per
+// flushInterval. Zero bufSize and flushInterval default to DefBufferedWriterSize
+// and DefBufferedFlushInterval respectively.
+func NewBufferedWriterTarget(filter logr.Filter, formatter logr.Formatter, w io.Writer, bufSize int, flushInterval time.Duration, maxQueued int) (*BufferedWriter, error) {
+	if w == nil {
+		return nil, errors.New("buffered writer cannot be nil")
+	}
+	if bufSize <= 0 {
+		bufSize = DefBufferedWriterSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefBufferedFlushInterval
+	}
+
+	bw := &BufferedWriter{
+		buf:      bufio.NewWriterSize(w, bufSize),
+		interval: flushInterval,
+	}
+	bw.Basic.Start(bw, bw, filter, formatter, maxQueued)
+	return bw, nil
+}
+
+// Write converts the log record to bytes, via the Formatter, and adds them to
+// the buffer, flushing as needed.
+func (bw *BufferedWriter) Write(rec *logr.LogRec) error {
+	_, stacktrace := bw.IsLevelEnabled(rec.Level())
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf.Write(bw.LinePrefix())
+	buf, err := bw.FormatRecord(rec, stacktrace, buf)
+	if err != nil {
+		return err
+	}
+	bw.EndRecord(buf)
+
+	bw.mux.Lock()
+	defer bw.mux.Unlock()
+
+	if bw.closed {
+		return errors.New("buffered writer closed")
+	}
+	if _, err = bw.buf.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	// flush errors and worse immediately so they survive a crash.
+	if rec.Level().ID <= logr.Error.ID {
+		return bw.flushLocked()
+	}
+	if bw.timer == nil && bw.buf.Buffered() > 0 {
+		bw.timer = time.AfterFunc(bw.interval, func() {
+			if err := bw.Flush(); err != nil {
+				rec.Logger().Logr().ReportError(err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush writes any buffered log records to the io.Writer. Log records still
+// queued are not written; call `Logr.Flush` first to include them.
+func (bw *BufferedWriter) Flush() error {
+	bw.mux.Lock()
+	defer bw.mux.Unlock()
+	return bw.flushLocked()
+}
+
+func (bw *BufferedWriter) flushLocked() error {
+	if bw.timer != nil {
+		bw.timer.Stop()
+		bw.timer = nil
+	}
+	if bw.closed {
+		return nil
+	}
+	return bw.buf.Flush()
+}
+
+// Shutdown writes any remaining log records, including those buffered, to the
+// io.Writer. The io.Writer is not closed.
+func (bw *BufferedWriter) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+
+	err := bw.Basic.Shutdown(ctx)
+	errs.Append(err)
+
+	bw.mux.Lock()
+	defer bw.mux.Unlock()
+
+	errs.Append(bw.flushLocked())
+	bw.closed = true
+
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,126 @@
+package target_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mux sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mux.Lock()
+	defer sb.mux.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mux.Lock()
+	defer sb.mux.Unlock()
+	return sb.buf.String()
+}
+
+func TestBufferedWriter(t *testing.T) {
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	newLogr := func(t *testing.T, out *syncBuffer, interval time.Duration) (*logr.Logr, *target.BufferedWriter) {
+		tgt, err := target.NewBufferedWriterTarget(filter, formatter, out, 4096, interval, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lgr := &logr.Logr{}
+		if err := lgr.AddTarget(tgt); err != nil {
+			t.Fatal(err)
+		}
+		return lgr, tgt
+	}
+
+	t.Run("buffers until flush", func(t *testing.T) {
+		out := &syncBuffer{}
+		lgr, tgt := newLogr(t, out, time.Hour)
+		lgr.NewLogger().Info("buffered")
+		if err := lgr.Flush(); err != nil {
+			t.Error(err)
+		}
+		if got := out.String(); got != "" {
+			t.Errorf("expected nothing written before flush, got %q", got)
+		}
+		if err := tgt.Flush(); err != nil {
+			t.Error(err)
+		}
+		if got := out.String(); got != "info | buffered | \n" {
+			t.Errorf("unexpected output: %q", got)
+		}
+		if err := lgr.Shutdown(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("error flushes", func(t *testing.T) {
+		out := &syncBuffer{}
+		lgr, _ := newLogr(t, out, time.Hour)
+		logger := lgr.NewLogger()
+		logger.Info("first")
+		logger.Error("second")
+		if err := lgr.Flush(); err != nil {
+			t.Error(err)
+		}
+		if got := out.String(); got != "info | first | \nerror | second | \n" {
+			t.Errorf("unexpected output: %q", got)
+		}
+		if err := lgr.Shutdown(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("interval flushes", func(t *testing.T) {
+		out := &syncBuffer{}
+		lgr, _ := newLogr(t, out, time.Millisecond*10)
+		lgr.NewLogger().Info("timed")
+		if err := lgr.Flush(); err != nil {
+			t.Error(err)
+		}
+		deadline := time.Now().Add(time.Second * 5)
+		for out.String() == "" && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 5)
+		}
+		if got := out.String(); got != "info | timed | \n" {
+			t.Errorf("unexpected output: %q", got)
+		}
+		if err := lgr.Shutdown(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("shutdown flushes", func(t *testing.T) {
+		out := &syncBuffer{}
+		lgr, _ := newLogr(t, out, time.Hour)
+		logger := lgr.NewLogger()
+		for i := 0; i < 1000; i++ {
+			logger.Info("record")
+		}
+		if err := lgr.Shutdown(); err != nil {
+			t.Error(err)
+		}
+		if got := strings.Count(out.String(), "info | record"); got != 1000 {
+			t.Errorf("expected 1000 records, got %d", got)
+		}
+	})
+
+	t.Run("nil writer", func(t *testing.T) {
+		if _, err := target.NewBufferedWriterTarget(filter, formatter, nil, 0, 0, 100); err == nil {
+			t.Error("expected error for nil writer")
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package target
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/wiggin77/merror"
+)
+
+const (
+	// DefaultCompressFlushInterval is how often a CompressedWriter flushes
+	// pending compressed data to the underlying io.Writer.
+	DefaultCompressFlushInterval = time.Second
+)
+
+// CompressedWriter outputs gzip compressed log records to any `io.Writer`.
+// The gzip stream is flushed periodically so that output truncated by a
+// crash remains readable up to the last flush, and is finalized on `Shutdown`.
+type CompressedWriter struct {
+	logr.Basic
+
+	mux sync.Mutex
+	gz  *gzip.Writer
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewCompressedWriterTarget creates a target capable of outputting gzip compressed
+// log records to an io.Writer. Level is a compression level from `compress/gzip`,
+// e.g. `gzip.DefaultCompression`.
+func NewCompressedWriterTarget(filter logr.Filter, formatter logr.Formatter, out io.Writer, level int, maxQueue int) (*CompressedWriter, error) {
+	if out == nil {
+		out = ioutil.Discard
+	}
+	gz, err := gzip.NewWriterLevel(out, level)
+	if err != nil {
+		return nil, err
+	}
+	w := &CompressedWriter{gz: gz, done: make(chan struct{})}
+	w.Basic.Start(w, w, filter, formatter, maxQueue)
+	go w.startFlusher(DefaultCompressFlushInterval)
+	return w, nil
+}
+
+// Write converts the log record to bytes, via the Formatter,
+// and outputs to the gzip stream.
+func (w *CompressedWriter) Write(rec *logr.LogRec) error {
+	_, stacktrace := w.IsLevelEnabled(rec.Level())
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf.Write(w.LinePrefix())
+	buf, err := w.FormatRecord(rec, stacktrace, buf)
+	if err != nil {
+		return err
+	}
+	w.EndRecord(buf)
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	_, err = w.gz.Write(buf.Bytes())
+	return err
+}
+
+// Shutdown flushes any remaining log records and closes the gzip stream,
+// writing the gzip footer. The underlying io.Writer is not closed.
+func (w *CompressedWriter) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+
+	err := w.Basic.Shutdown(ctx)
+	errs.Append(err)
+
+	w.doneOnce.Do(func() { close(w.done) })
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	err = w.gz.Close()
+	errs.Append(err)
+
+	return errs.ErrorOrNil()
+}
+
+// startFlusher flushes the gzip stream every interval until shutdown.
+func (w *CompressedWriter) startFlusher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mux.Lock()
+			_ = w.gz.Flush()
+			w.mux.Unlock()
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package target_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+)
+
+func TestCompressedWriter(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}
+	formatter := &format.Plain{Delim: " | "}
+	tgt, err := target.NewCompressedWriterTarget(filter, formatter, buf, gzip.BestSpeed, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = lgr.AddTarget(tgt)
+
+	const goodToken = "Woot!"
+	const badToken = "XXX!!XXX"
+
+	cfg := test.DoSomeLoggingCfg{
+		Lgr:        lgr,
+		Goroutines: 10,
+		Loops:      50,
+		GoodToken:  goodToken,
+		BadToken:   badToken,
+		Lvl:        logr.Error,
+		Delay:      time.Millisecond * 1,
+	}
+	test.DoSomeLogging(cfg)
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// a second Shutdown, e.g. after RemoveTarget, is a no-op.
+	if err := tgt.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(data)
+
+	if !strings.Contains(output, goodToken) {
+		t.Errorf("missing log records")
+	}
+
+	if strings.Contains(output, badToken) {
+		t.Errorf("wrong level(s) enabled")
+	}
+}
+
+func TestCompressedWriterBadLevel(t *testing.T) {
+	filter := &logr.StdFilter{Lvl: logr.Error}
+	formatter := &format.Plain{}
+	_, err := target.NewCompressedWriterTarget(filter, formatter, &test.Buffer{}, 42, 1000)
+	if err == nil {
+		t.Error("expected error for invalid compression level")
+	}
+}
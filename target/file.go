@@ -34,6 +34,18 @@ type FileOptions struct {
 	// Compress determines if the rotated log files should be compressed
 	// using gzip. The default is not to perform compression.
 	Compress bool
+
+	// RecordSeparator is output after each log record, e.g. `\r\n` for files
+	// read on Windows. Defaults to `logr.DefRecordSeparator`.
+	RecordSeparator []byte
+
+	// LinePrefix is output before each formatted log record, e.g. a source
+	// token required by an ingestion service. Defaults to none.
+	LinePrefix []byte
+
+	// LineSuffix is output after each formatted log record, before the
+	// RecordSeparator. Defaults to none.
+	LineSuffix []byte
 }
 
 // File outputs log records to a file which can be log rotated based on size or age.
@@ -53,6 +65,9 @@ func NewFileTarget(filter logr.Filter, formatter logr.Formatter, opts FileOption
 		Compress:   opts.Compress,
 	}
 	f := &File{out: lumber}
+	f.Basic.SetRecordSeparator(opts.RecordSeparator)
+	f.Basic.SetLinePrefix(opts.LinePrefix)
+	f.Basic.SetLineSuffix(opts.LineSuffix)
 	f.Basic.Start(f, f, filter, formatter, maxQueue)
 	return f
 }
@@ -65,14 +80,23 @@ func (f *File) Write(rec *logr.LogRec) error {
 	buf := rec.Logger().Logr().BorrowBuffer()
 	defer rec.Logger().Logr().ReleaseBuffer(buf)
 
-	buf, err := f.Formatter().Format(rec, stacktrace, buf)
+	buf.Write(f.LinePrefix())
+	buf, err := f.FormatRecord(rec, stacktrace, buf)
 	if err != nil {
 		return err
 	}
+	f.EndRecord(buf)
 	_, err = f.out.Write(buf.Bytes())
 	return err
 }
 
+// Reopen closes the file so that it is reopened, by name, when the next log
+// record is written, e.g. after `logrotate` has renamed it. Log records already
+// queued are written to the reopened file.
+func (f *File) Reopen() error {
+	return f.out.Close()
+}
+
 // Shutdown flushes any remaining log records and closes the file.
 func (f *File) Shutdown(ctx context.Context) error {
 	errs := merror.New()
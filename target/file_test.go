@@ -3,7 +3,9 @@ package target_test
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -113,3 +115,82 @@ func fileContains(t *testing.T, filename string, text string) bool {
 	}
 	return false
 }
+
+func TestFileReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logr_reopen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	tests := []struct {
+		name      string
+		newTarget func(filename string) (logr.Target, error)
+	}{
+		{
+			name: "file",
+			newTarget: func(filename string) (logr.Target, error) {
+				return target.NewFileTarget(filter, formatter, target.FileOptions{Filename: filename}, 100), nil
+			},
+		},
+		{
+			name: "audit file",
+			newTarget: func(filename string) (logr.Target, error) {
+				return target.NewAuditFileTarget(filter, formatter, target.AuditFileOptions{Filename: filename}, 100)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, strings.Replace(tt.name, " ", "_", -1)+".log")
+			tgt, err := tt.newTarget(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lgr := &logr.Logr{}
+			if err := lgr.AddTarget(target.NewTeeTarget(tgt)); err != nil {
+				t.Fatal(err)
+			}
+			logger := lgr.NewLogger()
+
+			logger.Info("before rotate")
+			if err := lgr.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			// simulate logrotate: rename the file then signal a reopen.
+			rotated := filename + ".1"
+			if err := os.Rename(filename, rotated); err != nil {
+				t.Fatal(err)
+			}
+			if err := lgr.Reopen(); err != nil {
+				t.Fatal(err)
+			}
+
+			logger.Info("after rotate")
+			if err := lgr.Shutdown(); err != nil {
+				t.Error(err)
+			}
+
+			if got := readFile(t, rotated); got != "info | before rotate | \n" {
+				t.Errorf("unexpected rotated file contents: %q", got)
+			}
+			if got := readFile(t, filename); got != "info | after rotate | \n" {
+				t.Errorf("unexpected reopened file contents: %q", got)
+			}
+		})
+	}
+}
+
+func readFile(t *testing.T, filename string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
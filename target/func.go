@@ -0,0 +1,42 @@
+package target
+
+import (
+	"github.com/mattermost/logr"
+)
+
+// Func outputs log records by calling a user supplied function for each
+// log record that passes the filter.
+type Func struct {
+	logr.Basic
+	fn func(formatted []byte, rec *logr.LogRec)
+}
+
+// NewFuncTarget creates a target that calls fn for each log record that passes
+// the filter. fn receives both the formatted bytes and the log record. The
+// target serializes writes, so calls to fn are never concurrent, but they may
+// be made from different goroutines, e.g. the caller's when `SetSynchronous`
+// is used. The formatted bytes are only valid for the duration of the call;
+// copy them if they need to be retained.
+func NewFuncTarget(filter logr.Filter, formatter logr.Formatter, fn func(formatted []byte, rec *logr.LogRec), maxQueue int) *Func {
+	f := &Func{fn: fn}
+	f.Basic.Start(f, f, filter, formatter, maxQueue)
+	return f
+}
+
+// Write converts the log record to bytes, via the Formatter,
+// and passes them to the user function.
+func (f *Func) Write(rec *logr.LogRec) error {
+	_, stacktrace := f.IsLevelEnabled(rec.Level())
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf.Write(f.LinePrefix())
+	buf, err := f.FormatRecord(rec, stacktrace, buf)
+	if err != nil {
+		return err
+	}
+	f.EndRecord(buf)
+	f.fn(buf.Bytes(), rec)
+	return nil
+}
@@ -0,0 +1,103 @@
+package target_test
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+)
+
+func TestFunc(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Warn, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	var mux sync.Mutex
+	var lines []string
+	var levels []logr.Level
+
+	fn := func(formatted []byte, rec *logr.LogRec) {
+		mux.Lock()
+		defer mux.Unlock()
+		lines = append(lines, string(formatted))
+		levels = append(levels, rec.Level())
+	}
+
+	tgt := target.NewFuncTarget(filter, formatter, fn, 1000)
+	err := lgr.AddTarget(tgt)
+	if err != nil {
+		t.Error(err)
+	}
+
+	logger := lgr.NewLogger().WithField("name", "wiggin")
+	logger.Error("first")
+	logger.Info("XXX")
+	logger.Warn("second")
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 callbacks, got %d", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], "error | first | name=wiggin") || levels[0] != logr.Error {
+		t.Errorf("unexpected first record: %s", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "warn | second | name=wiggin") || levels[1] != logr.Warn {
+		t.Errorf("unexpected second record: %s", lines[1])
+	}
+}
+
+func TestFuncSerialized(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true}
+
+	var active, overlaps, calls int32
+	fn := func(formatted []byte, rec *logr.LogRec) {
+		if atomic.AddInt32(&active, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		atomic.AddInt32(&calls, 1)
+		atomic.AddInt32(&active, -1)
+	}
+
+	// synchronous targets call fn from each logging goroutine.
+	tgt := target.NewFuncTarget(filter, formatter, fn, 1000)
+	tgt.SetSynchronous(true)
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	logger := lgr.NewLogger()
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info("concurrent")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+	if calls != 1000 {
+		t.Errorf("expected 1000 calls, got %d", calls)
+	}
+	if overlaps != 0 {
+		t.Errorf("fn called concurrently %d times", overlaps)
+	}
+}
@@ -0,0 +1,220 @@
+package target
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	_ "crypto/sha256" // register SHA-224 and SHA-256
+	_ "crypto/sha512" // register SHA-384 and SHA-512
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/mattermost/logr"
+)
+
+// hashChainAlgos maps the supported hash algorithms to the names used in
+// hash chain tokens.
+var hashChainAlgos = map[crypto.Hash]string{
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+// HashChainParams configures a HashChain target.
+type HashChainParams struct {
+	// Algo is the hash algorithm; one of crypto.SHA256, crypto.SHA384 or
+	// crypto.SHA512. Defaults to crypto.SHA256.
+	Algo crypto.Hash
+
+	// PrevHash is the hash of the last log record already in the output, when
+	// appending to an existing chain after a restart. Use `ResumeHashChain` to
+	// verify the existing output and obtain it. Empty starts a new chain.
+	PrevHash []byte
+}
+
+// HashChain outputs log records to an `io.Writer`, appending to each a hash of
+// the log record and the previous record's hash. The hashes form a chain, so
+// deleting, inserting, reordering or modifying any record can be detected
+// using `VerifyHashChain`. This detects accidental or casual tampering; since
+// the hashes are not keyed, anyone able to rewrite the whole output can also
+// recompute the chain.
+//
+// Each log record is output followed by a space and a token of the form
+// `sha256:<hex hash>` and a newline, where the hash is H(prev hash || record)
+// and the record excludes the formatter's trailing newline. The newline is
+// required to verify the chain, so the target's line prefix, line suffix and
+// record separator are not used.
+type HashChain struct {
+	logr.Basic
+	out  io.Writer
+	algo crypto.Hash
+	name string
+
+	mux  sync.Mutex
+	prev []byte
+}
+
+// NewHashChainTarget creates a target that outputs hash chained log records to
+// an io.Writer.
+func NewHashChainTarget(filter logr.Filter, formatter logr.Formatter, out io.Writer, params HashChainParams, maxQueue int) (*HashChain, error) {
+	if out == nil {
+		return nil, errors.New("hash chain writer cannot be nil")
+	}
+	algo := params.Algo
+	if algo == 0 {
+		algo = crypto.SHA256
+	}
+	name, ok := hashChainAlgos[algo]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash chain algorithm %v", algo)
+	}
+	if len(params.PrevHash) != 0 && len(params.PrevHash) != algo.Size() {
+		return nil, fmt.Errorf("previous hash must be %d bytes for %s", algo.Size(), name)
+	}
+
+	hc := &HashChain{
+		out:  out,
+		algo: algo,
+		name: name,
+		prev: append([]byte(nil), params.PrevHash...),
+	}
+	hc.Basic.Start(hc, hc, filter, formatter, maxQueue)
+	return hc, nil
+}
+
+// Write converts the log record to bytes, via the Formatter, appends the
+// chained hash and outputs to the io.Writer.
+func (hc *HashChain) Write(rec *logr.LogRec) error {
+	_, stacktrace := hc.IsLevelEnabled(rec.Level())
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf, err := hc.FormatRecord(rec, stacktrace, buf)
+	if err != nil {
+		return err
+	}
+	record := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+
+	hc.mux.Lock()
+	defer hc.mux.Unlock()
+
+	sum := chainHash(hc.algo, hc.prev, record)
+	line := make([]byte, 0, len(record)+len(hc.name)+2*len(sum)+3)
+	line = append(line, record...)
+	line = append(line, ' ')
+	line = append(line, hc.name...)
+	line = append(line, ':')
+	line = append(line, hex.EncodeToString(sum)...)
+	line = append(line, '\n')
+
+	if _, err = hc.out.Write(line); err != nil {
+		return err
+	}
+	hc.prev = sum
+	return nil
+}
+
+// LastHash returns the hash of the last log record written, which can be
+// persisted to resume the chain, or nil if none has been written.
+func (hc *HashChain) LastHash() []byte {
+	hc.mux.Lock()
+	defer hc.mux.Unlock()
+	return append([]byte(nil), hc.prev...)
+}
+
+// VerifyHashChain reads hash chained log records, as output by a HashChain
+// target, and returns an error identifying the first record whose hash does
+// not match, or nil if the whole chain is intact. The chain must start at the
+// beginning of r.
+func VerifyHashChain(r io.Reader) error {
+	_, err := ResumeHashChain(r)
+	return err
+}
+
+// ResumeHashChain verifies hash chained log records like `VerifyHashChain`,
+// and returns the hash of the last record for use as `HashChainParams.PrevHash`
+// when appending more records to the same output. Returns nil if r is empty.
+func ResumeHashChain(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	var prev []byte
+	var record bytes.Buffer
+	var lineNum, recNum int
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		lineNum++
+		line = strings.TrimSuffix(line, "\n")
+
+		algo, sum, content, ok := splitHashToken(line)
+		if !ok {
+			// part of a multi-line record, e.g. a stack trace.
+			if err == io.EOF {
+				return nil, fmt.Errorf("hash chain line %d: missing hash", lineNum)
+			}
+			record.WriteString(line)
+			record.WriteByte('\n')
+			continue
+		}
+		recNum++
+		record.WriteString(content)
+
+		want := chainHash(algo, prev, record.Bytes())
+		if !bytes.Equal(sum, want) {
+			return nil, fmt.Errorf("hash chain line %d: hash mismatch for record %d", lineNum, recNum)
+		}
+		prev = sum
+		record.Reset()
+
+		if err == io.EOF {
+			break
+		}
+	}
+	if record.Len() > 0 {
+		return nil, fmt.Errorf("hash chain line %d: missing hash", lineNum)
+	}
+	return prev, nil
+}
+
+// splitHashToken splits a line into the record content and the trailing hash
+// token, returning false if the line does not end with a valid token.
+func splitHashToken(line string) (algo crypto.Hash, sum []byte, content string, ok bool) {
+	idx := strings.LastIndexByte(line, ' ')
+	if idx < 0 {
+		return 0, nil, "", false
+	}
+	token := line[idx+1:]
+	colon := strings.IndexByte(token, ':')
+	if colon < 0 {
+		return 0, nil, "", false
+	}
+	name := token[:colon]
+	for a, n := range hashChainAlgos {
+		if n != name {
+			continue
+		}
+		sum, err := hex.DecodeString(token[colon+1:])
+		if err != nil || len(sum) != a.Size() {
+			return 0, nil, "", false
+		}
+		return a, sum, line[:idx], true
+	}
+	return 0, nil, "", false
+}
+
+// chainHash returns H(prev || record).
+func chainHash(algo crypto.Hash, prev []byte, record []byte) []byte {
+	h := algo.New()
+	h.Write(prev)
+	h.Write(record)
+	return h.Sum(nil)
+}
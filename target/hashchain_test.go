@@ -0,0 +1,66 @@
+package target_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+	"github.com/mattermost/logr/test"
+)
+
+func TestHashChain(t *testing.T) {
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	buf := &test.Buffer{}
+
+	logRecords := func(prev []byte, msgs ...string) []byte {
+		tgt, err := target.NewHashChainTarget(filter, formatter, buf, target.HashChainParams{PrevHash: prev}, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lgr := &logr.Logr{}
+		if err := lgr.AddTarget(tgt); err != nil {
+			t.Fatal(err)
+		}
+		logger := lgr.NewLogger().WithField("user", "bob")
+		for _, msg := range msgs {
+			logger.Info(msg)
+		}
+		logger.Error("with stack trace") // multi-line record
+		if err := lgr.Shutdown(); err != nil {
+			t.Error(err)
+		}
+		return tgt.LastHash()
+	}
+
+	last := logRecords(nil, "one", "two", "three")
+
+	// resume the chain after a restart.
+	prev, err := target.ResumeHashChain(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(prev, last) {
+		t.Fatalf("resumed hash %x does not match last hash %x", prev, last)
+	}
+	logRecords(prev, "four", "five")
+
+	output := buf.String()
+	if err := target.VerifyHashChain(strings.NewReader(output)); err != nil {
+		t.Errorf("intact chain failed verification: %v", err)
+	}
+
+	tampered := map[string]string{
+		"modified":  strings.Replace(output, "info | two", "info | 2", 1),
+		"deleted":   strings.Replace(output, strings.SplitAfter(output, "\n")[1], "", 1),
+		"truncated": strings.TrimSuffix(output, "\n")[:len(output)-10],
+	}
+	for name, s := range tampered {
+		if err := target.VerifyHashChain(strings.NewReader(s)); err == nil {
+			t.Errorf("%s chain passed verification", name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package target
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/mattermost/logr"
+)
+
+// NewLeveledFileSet creates a Tee of rotated File targets, one per entry in
+// levelFiles, where each file receives log records at its level or more
+// severe. For example, mapping Error to `error.log`, Warn to `warn.log` and
+// Info to `app.log` writes an error record to all three files. Filenames are
+// relative to dir. Files use the default rotation settings of `FileOptions`.
+// Add the returned Tee to a Logr; it shuts down and closes all files together.
+func NewLeveledFileSet(dir string, formatter logr.Formatter, levelFiles map[logr.Level]string, maxQueued int) (*Tee, error) {
+	return newLeveledFileSet(dir, formatter, levelFiles, maxQueued, func(lvl logr.Level) logr.Filter {
+		return &logr.StdFilter{Lvl: lvl, Stacktrace: logr.Panic}
+	})
+}
+
+// NewExactLeveledFileSet is the same as `NewLeveledFileSet` except each file
+// receives only log records of exactly its level.
+func NewExactLeveledFileSet(dir string, formatter logr.Formatter, levelFiles map[logr.Level]string, maxQueued int) (*Tee, error) {
+	return newLeveledFileSet(dir, formatter, levelFiles, maxQueued, func(lvl logr.Level) logr.Filter {
+		return &logr.StdFilter{Lvl: lvl, Stacktrace: logr.Panic, Exact: true}
+	})
+}
+
+func newLeveledFileSet(dir string, formatter logr.Formatter, levelFiles map[logr.Level]string,
+	maxQueued int, newFilter func(lvl logr.Level) logr.Filter) (*Tee, error) {
+	if len(levelFiles) == 0 {
+		return nil, errors.New("leveled file set requires at least one level")
+	}
+
+	levels := make([]logr.Level, 0, len(levelFiles))
+	seen := make(map[string]logr.Level, len(levelFiles))
+	for lvl, name := range levelFiles {
+		if name == "" {
+			return nil, fmt.Errorf("filename for level %s cannot be empty", lvl)
+		}
+		filename := filepath.Join(dir, name)
+		if other, ok := seen[filename]; ok {
+			return nil, fmt.Errorf("levels %s and %s cannot share file %s", other, lvl, filename)
+		}
+		seen[filename] = lvl
+		levels = append(levels, lvl)
+	}
+	// most severe first, for deterministic output order.
+	sort.Slice(levels, func(i, j int) bool { return levels[i].ID < levels[j].ID })
+
+	targets := make([]logr.Target, 0, len(levels))
+	for _, lvl := range levels {
+		filename := filepath.Join(dir, levelFiles[lvl])
+		f := NewFileTarget(newFilter(lvl), formatter, FileOptions{Filename: filename}, maxQueued)
+		f.SetName(filename)
+		targets = append(targets, f)
+	}
+
+	tee := NewTeeTarget(targets...)
+	tee.SetName("LeveledFileSet")
+	return tee, nil
+}
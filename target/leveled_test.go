@@ -0,0 +1,94 @@
+package target_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+)
+
+func TestLeveledFileSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logr_leveled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+	levelFiles := map[logr.Level]string{
+		logr.Error: "error.log",
+		logr.Warn:  "warn.log",
+		logr.Info:  "app.log",
+	}
+
+	tests := []struct {
+		name   string
+		subdir string
+		newSet func(dir string, formatter logr.Formatter, levelFiles map[logr.Level]string, maxQueued int) (*target.Tee, error)
+		want   map[string]string
+	}{
+		{
+			name:   "at or above",
+			subdir: "above",
+			newSet: target.NewLeveledFileSet,
+			want: map[string]string{
+				"error.log": "error | e | \n",
+				"warn.log":  "error | e | \nwarn | w | \n",
+				"app.log":   "error | e | \nwarn | w | \ninfo | i | \n",
+			},
+		},
+		{
+			name:   "exact",
+			subdir: "exact",
+			newSet: target.NewExactLeveledFileSet,
+			want: map[string]string{
+				"error.log": "error | e | \n",
+				"warn.log":  "warn | w | \n",
+				"app.log":   "info | i | \n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setDir := filepath.Join(dir, tt.subdir)
+			tee, err := tt.newSet(setDir, formatter, levelFiles, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lgr := &logr.Logr{}
+			if err = lgr.AddTarget(tee); err != nil {
+				t.Fatal(err)
+			}
+
+			logger := lgr.NewLogger()
+			logger.Error("e")
+			logger.Warn("w")
+			logger.Info("i")
+			logger.Debug("d")
+
+			if err = lgr.Shutdown(); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, want := range tt.want {
+				b, err := ioutil.ReadFile(filepath.Join(setDir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := string(b); got != want {
+					t.Errorf("%s: expected: %q;  got: %q", name, want, got)
+				}
+			}
+		})
+	}
+
+	_, err = target.NewLeveledFileSet(dir, formatter, map[logr.Level]string{logr.Error: "same.log", logr.Warn: "same.log"}, 100)
+	if err == nil {
+		t.Error("expected error for levels sharing a file")
+	}
+}
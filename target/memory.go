@@ -0,0 +1,96 @@
+package target
+
+import (
+	"sync"
+
+	"github.com/mattermost/logr"
+)
+
+// LeveledMemory keeps the most recent log records in memory, in a separate
+// fixed size ring buffer per level, e.g. to show recent errors and recent
+// warnings on a diagnostics page. Partitioning by level means a flood of
+// Debug log records does not evict recent Error log records.
+type LeveledMemory struct {
+	logr.Basic
+
+	capacity int
+
+	mux   sync.RWMutex
+	rings map[logr.LevelID]*recRing
+}
+
+// NewLeveledMemoryTarget creates a target that keeps up to capacityPerLevel
+// of the most recent log records for each level that passes the filter,
+// including custom levels. capacityPerLevel less than one is treated as one.
+func NewLeveledMemoryTarget(filter logr.Filter, capacityPerLevel int, maxQueue int) *LeveledMemory {
+	if capacityPerLevel < 1 {
+		capacityPerLevel = 1
+	}
+	m := &LeveledMemory{
+		capacity: capacityPerLevel,
+		rings:    make(map[logr.LevelID]*recRing),
+	}
+	m.Basic.Start(m, m, filter, nil, maxQueue)
+	return m
+}
+
+// Write keeps the log record, discarding the oldest log record of the same
+// level if its ring buffer is full.
+func (m *LeveledMemory) Write(rec *logr.LogRec) error {
+	id := rec.Level().ID
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	ring, ok := m.rings[id]
+	if !ok {
+		ring = &recRing{recs: make([]*logr.LogRec, m.capacity)}
+		m.rings[id] = ring
+	}
+	ring.add(rec)
+	return nil
+}
+
+// RecentByLevel returns a copy of the most recent log records kept for the
+// level, oldest first. The log records are shared and must not be modified.
+func (m *LeveledMemory) RecentByLevel(lvl logr.Level) []*logr.LogRec {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	ring, ok := m.rings[lvl.ID]
+	if !ok {
+		return nil
+	}
+	return ring.list()
+}
+
+// Reset discards all log records kept.
+func (m *LeveledMemory) Reset() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.rings = make(map[logr.LevelID]*recRing)
+}
+
+// recRing is a fixed size ring buffer of log records.
+type recRing struct {
+	recs []*logr.LogRec
+	next int
+	full bool
+}
+
+func (r *recRing) add(rec *logr.LogRec) {
+	r.recs[r.next] = rec
+	r.next++
+	if r.next == len(r.recs) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// list returns the log records, oldest first.
+func (r *recRing) list() []*logr.LogRec {
+	if !r.full {
+		return append([]*logr.LogRec{}, r.recs[:r.next]...)
+	}
+	out := make([]*logr.LogRec, 0, len(r.recs))
+	out = append(out, r.recs[r.next:]...)
+	return append(out, r.recs[:r.next]...)
+}
@@ -0,0 +1,73 @@
+package target_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/target"
+)
+
+func TestLeveledMemory(t *testing.T) {
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic}
+	tgt := target.NewLeveledMemoryTarget(filter, 3, 1000)
+	err := lgr.AddTarget(tgt)
+	if err != nil {
+		t.Error(err)
+	}
+
+	logger := lgr.NewLogger()
+	logger.Error("error 1")
+	logger.Warn("warn 1")
+	logger.Error("error 2")
+	// a flood of debug must not evict the errors.
+	for i := 0; i < 100; i++ {
+		logger.Debug(fmt.Sprintf("debug %d", i))
+	}
+	logger.Trace("not enabled")
+
+	err = lgr.Flush()
+	if err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		level logr.Level
+		want  []string
+	}{
+		{level: logr.Error, want: []string{"error 1", "error 2"}},
+		{level: logr.Warn, want: []string{"warn 1"}},
+		{level: logr.Debug, want: []string{"debug 97", "debug 98", "debug 99"}},
+		{level: logr.Trace, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.Name, func(t *testing.T) {
+			recs := tgt.RecentByLevel(tt.level)
+			var got []string
+			for _, rec := range recs {
+				got = append(got, rec.Msg())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected: %v;  got: %v", tt.want, got)
+			}
+		})
+	}
+
+	// returned slices are copies.
+	recs := tgt.RecentByLevel(logr.Error)
+	recs[0] = nil
+	if tgt.RecentByLevel(logr.Error)[0] == nil {
+		t.Error("RecentByLevel did not return a copy")
+	}
+
+	tgt.Reset()
+	if recs := tgt.RecentByLevel(logr.Error); len(recs) != 0 {
+		t.Errorf("expected no log records after Reset, got %d", len(recs))
+	}
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+}
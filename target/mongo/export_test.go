@@ -0,0 +1,9 @@
+package mongo
+
+import "github.com/mattermost/logr"
+
+// NewMongoTargetWithCollection creates a target that inserts into coll
+// instead of params.Collection, so tests do not need a MongoDB server.
+func NewMongoTargetWithCollection(filter logr.Filter, params *MongoParams, coll inserter, maxQueue int) (*Mongo, error) {
+	return newMongoTarget(filter, params, coll, maxQueue), nil
+}
@@ -0,0 +1,263 @@
+// Package mongo provides a target that inserts log records into a MongoDB
+// collection as documents using the official MongoDB driver. It is a separate
+// module so only applications that use it depend on the MongoDB driver.
+//
+// For automatic retention create the collection as a capped collection before
+// use, e.g. via `db.CreateCollection(ctx, "logs", options.CreateCollection().
+// SetCapped(true).SetSizeInBytes(size))`.
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/francoispqt/gojay"
+	"github.com/mattermost/logr"
+	"github.com/wiggin77/merror"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DefaultBatchSize is the maximum number of documents inserted at once.
+	DefaultBatchSize = 100
+
+	// DefaultFlushInterval is how often a partial batch is inserted.
+	DefaultFlushInterval = time.Second
+
+	// DefaultInsertTimeout is the time allowed for each batch insert.
+	DefaultInsertTimeout = 10 * time.Second
+)
+
+// inserter is the subset of `*mongo.Collection` used by the target.
+type inserter interface {
+	InsertMany(ctx context.Context, docs []interface{}, opts ...*options.InsertManyOptions) (*mongodriver.InsertManyResult, error)
+}
+
+// MongoParams provides parameters for inserting log records into MongoDB.
+type MongoParams struct {
+	// Collection is the collection log records are inserted into. The
+	// target does not disconnect the collection's client on shutdown.
+	Collection *mongodriver.Collection
+
+	// BatchSize is the maximum number of documents inserted at once.
+	// Defaults to DefaultBatchSize.
+	BatchSize int
+
+	// FlushInterval is how often a partial batch is inserted.
+	// Defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+
+	// InsertTimeout is the time allowed for each batch insert.
+	// Defaults to DefaultInsertTimeout.
+	InsertTimeout time.Duration
+
+	// FlushOnLevel causes the batch to be inserted immediately when a log
+	// record at this level or more severe is added, so important records are
+	// not delayed waiting for the batch to fill or the flush interval.
+	// The zero value only does this for Panic.
+	FlushOnLevel logr.Level
+}
+
+// Mongo inserts log records into a MongoDB collection. Each document contains
+// `timestamp` (time.Time), `level`, `msg`, `fields` as a sub-document with
+// value types preserved so range queries work, and `stacktrace` when required.
+type Mongo struct {
+	logr.Basic
+	params MongoParams
+	coll   inserter
+
+	mux   sync.Mutex
+	batch []interface{}
+	lgr   *logr.Logr
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewMongoTarget creates a target capable of inserting log records into MongoDB.
+func NewMongoTarget(filter logr.Filter, params *MongoParams, maxQueue int) (*Mongo, error) {
+	if params.Collection == nil {
+		return nil, errors.New("mongo collection is required")
+	}
+	return newMongoTarget(filter, params, params.Collection, maxQueue), nil
+}
+
+func newMongoTarget(filter logr.Filter, params *MongoParams, coll inserter, maxQueue int) *Mongo {
+	m := &Mongo{params: *params, coll: coll, done: make(chan struct{})}
+	if m.params.BatchSize <= 0 {
+		m.params.BatchSize = DefaultBatchSize
+	}
+	if m.params.FlushInterval <= 0 {
+		m.params.FlushInterval = DefaultFlushInterval
+	}
+	if m.params.InsertTimeout <= 0 {
+		m.params.InsertTimeout = DefaultInsertTimeout
+	}
+	m.batch = make([]interface{}, 0, m.params.BatchSize)
+
+	m.Basic.Start(m, m, filter, nil, maxQueue)
+	go m.startFlusher()
+	return m
+}
+
+// Shutdown stops processing log records after making best
+// effort to flush queue, then inserts any remaining batch.
+func (m *Mongo) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+
+	err := m.Basic.Shutdown(ctx)
+	errs.Append(err)
+
+	m.doneOnce.Do(func() { close(m.done) })
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	err = m.insertBatch(ctx)
+	errs.Append(err)
+
+	return errs.ErrorOrNil()
+}
+
+// Write converts the log record to a document and adds it to the batch,
+// inserting the batch when full or when the log record's level is at least
+// as severe as `FlushOnLevel`.
+func (m *Mongo) Write(rec *logr.LogRec) error {
+	_, stacktrace := m.IsLevelEnabled(rec.Level())
+	doc := Document(rec, stacktrace)
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.lgr = rec.Logger().Logr()
+	m.batch = append(m.batch, doc)
+	if len(m.batch) < m.params.BatchSize && rec.Level().ID > m.params.FlushOnLevel.ID {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.params.InsertTimeout)
+	defer cancel()
+	return m.insertBatch(ctx)
+}
+
+// insertBatch inserts the current batch. Must be called with mux held.
+// The batch is discarded even on error so a failing database cannot cause
+// unbounded growth.
+func (m *Mongo) insertBatch(ctx context.Context) error {
+	if len(m.batch) == 0 {
+		return nil
+	}
+	batch := m.batch
+	m.batch = make([]interface{}, 0, m.params.BatchSize)
+
+	if _, err := m.coll.InsertMany(ctx, batch); err != nil {
+		return fmt.Errorf("mongo insert of %d log records fail: %w", len(batch), err)
+	}
+	return nil
+}
+
+// startFlusher periodically inserts partial batches until the target is
+// shut down.
+func (m *Mongo) startFlusher() {
+	ticker := time.NewTicker(m.params.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), m.params.InsertTimeout)
+			m.mux.Lock()
+			err := m.insertBatch(ctx)
+			lgr := m.lgr
+			m.mux.Unlock()
+			cancel()
+
+			if err != nil && lgr != nil {
+				lgr.ReportError(err)
+			}
+		}
+	}
+}
+
+// Document converts a log record to a MongoDB document. Field values keep
+// their types where possible so they are stored as native BSON types;
+// errors are stored as their message, and object or array marshalers as
+// the equivalent nested document or array.
+func Document(rec *logr.LogRec, stacktrace bool) map[string]interface{} {
+	doc := map[string]interface{}{
+		"timestamp": rec.Time(),
+		"level":     rec.Level().Name,
+		"msg":       rec.Msg(),
+	}
+
+	if fields := rec.Fields(); len(fields) > 0 {
+		sub := make(map[string]interface{}, len(fields))
+		for k, v := range fields {
+			sub[k] = fieldValue(v)
+		}
+		doc["fields"] = sub
+	}
+
+	if stacktrace {
+		frames := rec.ErrorStackFrames()
+		if len(frames) == 0 {
+			frames = rec.StackFrames()
+		}
+		if len(frames) > 0 {
+			stack := make([]interface{}, 0, len(frames))
+			for _, f := range frames {
+				stack = append(stack, map[string]interface{}{
+					"function": f.Function,
+					"file":     f.File,
+					"line":     f.Line,
+				})
+			}
+			doc["stacktrace"] = stack
+		}
+	}
+	return doc
+}
+
+// fieldValue converts a field value to a type suitable for BSON encoding.
+func fieldValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case logr.AlwaysValue:
+		return fieldValue(v.Val)
+	case logr.Field:
+		return fieldValue(v.Value())
+	case nil:
+		return nil
+	case error:
+		return v.Error()
+	case logr.Fields:
+		return fieldValue(map[string]interface{}(v))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, mv := range v {
+			m[k] = fieldValue(mv)
+		}
+		return m
+	case gojay.MarshalerJSONObject:
+		return viaJSON(gojay.MarshalJSONObject(v))
+	case gojay.MarshalerJSONArray:
+		return viaJSON(gojay.MarshalJSONArray(v))
+	}
+	return val
+}
+
+// viaJSON decodes JSON produced by a marshaler into maps and slices.
+func viaJSON(data []byte, err error) interface{} {
+	if err != nil {
+		return err.Error()
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return string(data)
+	}
+	return v
+}
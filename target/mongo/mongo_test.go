@@ -0,0 +1,174 @@
+package mongo_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/target/mongo"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fakeCollection records inserted batches.
+type fakeCollection struct {
+	mux     sync.Mutex
+	batches [][]interface{}
+	err     error
+}
+
+func (c *fakeCollection) InsertMany(ctx context.Context, docs []interface{}, opts ...*options.InsertManyOptions) (*mongodriver.InsertManyResult, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.batches = append(c.batches, docs)
+	return &mongodriver.InsertManyResult{}, nil
+}
+
+func (c *fakeCollection) Batches() [][]interface{} {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return append([][]interface{}(nil), c.batches...)
+}
+
+func TestMongoTarget(t *testing.T) {
+	coll := &fakeCollection{}
+	params := &mongo.MongoParams{BatchSize: 2, FlushInterval: time.Hour}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+
+	tgt, err := mongo.NewMongoTargetWithCollection(filter, params, coll, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lgr := &logr.Logr{}
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := lgr.NewLogger().WithFields(logr.Fields{"count": 42, "ratio": 0.5, "err": errors.New("oops")})
+	logger.Info("one")
+	logger.Warn("two")
+	logger.Error("three")
+	logger.Debug("XXX")
+
+	// Shutdown inserts the partial batch.
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	// a second Shutdown, e.g. after RemoveTarget, is a no-op.
+	if err := tgt.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	batches := coll.Batches()
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+
+	doc := batches[0][0].(map[string]interface{})
+	if _, ok := doc["timestamp"].(time.Time); !ok {
+		t.Errorf("timestamp should be time.Time, got %T", doc["timestamp"])
+	}
+	if doc["level"] != "info" || doc["msg"] != "one" {
+		t.Errorf("unexpected document: %v", doc)
+	}
+	fields := doc["fields"].(map[string]interface{})
+	if fields["count"] != 42 || fields["ratio"] != 0.5 || fields["err"] != "oops" {
+		t.Errorf("field types not preserved: %#v", fields)
+	}
+	if _, ok := doc["stacktrace"]; ok {
+		t.Error("stacktrace not expected for info")
+	}
+
+	doc = batches[1][0].(map[string]interface{})
+	if doc["msg"] != "three" {
+		t.Errorf("unexpected document: %v", doc)
+	}
+	if stack, ok := doc["stacktrace"].([]interface{}); !ok || len(stack) == 0 {
+		t.Error("stacktrace expected for error")
+	}
+}
+
+func TestMongoTargetFlushOnLevel(t *testing.T) {
+	coll := &fakeCollection{}
+	params := &mongo.MongoParams{BatchSize: 100, FlushInterval: time.Hour, FlushOnLevel: logr.Error}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+
+	tgt, err := mongo.NewMongoTargetWithCollection(filter, params, coll, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lgr := &logr.Logr{}
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := lgr.NewLogger()
+	logger.Info("one")
+	logger.Warn("two")
+	logger.Error("three")
+	if err := lgr.Flush(); err != nil {
+		t.Error(err)
+	}
+
+	// the error record flushes the batch without waiting for the interval.
+	batches := coll.Batches()
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+	for i, msg := range []string{"one", "two", "three"} {
+		if doc := batches[0][i].(map[string]interface{}); doc["msg"] != msg {
+			t.Errorf("batch order not preserved at %d: %v", i, doc["msg"])
+		}
+	}
+
+	logger.Info("four")
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+	if batches = coll.Batches(); len(batches) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+}
+
+func TestMongoTargetInsertFail(t *testing.T) {
+	coll := &fakeCollection{err: errors.New("not primary")}
+	var mux sync.Mutex
+	var errs []error
+	lgr := &logr.Logr{
+		OnLoggerError: func(err error) {
+			mux.Lock()
+			defer mux.Unlock()
+			errs = append(errs, err)
+		},
+	}
+
+	params := &mongo.MongoParams{BatchSize: 1}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	tgt, err := mongo.NewMongoTargetWithCollection(filter, params, coll, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	lgr.NewLogger().Info("lost")
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "not primary") {
+		t.Errorf("expected insert error, got %v", errs)
+	}
+}
@@ -0,0 +1,21 @@
+module github.com/mattermost/logr/target/nats
+
+go 1.18
+
+require (
+	github.com/mattermost/logr v0.0.0-20261016193954-e78f9ff6dc27
+	github.com/nats-io/nats.go v1.10.0
+	github.com/wiggin77/merror v1.0.2
+)
+
+require (
+	github.com/francoispqt/gojay v1.2.13 // indirect
+	github.com/nats-io/jwt v0.3.2 // indirect
+	github.com/nats-io/nkeys v0.1.4 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	github.com/wiggin77/cfg v1.0.2 // indirect
+	golang.org/x/crypto v0.0.0-20200323165209-0ec3e9974c59 // indirect
+)
+
+// Builds in this repository use the local root module.
+replace github.com/mattermost/logr => ../..
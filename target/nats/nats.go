@@ -0,0 +1,171 @@
+// Package nats provides a target that publishes log records to NATS subjects.
+// It is a separate module so only applications that use it depend on the
+// NATS client.
+package nats
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/logr"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/wiggin77/merror"
+)
+
+const (
+	// DefaultSubject is the subject used when neither `NATSParams.Subject`
+	// nor `NATSParams.SubjectFunc` is provided.
+	DefaultSubject = "logs"
+
+	// DefaultFlushTimeout is the time allowed to flush the NATS connection
+	// during shutdown when the context has no deadline.
+	DefaultFlushTimeout = 5 * time.Second
+)
+
+// NATSParams provides parameters for connecting and publishing to NATS.
+type NATSParams struct {
+	// URL is the NATS server URL, or comma separated list of URLs.
+	// Defaults to `nats://127.0.0.1:4222`.
+	URL string
+
+	// Username and Password are optional user credentials.
+	Username string
+	Password string
+
+	// Token is an optional authentication token.
+	Token string
+
+	// CredentialsFile is an optional path to a user credentials (JWT and nkey)
+	// file.
+	CredentialsFile string
+
+	// Subject is the subject each log record is published to.
+	// Defaults to DefaultSubject. Ignored if SubjectFunc is provided.
+	Subject string
+
+	// SubjectFunc, when not nil, derives the subject from each log record,
+	// e.g. `logs.<level>.<component>`.
+	SubjectFunc func(rec *logr.LogRec) string
+
+	// Options are additional NATS connection options.
+	Options []natsgo.Option
+}
+
+// NATS publishes log records to a NATS subject.
+type NATS struct {
+	logr.Basic
+	params NATSParams
+	conn   *natsgo.Conn
+
+	mux sync.Mutex
+	lgr *logr.Logr
+}
+
+// NewNATSTarget creates a target capable of publishing log records to NATS.
+// The connection reconnects indefinitely; records published while disconnected
+// are buffered by the NATS client up to its reconnect buffer size.
+func NewNATSTarget(filter logr.Filter, formatter logr.Formatter, params *NATSParams, maxQueue int) (*NATS, error) {
+	n := &NATS{params: *params}
+
+	url := params.URL
+	if url == "" {
+		url = natsgo.DefaultURL
+	}
+
+	opts := []natsgo.Option{
+		natsgo.Name("logr"),
+		natsgo.MaxReconnects(-1),
+		natsgo.ErrorHandler(n.onAsyncError),
+	}
+	if params.Username != "" {
+		opts = append(opts, natsgo.UserInfo(params.Username, params.Password))
+	}
+	if params.Token != "" {
+		opts = append(opts, natsgo.Token(params.Token))
+	}
+	if params.CredentialsFile != "" {
+		opts = append(opts, natsgo.UserCredentials(params.CredentialsFile))
+	}
+	opts = append(opts, params.Options...)
+
+	conn, err := natsgo.Connect(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	n.conn = conn
+
+	n.Basic.Start(n, n, filter, formatter, maxQueue)
+	return n, nil
+}
+
+// Shutdown stops processing log records after making best
+// effort to flush queue, then flushes and closes the NATS connection.
+func (n *NATS) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+
+	err := n.Basic.Shutdown(ctx)
+	errs.Append(err)
+
+	timeout := DefaultFlushTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if timeout > 0 {
+		err = n.conn.FlushTimeout(timeout)
+		errs.Append(err)
+	}
+	n.conn.Close()
+
+	return errs.ErrorOrNil()
+}
+
+// Write converts the log record to bytes, via the Formatter,
+// and publishes to NATS.
+func (n *NATS) Write(rec *logr.LogRec) error {
+	n.mux.Lock()
+	n.lgr = rec.Logger().Logr()
+	n.mux.Unlock()
+
+	_, stacktrace := n.IsLevelEnabled(rec.Level())
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf.Write(n.LinePrefix())
+	buf, err := n.FormatRecord(rec, stacktrace, buf)
+	if err != nil {
+		return err
+	}
+	n.EndRecord(buf)
+
+	subject := n.subject(rec)
+	// the NATS client buffers the data so a copy is not needed.
+	if err = n.conn.Publish(subject, buf.Bytes()); err != nil {
+		return fmt.Errorf("nats publish to %s fail: %w", subject, err)
+	}
+	return nil
+}
+
+func (n *NATS) subject(rec *logr.LogRec) string {
+	if n.params.SubjectFunc != nil {
+		return n.params.SubjectFunc(rec)
+	}
+	if n.params.Subject != "" {
+		return n.params.Subject
+	}
+	return DefaultSubject
+}
+
+// onAsyncError reports errors the NATS client encounters asynchronously,
+// e.g. permission violations, to the Logr this target belongs to.
+func (n *NATS) onAsyncError(_ *natsgo.Conn, _ *natsgo.Subscription, err error) {
+	n.mux.Lock()
+	lgr := n.lgr
+	n.mux.Unlock()
+
+	if lgr != nil {
+		lgr.ReportError(fmt.Errorf("nats error: %w", err))
+	}
+}
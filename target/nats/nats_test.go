@@ -0,0 +1,153 @@
+package nats_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target/nats"
+)
+
+type pubMsg struct {
+	subject string
+	data    string
+}
+
+// fakeServer implements just enough of the NATS protocol to accept
+// connections and record published messages.
+type fakeServer struct {
+	ln   net.Listener
+	mux  sync.Mutex
+	msgs []pubMsg
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) URL() string {
+	return "nats://" + s.ln.Addr().String()
+}
+
+func (s *fakeServer) Msgs() []pubMsg {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]pubMsg(nil), s.msgs...)
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"version\":\"2.1.0\",\"max_payload\":1048576}\r\n")
+
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case "PUB":
+			size, err := strconv.Atoi(args[len(args)-1])
+			if err != nil {
+				return
+			}
+			payload := make([]byte, size+2) // includes trailing CRLF
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			s.mux.Lock()
+			s.msgs = append(s.msgs, pubMsg{subject: args[1], data: string(payload[:size])})
+			s.mux.Unlock()
+		}
+	}
+}
+
+func TestNATSTarget(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.ln.Close()
+
+	params := &nats.NATSParams{
+		URL: server.URL(),
+		SubjectFunc: func(rec *logr.LogRec) string {
+			return fmt.Sprintf("logs.%s.%v", rec.Level().Name, rec.Fields()["component"])
+		},
+	}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	tgt, err := nats.NewNATSTarget(filter, formatter, params, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lgr := &logr.Logr{}
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := lgr.NewLogger().WithField("component", "api")
+	logger.Info("first")
+	logger.Debug("XXX")
+	logger.Error("second")
+
+	// Shutdown flushes the NATS connection so all messages have been received.
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	msgs := server.Msgs()
+	want := []pubMsg{
+		{subject: "logs.info.api", data: "info | first | component=api\n"},
+		{subject: "logs.error.api", data: "error | second | component=api\n"},
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("expected %d messages, got %d: %v", len(want), len(msgs), msgs)
+	}
+	for i := range want {
+		if msgs[i] != want[i] {
+			t.Errorf("expected %v, got %v", want[i], msgs[i])
+		}
+	}
+}
+
+func TestNATSTargetConnectFail(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "nats://" + ln.Addr().String()
+	ln.Close()
+
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	_, err = nats.NewNATSTarget(filter, &format.Plain{}, &nats.NATSParams{URL: url}, 1000)
+	if err == nil {
+		t.Error("expected connect error")
+	}
+}
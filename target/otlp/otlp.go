@@ -0,0 +1,472 @@
+// Package otlp provides a target that exports log records to an OpenTelemetry
+// collector as OTel log records, using the OpenTelemetry Go log SDK and its
+// OTLP exporters over HTTP or gRPC. It is a separate module so only
+// applications that use it depend on the OpenTelemetry SDK. Log records are
+// batched by the SDK's batch processor and exported when a batch is full, on a
+// periodic interval, and at shutdown.
+//
+// Each log record maps to an OTel log record as follows:
+//   - severity number and text from the level,
+//   - body from the message,
+//   - attributes from the fields,
+//   - trace and span id from the fields keyed by `TraceIDKey` and `SpanIDKey`.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/francoispqt/gojay"
+	"github.com/mattermost/logr"
+	"github.com/wiggin77/merror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultHTTPEndpoint is the OTLP/HTTP logs endpoint of a local collector.
+	DefaultHTTPEndpoint = "http://localhost:4318/v1/logs"
+
+	// DefaultGRPCEndpoint is the OTLP/gRPC endpoint of a local collector.
+	DefaultGRPCEndpoint = "http://localhost:4317"
+
+	// DefaultBatchSize is the maximum number of log records exported at once.
+	DefaultBatchSize = 512
+
+	// DefaultFlushInterval is how often a partial batch is exported.
+	DefaultFlushInterval = time.Second
+
+	// DefaultTimeout is the time allowed for each export request.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultTraceIDKey is the field key holding a hex encoded trace id.
+	DefaultTraceIDKey = "trace_id"
+
+	// DefaultSpanIDKey is the field key holding a hex encoded span id.
+	DefaultSpanIDKey = "span_id"
+
+	// scopeName identifies logr as the instrumentation scope.
+	scopeName = "github.com/mattermost/logr"
+)
+
+// Protocol is the OTLP transport used to export log records.
+type Protocol int
+
+const (
+	// ProtocolHTTP exports via OTLP/HTTP with protobuf encoding.
+	ProtocolHTTP Protocol = iota
+	// ProtocolGRPC exports via OTLP/gRPC.
+	ProtocolGRPC
+)
+
+// OTLPParams provides parameters for exporting log records to an
+// OpenTelemetry collector.
+type OTLPParams struct {
+	// Protocol is the OTLP transport. Defaults to ProtocolHTTP.
+	Protocol Protocol
+
+	// Endpoint is the URL of the collector; an `http` scheme disables TLS.
+	// For ProtocolHTTP this is the full logs endpoint URL. Defaults to
+	// DefaultHTTPEndpoint or DefaultGRPCEndpoint.
+	Endpoint string
+
+	// Headers are added to each export request, e.g. for authentication.
+	Headers map[string]string
+
+	// ServiceName is reported as the `service.name` resource attribute.
+	ServiceName string
+
+	// Resource contains additional resource attributes.
+	Resource map[string]interface{}
+
+	// TraceIDKey is the field key holding a hex encoded trace id.
+	// Defaults to DefaultTraceIDKey.
+	TraceIDKey string
+
+	// SpanIDKey is the field key holding a hex encoded span id.
+	// Defaults to DefaultSpanIDKey.
+	SpanIDKey string
+
+	// BatchSize is the maximum number of log records exported at once.
+	// Defaults to DefaultBatchSize.
+	BatchSize int
+
+	// FlushInterval is how often a partial batch is exported.
+	// Defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+
+	// Timeout is the time allowed for each export request.
+	// Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// Exporter, when not nil, is used instead of creating an OTLP exporter
+	// from Protocol, Endpoint and Headers, e.g. to configure TLS or retries.
+	Exporter sdklog.Exporter
+}
+
+// OTLP exports log records to an OpenTelemetry collector.
+type OTLP struct {
+	logr.Basic
+	params   OTLPParams
+	provider *sdklog.LoggerProvider
+	logger   otellog.Logger
+
+	mux sync.Mutex
+	lgr *logr.Logr
+}
+
+// NewOTLPTarget creates a target capable of exporting log records to an
+// OpenTelemetry collector.
+func NewOTLPTarget(filter logr.Filter, params *OTLPParams, maxQueue int) (*OTLP, error) {
+	o := &OTLP{params: *params}
+	if o.params.TraceIDKey == "" {
+		o.params.TraceIDKey = DefaultTraceIDKey
+	}
+	if o.params.SpanIDKey == "" {
+		o.params.SpanIDKey = DefaultSpanIDKey
+	}
+	if o.params.BatchSize <= 0 {
+		o.params.BatchSize = DefaultBatchSize
+	}
+	if o.params.FlushInterval <= 0 {
+		o.params.FlushInterval = DefaultFlushInterval
+	}
+	if o.params.Timeout <= 0 {
+		o.params.Timeout = DefaultTimeout
+	}
+
+	exporter := o.params.Exporter
+	if exporter == nil {
+		var err error
+		if exporter, err = o.newExporter(); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := o.newResource()
+	if err != nil {
+		return nil, err
+	}
+
+	processor := sdklog.NewBatchProcessor(&reportingExporter{Exporter: exporter, target: o},
+		sdklog.WithExportMaxBatchSize(o.params.BatchSize),
+		sdklog.WithExportInterval(o.params.FlushInterval),
+		sdklog.WithExportTimeout(o.params.Timeout),
+	)
+	o.provider = sdklog.NewLoggerProvider(sdklog.WithResource(res), sdklog.WithProcessor(processor))
+	o.logger = o.provider.Logger(scopeName)
+
+	o.Basic.Start(o, o, filter, nil, maxQueue)
+	return o, nil
+}
+
+// newExporter creates the OTLP exporter for the configured protocol.
+func (o *OTLP) newExporter() (sdklog.Exporter, error) {
+	ctx := context.Background()
+	switch o.params.Protocol {
+	case ProtocolGRPC:
+		endpoint := o.params.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultGRPCEndpoint
+		}
+		opts := []otlploggrpc.Option{
+			otlploggrpc.WithEndpointURL(endpoint),
+			otlploggrpc.WithTimeout(o.params.Timeout),
+		}
+		if len(o.params.Headers) > 0 {
+			opts = append(opts, otlploggrpc.WithHeaders(o.params.Headers))
+		}
+		return otlploggrpc.New(ctx, opts...)
+	default:
+		endpoint := o.params.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultHTTPEndpoint
+		}
+		opts := []otlploghttp.Option{
+			otlploghttp.WithEndpointURL(endpoint),
+			otlploghttp.WithTimeout(o.params.Timeout),
+		}
+		if len(o.params.Headers) > 0 {
+			opts = append(opts, otlploghttp.WithHeaders(o.params.Headers))
+		}
+		return otlploghttp.New(ctx, opts...)
+	}
+}
+
+// newResource merges the SDK's default resource with the service name and
+// resource attributes.
+func (o *OTLP) newResource() (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	if o.params.ServiceName != "" {
+		attrs = append(attrs, attribute.String("service.name", o.params.ServiceName))
+	}
+	keys := make([]string, 0, len(o.params.Resource))
+	for k := range o.params.Resource {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		attrs = append(attrs, resourceAttr(k, o.params.Resource[k]))
+	}
+	return resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+}
+
+// Shutdown stops processing log records after making best
+// effort to flush queue, then exports any remaining batch.
+func (o *OTLP) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+
+	err := o.Basic.Shutdown(ctx)
+	errs.Append(err)
+
+	// the provider ignores calls after the first.
+	err = o.provider.Shutdown(ctx)
+	errs.Append(err)
+
+	return errs.ErrorOrNil()
+}
+
+// Write converts the log record to an OTel log record and emits it to the
+// batch processor.
+func (o *OTLP) Write(rec *logr.LogRec) error {
+	_, stacktrace := o.IsLevelEnabled(rec.Level())
+
+	o.mux.Lock()
+	o.lgr = rec.Logger().Logr()
+	o.mux.Unlock()
+
+	ctx, r := o.logRecord(rec, stacktrace)
+	o.logger.Emit(ctx, r)
+	return nil
+}
+
+// reportError reports export errors to the Logr, since the batch processor
+// exports in the background.
+func (o *OTLP) reportError(err error) {
+	o.mux.Lock()
+	lgr := o.lgr
+	o.mux.Unlock()
+
+	if lgr != nil {
+		lgr.ReportError(err)
+	}
+}
+
+// reportingExporter reports export errors to the target's Logr.
+type reportingExporter struct {
+	sdklog.Exporter
+	target *OTLP
+}
+
+func (e *reportingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	err := e.Exporter.Export(ctx, records)
+	if err != nil {
+		e.target.reportError(fmt.Errorf("otlp export of %d log records failed: %w", len(records), err))
+	}
+	return err
+}
+
+// logRecord converts a log record to an OTel log record. The returned context
+// carries the trace and span id, if any, which the SDK adds to the record.
+func (o *OTLP) logRecord(rec *logr.LogRec, stacktrace bool) (context.Context, otellog.Record) {
+	var r otellog.Record
+	r.SetTimestamp(rec.Time())
+	r.SetObservedTimestamp(time.Now())
+	r.SetSeverity(Severity(rec.Level()))
+	r.SetSeverityText(rec.Level().Name)
+	r.SetBody(otellog.StringValue(rec.Msg()))
+
+	ctx := context.Background()
+	fields := rec.Fields()
+	var skip map[string]struct{}
+	var sc trace.SpanContextConfig
+	if id, ok := hexID(fields[o.params.TraceIDKey], 16); ok {
+		copy(sc.TraceID[:], id)
+		skip = map[string]struct{}{o.params.TraceIDKey: {}}
+	}
+	if id, ok := hexID(fields[o.params.SpanIDKey], 8); ok {
+		copy(sc.SpanID[:], id)
+		if skip == nil {
+			skip = map[string]struct{}{}
+		}
+		skip[o.params.SpanIDKey] = struct{}{}
+	}
+	if skip != nil {
+		ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(sc))
+	}
+	r.AddAttributes(attributes(fields, skip)...)
+
+	if stacktrace {
+		frames := rec.ErrorStackFrames()
+		if len(frames) == 0 {
+			frames = rec.StackFrames()
+		}
+		if len(frames) > 0 {
+			var buf bytes.Buffer
+			logr.WriteStacktrace(&buf, frames)
+			r.AddAttributes(otellog.String("exception.stacktrace", buf.String()))
+		}
+	}
+	return ctx, r
+}
+
+// Severity returns the OTel severity number for a level. Custom levels map to
+// SeverityUndefined; their name is still reported as the severity text.
+func Severity(lvl logr.Level) otellog.Severity {
+	switch lvl.ID {
+	case logr.Trace.ID:
+		return otellog.SeverityTrace
+	case logr.Debug.ID:
+		return otellog.SeverityDebug
+	case logr.Info.ID:
+		return otellog.SeverityInfo
+	case logr.Warn.ID:
+		return otellog.SeverityWarn
+	case logr.Error.ID:
+		return otellog.SeverityError
+	case logr.Fatal.ID:
+		return otellog.SeverityFatal
+	case logr.Panic.ID:
+		return otellog.SeverityFatal4
+	}
+	return otellog.SeverityUndefined
+}
+
+// hexID returns the bytes of val if it is a hex string, or []byte, of the
+// expected number of bytes.
+func hexID(val interface{}, size int) ([]byte, bool) {
+	switch v := val.(type) {
+	case logr.Field:
+		return hexID(v.Value(), size)
+	case string:
+		if b, err := hex.DecodeString(v); err == nil && len(b) == size {
+			return b, true
+		}
+	case []byte:
+		if len(v) == size {
+			return v, true
+		}
+	case fmt.Stringer:
+		return hexID(v.String(), size)
+	}
+	return nil, false
+}
+
+// attributes converts fields to OTel attributes, sorted by key, omitting
+// any keys in skip.
+func attributes(fields map[string]interface{}, skip map[string]struct{}) []otellog.KeyValue {
+	if len(fields) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if _, ok := skip[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otellog.KeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otellog.KeyValue{Key: k, Value: attrValue(fields[k])})
+	}
+	return kvs
+}
+
+// attrValue converts a field value to an OTel log value.
+func attrValue(val interface{}) otellog.Value {
+	switch v := val.(type) {
+	case logr.AlwaysValue:
+		return attrValue(v.Val)
+	case logr.Field:
+		return attrValue(v.Value())
+	case nil:
+		return otellog.Value{}
+	case string:
+		return otellog.StringValue(v)
+	case bool:
+		return otellog.BoolValue(v)
+	case int:
+		return otellog.IntValue(v)
+	case int8:
+		return otellog.Int64Value(int64(v))
+	case int16:
+		return otellog.Int64Value(int64(v))
+	case int32:
+		return otellog.Int64Value(int64(v))
+	case int64:
+		return otellog.Int64Value(v)
+	case uint8:
+		return otellog.Int64Value(int64(v))
+	case uint16:
+		return otellog.Int64Value(int64(v))
+	case uint32:
+		return otellog.Int64Value(int64(v))
+	case float32:
+		return otellog.Float64Value(float64(v))
+	case float64:
+		return otellog.Float64Value(v)
+	case []byte:
+		return otellog.BytesValue(v)
+	case error:
+		return otellog.StringValue(v.Error())
+	case time.Time:
+		return otellog.StringValue(v.Format(time.RFC3339Nano))
+	case logr.Fields:
+		return attrValue(map[string]interface{}(v))
+	case map[string]interface{}:
+		return otellog.MapValue(attributes(v, nil)...)
+	case []interface{}:
+		values := make([]otellog.Value, 0, len(v))
+		for _, av := range v {
+			values = append(values, attrValue(av))
+		}
+		return otellog.SliceValue(values...)
+	case gojay.MarshalerJSONObject:
+		return viaJSON(gojay.MarshalJSONObject(v))
+	case gojay.MarshalerJSONArray:
+		return viaJSON(gojay.MarshalJSONArray(v))
+	}
+	return otellog.StringValue(fmt.Sprint(val))
+}
+
+// resourceAttr converts a resource value to a resource attribute.
+func resourceAttr(key string, val interface{}) attribute.KeyValue {
+	switch v := val.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	}
+	return attribute.String(key, fmt.Sprint(val))
+}
+
+// viaJSON decodes JSON produced by a marshaler into maps and slices, then
+// converts the result to an OTel log value.
+func viaJSON(data []byte, err error) otellog.Value {
+	if err != nil {
+		return otellog.StringValue(err.Error())
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return otellog.StringValue(string(data))
+	}
+	return attrValue(v)
+}
@@ -0,0 +1,259 @@
+package otlp_test
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/target/otlp"
+	otellog "go.opentelemetry.io/otel/log"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector records OTLP export requests received over HTTP or gRPC.
+type collector struct {
+	collogspb.UnimplementedLogsServiceServer
+
+	mux      sync.Mutex
+	requests []*collogspb.ExportLogsServiceRequest
+	auth     []string
+}
+
+func (c *collector) add(req *collogspb.ExportLogsServiceRequest, auth string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.requests = append(c.requests, req)
+	c.auth = append(c.auth, auth)
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &collogspb.ExportLogsServiceRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.add(req, r.Header.Get("Authorization"))
+
+	resp, _ := proto.Marshal(&collogspb.ExportLogsServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(resp)
+}
+
+func (c *collector) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	var auth string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		auth = md.Get("authorization")[0]
+	}
+	c.add(req, auth)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (c *collector) logRecords() []*logspb.LogRecord {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var recs []*logspb.LogRecord
+	for _, req := range c.requests {
+		for _, rl := range req.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				recs = append(recs, sl.LogRecords...)
+			}
+		}
+	}
+	return recs
+}
+
+func attrs(kvs []*commonpb.KeyValue) map[string]*commonpb.AnyValue {
+	m := map[string]*commonpb.AnyValue{}
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func TestOTLPTarget(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	params := &otlp.OTLPParams{
+		Endpoint:      server.URL + "/v1/logs",
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		ServiceName:   "checkout",
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Error}
+	tgt, err := otlp.NewOTLPTarget(filter, params, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lgr := &logr.Logr{}
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := lgr.NewLogger().WithFields(logr.Fields{
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":  "00f067aa0ba902b7",
+		"count":    42,
+		"ok":       true,
+	})
+	logger.Info("one")
+	logger.Warn("two")
+	logger.Error("three")
+	logger.Debug("XXX")
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	// a second Shutdown, e.g. after RemoveTarget, is a no-op.
+	if err := tgt.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	if len(c.requests) == 0 {
+		t.Fatal("expected export requests")
+	}
+	if c.auth[0] != "Bearer token" {
+		t.Errorf("missing header, got %q", c.auth[0])
+	}
+
+	resAttrs := attrs(c.requests[0].ResourceLogs[0].Resource.Attributes)
+	if svc := resAttrs["service.name"].GetStringValue(); svc != "checkout" {
+		t.Errorf("unexpected service.name %v", svc)
+	}
+
+	recs := c.logRecords()
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 log records, got %d", len(recs))
+	}
+
+	lr := recs[0]
+	if lr.SeverityNumber != logspb.SeverityNumber(otellog.SeverityInfo) || lr.SeverityText != "info" {
+		t.Errorf("unexpected severity: %v %v", lr.SeverityNumber, lr.SeverityText)
+	}
+	if body := lr.Body.GetStringValue(); body != "one" {
+		t.Errorf("unexpected body %v", body)
+	}
+	if hex.EncodeToString(lr.TraceId) != "4bf92f3577b34da6a3ce929d0e0e4736" || hex.EncodeToString(lr.SpanId) != "00f067aa0ba902b7" {
+		t.Errorf("unexpected trace context: %x %x", lr.TraceId, lr.SpanId)
+	}
+	a := attrs(lr.Attributes)
+	if _, ok := a["trace_id"]; ok {
+		t.Error("trace_id should not be an attribute")
+	}
+	if v := a["count"].GetIntValue(); v != 42 {
+		t.Errorf("unexpected count attribute %v", v)
+	}
+	if v := a["ok"].GetBoolValue(); v != true {
+		t.Errorf("unexpected ok attribute %v", v)
+	}
+	if _, ok := a["exception.stacktrace"]; ok {
+		t.Error("stacktrace not expected for info")
+	}
+
+	if _, ok := attrs(recs[2].Attributes)["exception.stacktrace"]; !ok {
+		t.Error("stacktrace expected for error")
+	}
+	if recs[2].SeverityNumber != logspb.SeverityNumber(otellog.SeverityError) {
+		t.Errorf("unexpected severity %v", recs[2].SeverityNumber)
+	}
+}
+
+func TestOTLPTargetGRPC(t *testing.T) {
+	c := &collector{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(server, c)
+	go server.Serve(l)
+	defer server.Stop()
+
+	params := &otlp.OTLPParams{
+		Protocol:      otlp.ProtocolGRPC,
+		Endpoint:      "http://" + l.Addr().String(),
+		Headers:       map[string]string{"Authorization": "Bearer token"},
+		FlushInterval: time.Hour,
+	}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	tgt, err := otlp.NewOTLPTarget(filter, params, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lgr := &logr.Logr{}
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	lgr.NewLogger().Warn("over grpc")
+
+	// Shutdown exports the partial batch.
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	recs := c.logRecords()
+	if len(recs) != 1 || recs[0].Body.GetStringValue() != "over grpc" {
+		t.Fatalf("unexpected log records: %v", recs)
+	}
+	if c.auth[0] != "Bearer token" {
+		t.Errorf("missing header, got %q", c.auth[0])
+	}
+}
+
+func TestOTLPTargetExportFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// not retryable, so the export fails at once.
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	params := &otlp.OTLPParams{Endpoint: server.URL, BatchSize: 1}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	tgt, err := otlp.NewOTLPTarget(filter, params, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mux sync.Mutex
+	var errs []error
+	lgr := &logr.Logr{OnLoggerError: func(err error) {
+		mux.Lock()
+		defer mux.Unlock()
+		errs = append(errs, err)
+	}}
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+	lgr.NewLogger().Info("lost")
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(errs) == 0 {
+		t.Error("expected export failure to be reported")
+	}
+}
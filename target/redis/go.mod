@@ -0,0 +1,19 @@
+module github.com/mattermost/logr/target/redis
+
+go 1.18
+
+require (
+	github.com/mattermost/logr v0.0.0-20261016193954-e78f9ff6dc27
+	github.com/redis/go-redis/v9 v9.7.3
+	github.com/wiggin77/merror v1.0.2
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/francoispqt/gojay v1.2.13 // indirect
+	github.com/wiggin77/cfg v1.0.2 // indirect
+)
+
+// Builds in this repository use the local root module.
+replace github.com/mattermost/logr => ../..
@@ -0,0 +1,264 @@
+// Package redis provides a target that outputs log records to Redis, either by
+// appending to a list or by publishing to a channel, using the go-redis client.
+// It is a separate module so only applications that use it depend on the
+// Redis client.
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/logr"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/wiggin77/merror"
+)
+
+const (
+	// DefaultAddr is the Redis server address used when none is provided.
+	DefaultAddr = "localhost:6379"
+
+	// DefaultKey is the list key or channel used when none is provided.
+	DefaultKey = "logs"
+
+	// DefaultDialTimeout is the time allowed to connect to Redis.
+	DefaultDialTimeout = 5 * time.Second
+
+	// DefaultFlushInterval is how often batched log records are sent to Redis.
+	DefaultFlushInterval = 100 * time.Millisecond
+
+	// DefaultBatchSize is the number of log records batched before they are
+	// sent to Redis without waiting for the flush interval.
+	DefaultBatchSize = 1000
+)
+
+// Mode determines how log records are output to Redis.
+type Mode int
+
+const (
+	// ModeList appends each log record to a list via RPUSH, for a pull model.
+	// The list is optionally capped via LTRIM; see `RedisParams.MaxLen`.
+	ModeList Mode = iota
+	// ModePublish publishes each log record to a channel via PUBLISH, for live tailing.
+	ModePublish
+)
+
+// RedisParams provides parameters for connecting and writing to Redis.
+type RedisParams struct {
+	// Addr is the host:port of the Redis server. Defaults to DefaultAddr.
+	Addr string
+
+	// Password is an optional password used to authenticate.
+	Password string
+
+	// DB is the database number selected on connect.
+	DB int
+
+	// Key is the list key (ModeList) or channel (ModePublish).
+	// Defaults to DefaultKey.
+	Key string
+
+	// Mode determines whether records are pushed to a list or published.
+	Mode Mode
+
+	// MaxLen, when greater than zero in ModeList, trims the list to the
+	// newest MaxLen entries after each push.
+	MaxLen int64
+
+	// DialTimeout is the time allowed to connect. Defaults to DefaultDialTimeout.
+	DialTimeout time.Duration
+
+	// FlushInterval is how often batched log records are sent to Redis.
+	// Defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+
+	// BatchSize is the number of log records batched before they are sent
+	// without waiting for FlushInterval. Defaults to DefaultBatchSize.
+	BatchSize int
+
+	// Options, when not nil, are used to create the client, e.g. for TLS or
+	// Redis Sentinel, in which case Addr, Password, DB and DialTimeout are
+	// ignored.
+	Options *goredis.Options
+}
+
+// Redis outputs log records to a Redis list or channel. Log records are
+// batched and sent as a single pipeline every `FlushInterval`, or sooner when
+// `BatchSize` log records are waiting. Failed connections are re-established
+// by the client on the next send.
+type Redis struct {
+	logr.Basic
+	params RedisParams
+	client *goredis.Client
+
+	mux   sync.Mutex
+	batch [][]byte
+	lgr   *logr.Logr
+
+	// flushMux serializes sends so batches arrive in order.
+	flushMux sync.Mutex
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewRedisTarget creates a target capable of outputting log records to Redis.
+// An error is returned if Redis cannot be reached.
+func NewRedisTarget(filter logr.Filter, formatter logr.Formatter, params *RedisParams, maxQueue int) (*Redis, error) {
+	r := &Redis{params: *params, done: make(chan struct{})}
+	if r.params.Key == "" {
+		r.params.Key = DefaultKey
+	}
+	if r.params.DialTimeout == 0 {
+		r.params.DialTimeout = DefaultDialTimeout
+	}
+	if r.params.FlushInterval == 0 {
+		r.params.FlushInterval = DefaultFlushInterval
+	}
+	if r.params.BatchSize <= 0 {
+		r.params.BatchSize = DefaultBatchSize
+	}
+
+	opts := r.params.Options
+	if opts == nil {
+		addr := r.params.Addr
+		if addr == "" {
+			addr = DefaultAddr
+		}
+		opts = &goredis.Options{
+			Addr:        addr,
+			Password:    r.params.Password,
+			DB:          r.params.DB,
+			DialTimeout: r.params.DialTimeout,
+		}
+	}
+	r.client = goredis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.params.DialTimeout)
+	defer cancel()
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		r.client.Close()
+		return nil, fmt.Errorf("redis connect to %s fail: %w", opts.Addr, err)
+	}
+
+	r.Basic.Start(r, r, filter, formatter, maxQueue)
+	go r.startFlusher()
+	return r, nil
+}
+
+// Shutdown stops processing log records after making best effort to flush
+// queue, then sends any batched log records before closing the client.
+func (r *Redis) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+
+	err := r.Basic.Shutdown(ctx)
+	errs.Append(err)
+
+	r.doneOnce.Do(func() { close(r.done) })
+
+	if err = r.flush(ctx); err != nil {
+		r.reportError(err)
+	}
+
+	if err = r.client.Close(); err != nil && err != goredis.ErrClosed {
+		errs.Append(err)
+	}
+	return errs.ErrorOrNil()
+}
+
+// Write converts the log record to bytes, via the Formatter, and adds it to
+// the batch, sending the batch if full.
+func (r *Redis) Write(rec *logr.LogRec) error {
+	_, stacktrace := r.IsLevelEnabled(rec.Level())
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf.Write(r.LinePrefix())
+	buf, err := r.FormatRecord(rec, stacktrace, buf)
+	if err != nil {
+		return err
+	}
+	r.EndRecord(buf)
+
+	r.mux.Lock()
+	r.lgr = rec.Logger().Logr()
+	r.batch = append(r.batch, append([]byte(nil), buf.Bytes()...))
+	full := len(r.batch) >= r.params.BatchSize
+	r.mux.Unlock()
+
+	if !full {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.params.FlushInterval+r.params.DialTimeout)
+	defer cancel()
+	return r.flush(ctx)
+}
+
+// flush sends the batched log records as a single pipeline. The batch is
+// discarded even on error so a failing server cannot cause unbounded growth.
+func (r *Redis) flush(ctx context.Context) error {
+	r.flushMux.Lock()
+	defer r.flushMux.Unlock()
+
+	r.mux.Lock()
+	batch := r.batch
+	r.batch = nil
+	r.mux.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	switch r.params.Mode {
+	case ModePublish:
+		for _, data := range batch {
+			pipe.Publish(ctx, r.params.Key, data)
+		}
+	default:
+		vals := make([]interface{}, len(batch))
+		for i, data := range batch {
+			vals[i] = data
+		}
+		pipe.RPush(ctx, r.params.Key, vals...)
+		if r.params.MaxLen > 0 {
+			pipe.LTrim(ctx, r.params.Key, -r.params.MaxLen, -1)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis write of %d log records fail: %w", len(batch), err)
+	}
+	return nil
+}
+
+// startFlusher periodically sends batched log records until the target
+// is shut down.
+func (r *Redis) startFlusher() {
+	ticker := time.NewTicker(r.params.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), r.params.FlushInterval+r.params.DialTimeout)
+			err := r.flush(ctx)
+			cancel()
+			if err != nil {
+				r.reportError(err)
+			}
+		}
+	}
+}
+
+func (r *Redis) reportError(err error) {
+	r.mux.Lock()
+	lgr := r.lgr
+	r.mux.Unlock()
+
+	if lgr != nil {
+		lgr.ReportError(err)
+	}
+}
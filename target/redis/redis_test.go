@@ -0,0 +1,241 @@
+package redis_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target/redis"
+)
+
+// fakeServer implements just enough of the Redis protocol to record
+// commands and reply to them. Connection handshake commands sent by the
+// client are answered but not recorded.
+type fakeServer struct {
+	ln   net.Listener
+	mux  sync.Mutex
+	cmds []string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) Cmds() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]string(nil), s.cmds...)
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		args[0] = strings.ToUpper(args[0])
+		switch args[0] {
+		case "HELLO":
+			// reply as a server without RESP3 so the client uses RESP2.
+			fmt.Fprint(conn, "-ERR unknown command 'HELLO'\r\n")
+			continue
+		case "CLIENT", "PING":
+			fmt.Fprint(conn, "+OK\r\n")
+			continue
+		}
+
+		s.mux.Lock()
+		s.cmds = append(s.cmds, strings.Join(args, " "))
+		s.mux.Unlock()
+
+		switch args[0] {
+		case "RPUSH", "PUBLISH":
+			if args[1] == "bad" {
+				fmt.Fprint(conn, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+				continue
+			}
+			fmt.Fprint(conn, ":1\r\n")
+		default:
+			fmt.Fprint(conn, "+OK\r\n")
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisTarget(t *testing.T) {
+	tests := []struct {
+		name   string
+		params redis.RedisParams
+		want   []string
+	}{
+		{
+			name:   "list",
+			params: redis.RedisParams{Key: "applogs", Password: "secret", DB: 2, MaxLen: 100},
+			want: []string{
+				"AUTH secret",
+				"SELECT 2",
+				"RPUSH applogs info | first | \n error | second | \n",
+				"LTRIM applogs -100 -1",
+			},
+		},
+		{
+			name:   "publish",
+			params: redis.RedisParams{Key: "tail", Mode: redis.ModePublish},
+			want: []string{
+				"PUBLISH tail info | first | \n",
+				"PUBLISH tail error | second | \n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer(t)
+			defer server.ln.Close()
+
+			params := tt.params
+			params.Addr = server.ln.Addr().String()
+			// log records are sent as one batch on shutdown.
+			params.FlushInterval = time.Hour
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+			tgt, err := redis.NewRedisTarget(filter, formatter, &params, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lgr := &logr.Logr{}
+			if err := lgr.AddTarget(tgt); err != nil {
+				t.Fatal(err)
+			}
+
+			logger := lgr.NewLogger()
+			logger.Info("first")
+			logger.Debug("XXX")
+			logger.Error("second")
+
+			if err := lgr.Shutdown(); err != nil {
+				t.Error(err)
+			}
+
+			cmds := server.Cmds()
+			if len(cmds) != len(tt.want) {
+				t.Fatalf("expected %d commands, got %d: %q", len(tt.want), len(cmds), cmds)
+			}
+			for i := range tt.want {
+				if cmds[i] != tt.want[i] {
+					t.Errorf("expected %q, got %q", tt.want[i], cmds[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRedisTargetErrorReply(t *testing.T) {
+	server := newFakeServer(t)
+	defer server.ln.Close()
+
+	var mux sync.Mutex
+	var errs []error
+	lgr := &logr.Logr{
+		OnLoggerError: func(err error) {
+			mux.Lock()
+			defer mux.Unlock()
+			errs = append(errs, err)
+		},
+	}
+
+	params := &redis.RedisParams{Addr: server.ln.Addr().String(), Key: "bad"}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	tgt, err := redis.NewRedisTarget(filter, &format.Plain{}, params, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	lgr.NewLogger().Info("rejected")
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	// a second Shutdown, e.g. after RemoveTarget, is a no-op.
+	if err := tgt.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "WRONGTYPE") {
+		t.Errorf("expected WRONGTYPE error, got %v", errs)
+	}
+}
+
+func TestRedisTargetConnectFail(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	_, err = redis.NewRedisTarget(filter, &format.Plain{}, &redis.RedisParams{Addr: addr}, 1000)
+	if err == nil {
+		t.Error("expected connect error")
+	}
+}
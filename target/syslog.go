@@ -60,10 +60,12 @@ func (s *Syslog) Write(rec *logr.LogRec) error {
 	buf := rec.Logger().Logr().BorrowBuffer()
 	defer rec.Logger().Logr().ReleaseBuffer(buf)
 
-	buf, err := s.Formatter().Format(rec, stacktrace, buf)
+	buf.Write(s.LinePrefix())
+	buf, err := s.FormatRecord(rec, stacktrace, buf)
 	if err != nil {
 		return err
 	}
+	s.EndRecord(buf)
 	txt := buf.String()
 
 	switch rec.Level() {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/syslog"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,6 +46,12 @@ func TestSyslogPlain(t *testing.T) {
 }
 
 func syslogger(t *testing.T, formatter logr.Formatter) {
+	server, err := test.NewSyslogServer("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
 	lgr := &logr.Logr{}
 
 	lgr.OnLoggerError = func(err error) {
@@ -52,12 +59,14 @@ func syslogger(t *testing.T, formatter logr.Formatter) {
 	}
 
 	filter := &logr.StdFilter{Lvl: logr.Warn, Stacktrace: logr.Panic}
-	params := &target.SyslogParams{Network: "", Raddr: "", Priority: syslog.LOG_WARNING | syslog.LOG_DAEMON, Tag: "logrtest"}
+	params := &target.SyslogParams{Network: server.Network(), Raddr: server.Addr(), Priority: syslog.LOG_WARNING | syslog.LOG_DAEMON, Tag: "logrtest"}
 	target, err := target.NewSyslogTarget(filter, formatter, params, 1000)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	if err = lgr.AddTarget(target); err != nil {
+		t.Fatal(err)
 	}
-	_ = lgr.AddTarget(target)
 
 	cfg := test.DoSomeLoggingCfg{
 		Lgr:        lgr,
@@ -68,9 +77,101 @@ func syslogger(t *testing.T, formatter logr.Formatter) {
 		Lvl:        logr.Warn,
 		Delay:      time.Millisecond * 1,
 	}
-	test.DoSomeLogging(cfg)
+	logged, _ := test.DoSomeLogging(cfg)
+	if err = lgr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := server.WaitForMessages(int(logged), time.Second*5)
+	if err != nil {
+		t.Fatal(err)
+	}
 	err = lgr.Shutdown()
 	if err != nil {
 		t.Error(err)
 	}
+
+	if len(msgs) != int(logged) {
+		t.Errorf("expected %d messages; got %d", logged, len(msgs))
+	}
+	for _, m := range msgs {
+		if !strings.Contains(m.Msg, cfg.GoodToken) || strings.Contains(m.Msg, cfg.BadToken) {
+			t.Errorf("unexpected message %q", m.Msg)
+		}
+	}
+}
+
+func TestSyslogDelivery(t *testing.T) {
+	for _, network := range []string{"udp", "tcp"} {
+		t.Run(network, func(t *testing.T) {
+			server, err := test.NewSyslogServer(network)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			lgr := &logr.Logr{}
+			lgr.OnLoggerError = func(err error) {
+				t.Error(err)
+			}
+			filter := &logr.StdFilter{Lvl: logr.Debug, Stacktrace: logr.Panic}
+			formatter := &format.Plain{Delim: " | ", DisableTimestamp: true, DisableLevel: true}
+			params := &target.SyslogParams{Network: network, Raddr: server.Addr(), Priority: syslog.LOG_DAEMON, Tag: "logrtest"}
+			tgt, err := target.NewSyslogTarget(filter, formatter, params, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = lgr.AddTarget(tgt); err != nil {
+				t.Fatal(err)
+			}
+
+			logger := lgr.NewLogger().WithField("name", "wiggin")
+			logger.Error("disk full")
+			logger.Warn("disk almost full")
+			logger.Info("disk checked")
+			logger.Debug("disk stats")
+			logger.Trace("filtered")
+
+			if err = lgr.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			msgs, err := server.WaitForMessages(4, time.Second*5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = lgr.Shutdown(); err != nil {
+				t.Error(err)
+			}
+			if errs := server.Errors(); len(errs) > 0 {
+				t.Errorf("syslog server errors: %v", errs)
+			}
+
+			want := []struct {
+				severity syslog.Priority
+				msg      string
+			}{
+				{syslog.LOG_ERR, "disk full | name=wiggin"},
+				{syslog.LOG_WARNING, "disk almost full | name=wiggin"},
+				{syslog.LOG_INFO, "disk checked | name=wiggin"},
+				{syslog.LOG_DEBUG, "disk stats | name=wiggin"},
+			}
+			if len(msgs) != len(want) {
+				t.Fatalf("expected %d messages; got %d: %+v", len(want), len(msgs), msgs)
+			}
+			for i, w := range want {
+				m := msgs[i]
+				if m.Severity != int(w.severity) {
+					t.Errorf("message %d: expected severity %d; got %d", i, w.severity, m.Severity)
+				}
+				if m.Facility != int(syslog.LOG_DAEMON>>3) {
+					t.Errorf("message %d: expected facility %d; got %d", i, syslog.LOG_DAEMON>>3, m.Facility)
+				}
+				if m.AppName != "logrtest" {
+					t.Errorf("message %d: expected tag %q; got %q", i, "logrtest", m.AppName)
+				}
+				if m.Msg != w.msg {
+					t.Errorf("message %d: expected %q; got %q", i, w.msg, m.Msg)
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,97 @@
+package target
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mattermost/logr"
+	"github.com/wiggin77/merror"
+)
+
+// Tee is a target that passes each log record to multiple child targets,
+// allowing a group of targets to be added, removed and shut down as one.
+// Each child applies its own filter and formatter. Child targets must not
+// also be added to a Logr directly.
+type Tee struct {
+	mux     sync.RWMutex
+	name    string
+	targets []logr.Target
+}
+
+// NewTeeTarget creates a target that passes log records to the child targets,
+// in the order provided.
+func NewTeeTarget(targets ...logr.Target) *Tee {
+	return &Tee{targets: targets}
+}
+
+// SetName provides an optional name for the target.
+func (t *Tee) SetName(name string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.name = name
+}
+
+// String returns a name for this target.
+func (t *Tee) String() string {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.name != "" {
+		return t.name
+	}
+	return "Tee"
+}
+
+// IsLevelEnabled returns true if any child target has the level enabled,
+// and whether any of those children require a stack trace.
+func (t *Tee) IsLevelEnabled(lvl logr.Level) (enabled bool, stacktrace bool) {
+	for _, child := range t.targets {
+		e, s := child.IsLevelEnabled(lvl)
+		enabled = enabled || e
+		stacktrace = stacktrace || (e && s)
+	}
+	return enabled, stacktrace
+}
+
+// Levels returns the custom levels supported by any child target.
+func (t *Tee) Levels() []logr.Level {
+	var levels []logr.Level
+	for _, child := range t.targets {
+		if ll, ok := child.(logr.LevelLister); ok {
+			levels = append(levels, ll.Levels()...)
+		}
+	}
+	return levels
+}
+
+// Formatter returns nil since each child target formats log records itself.
+func (t *Tee) Formatter() logr.Formatter {
+	return nil
+}
+
+// Log passes the log record to each child target that has its level enabled.
+func (t *Tee) Log(rec *logr.LogRec) {
+	logr.Forward(rec, t.targets...)
+}
+
+// Reopen calls `Reopen` on each child target that implements logr.Reopener.
+func (t *Tee) Reopen() error {
+	errs := merror.New()
+	for _, child := range t.targets {
+		if r, ok := child.(logr.Reopener); ok {
+			errs.Append(r.Reopen())
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// Shutdown shuts down the child targets, in reverse order, before returning.
+// Log records already passed to a child are flushed by that child.
+func (t *Tee) Shutdown(ctx context.Context) error {
+	errs := merror.New()
+	for i := len(t.targets) - 1; i >= 0; i-- {
+		if err := t.targets[i].Shutdown(ctx); err != nil {
+			errs.Append(err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,67 @@
+package target_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+)
+
+func TestTee(t *testing.T) {
+	lgr := &logr.Logr{}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	var mux sync.Mutex
+	counts := make(map[string]int)
+	slowFn := func(name string) func([]byte, *logr.LogRec) {
+		return func(formatted []byte, rec *logr.LogRec) {
+			time.Sleep(time.Millisecond)
+			mux.Lock()
+			defer mux.Unlock()
+			counts[name]++
+		}
+	}
+
+	errors := target.NewFuncTarget(&logr.StdFilter{Lvl: logr.Error, Stacktrace: logr.Panic}, formatter, slowFn("errors"), 1000)
+	all := target.NewFuncTarget(&logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}, formatter, slowFn("all"), 1000)
+	tee := target.NewTeeTarget(errors, all)
+	err := lgr.AddTarget(tee)
+	if err != nil {
+		t.Error(err)
+	}
+
+	logger := lgr.NewLogger()
+	for i := 0; i < 20; i++ {
+		logger.Info("info")
+		logger.Error("error")
+	}
+
+	err = lgr.Flush()
+	if err != nil {
+		t.Error(err)
+	}
+	mux.Lock()
+	if counts["errors"] != 20 || counts["all"] != 40 {
+		t.Errorf("children not flushed: %v", counts)
+	}
+	mux.Unlock()
+
+	for i := 0; i < 20; i++ {
+		logger.Error("error")
+	}
+
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// children must be drained before the tee reports shut down.
+	mux.Lock()
+	defer mux.Unlock()
+	if counts["errors"] != 40 || counts["all"] != 60 {
+		t.Errorf("children not drained before shutdown returned: %v", counts)
+	}
+}
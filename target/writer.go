@@ -31,10 +31,24 @@ func (w *Writer) Write(rec *logr.LogRec) error {
 	buf := rec.Logger().Logr().BorrowBuffer()
 	defer rec.Logger().Logr().ReleaseBuffer(buf)
 
-	buf, err := w.Formatter().Format(rec, stacktrace, buf)
+	buf.Write(w.LinePrefix())
+	buf, err := w.FormatRecord(rec, stacktrace, buf)
 	if err != nil {
 		return err
 	}
+	w.EndRecord(buf)
 	_, err = w.out.Write(buf.Bytes())
 	return err
 }
+
+// CloneTarget creates a new Writer target with the same configuration,
+// outputting to out, or to the same io.Writer as this target if out is nil.
+// See `logr.Logr.Clone`.
+func (w *Writer) CloneTarget(out io.Writer) (logr.Target, error) {
+	if out == nil {
+		out = w.out
+	}
+	clone := &Writer{out: out}
+	clone.Basic.StartClone(&w.Basic, clone, clone)
+	return clone, nil
+}
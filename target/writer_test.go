@@ -1,6 +1,8 @@
 package target_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -83,3 +85,149 @@ func writer(t *testing.T, formatter logr.Formatter) {
 		t.Errorf("wrong level(s) enabled")
 	}
 }
+
+// msgFormatter outputs only the message, without a trailing newline.
+type msgFormatter struct{}
+
+func (f msgFormatter) Format(rec *logr.LogRec, stacktrace bool, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	buf.WriteString(rec.Msg())
+	return buf, nil
+}
+
+func TestWriterRecordSeparator(t *testing.T) {
+	jsonFmt := &format.JSON{DisableTimestamp: true, DisableLevel: true}
+	tests := []struct {
+		name      string
+		formatter logr.Formatter
+		sep       []byte
+		want      string
+	}{
+		{name: "default", formatter: jsonFmt, sep: nil, want: "{\"msg\":\"one\"}\n{\"msg\":\"two\"}\n"},
+		{name: "crlf", formatter: jsonFmt, sep: []byte("\r\n"), want: "{\"msg\":\"one\"}\r\n{\"msg\":\"two\"}\r\n"},
+		{name: "none", formatter: jsonFmt, sep: []byte{}, want: "{\"msg\":\"one\"}{\"msg\":\"two\"}"},
+		// output of formatters without a trailing newline is unchanged by default.
+		{name: "no newline default", formatter: msgFormatter{}, sep: nil, want: "onetwo"},
+		{name: "no newline crlf", formatter: msgFormatter{}, sep: []byte("\r\n"), want: "one\r\ntwo\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := &logr.Logr{}
+			buf := &test.Buffer{}
+			filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+			tgt := target.NewWriterTarget(filter, tt.formatter, buf, 1000)
+			tgt.SetRecordSeparator(tt.sep)
+			if err := lgr.AddTarget(tgt); err != nil {
+				t.Fatal(err)
+			}
+
+			logger := lgr.NewLogger()
+			logger.Info("one")
+			logger.Info("two")
+
+			if err := lgr.Shutdown(); err != nil {
+				t.Error(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected: %q;  got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// flakyWriter fails every write while failing is set.
+type flakyWriter struct {
+	test.Buffer
+	failing bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.failing {
+		return 0, errors.New("disk full")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWriterDisableOnErrors(t *testing.T) {
+	now := time.Date(2020, 5, 17, 10, 30, 0, 0, time.UTC)
+	var reported []error
+	lgr := &logr.Logr{
+		Clock:         func() time.Time { return now },
+		OnLoggerError: func(err error) { reported = append(reported, err) },
+	}
+	out := &flakyWriter{failing: true}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true, DisableLevel: true}
+	tgt := target.NewWriterTarget(filter, formatter, out, 1000)
+	tgt.SetSynchronous(true)
+	tgt.SetDisableOnErrors(3, time.Minute)
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+	logger := lgr.NewLogger()
+
+	for i := 0; i < 10; i++ {
+		logger.Info("lost")
+	}
+	if tgt.Healthy() || lgr.Healthy() {
+		t.Error("expected target to be disabled")
+	}
+	if len(reported) != 3 || !strings.Contains(reported[2].Error(), "disabled after 3 consecutive write errors") {
+		t.Errorf("unexpected errors reported: %v", reported)
+	}
+	if got := tgt.DisabledDrops(); got != 7 {
+		t.Errorf("expected 7 disabled drops, got %d", got)
+	}
+
+	// a failed probe is not reported.
+	now = now.Add(time.Minute)
+	logger.Info("probe")
+	if len(reported) != 3 {
+		t.Errorf("unexpected errors reported: %v", reported)
+	}
+
+	// the next probe succeeds and re-enables the target.
+	out.failing = false
+	logger.Info("dropped")
+	now = now.Add(time.Minute)
+	logger.Info("recovered")
+	logger.Info("again")
+	if !tgt.Healthy() || !lgr.Healthy() {
+		t.Error("expected target to be re-enabled")
+	}
+	if got := tgt.DisabledDrops(); got != 8 {
+		t.Errorf("expected 8 disabled drops, got %d", got)
+	}
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+	if want := "{\"msg\":\"recovered\"}\n{\"msg\":\"again\"}\n"; out.String() != want {
+		t.Errorf("expected: %q;  got: %q", want, out.String())
+	}
+}
+
+func TestWriterLinePrefix(t *testing.T) {
+	lgr := &logr.Logr{}
+	buf := &test.Buffer{}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.JSON{DisableTimestamp: true, DisableLevel: true}
+	tgt := target.NewWriterTarget(filter, formatter, buf, 1000)
+	tgt.SetLinePrefix([]byte("token123 "))
+	tgt.SetLineSuffix([]byte(" #"))
+	if err := lgr.AddTarget(tgt); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := lgr.NewLogger()
+	logger.Info("one")
+	logger.Info("two")
+
+	if err := lgr.Shutdown(); err != nil {
+		t.Error(err)
+	}
+	want := "token123 {\"msg\":\"one\"} #\ntoken123 {\"msg\":\"two\"} #\n"
+	if got := buf.String(); got != want {
+		t.Errorf("expected: %q;  got: %q", want, got)
+	}
+}
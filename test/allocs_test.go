@@ -0,0 +1,96 @@
+package test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+	"github.com/mattermost/logr/target"
+)
+
+// Allocation budgets for hot paths, each the count measured without the race
+// detector. A change that exceeds a budget should be treated as a performance
+// regression unless the budget is deliberately raised.
+const (
+	// budgetJSONFormat covers the sorted context field keys and the encoded
+	// log record.
+	budgetJSONFormat = 2
+
+	// budgetJSONTypedFields covers a new log record, boxing its typed field
+	// values and the encoded log record.
+	budgetJSONTypedFields = 4
+
+	// budgetPlainTypedFields covers a new log record (1), the message (1),
+	// passing each of the two typed fields to the formatter (2), boxing the
+	// int value (1) and fmt.Fprintf writing both key/value pairs (5).
+	budgetPlainTypedFields = 1 + 1 + 2 + 1 + 5
+
+	// budgetWithFields covers the new Logger's field map.
+	budgetWithFields = 2
+
+	// budgetTypedFields covers the typed field slice.
+	budgetTypedFields = 1
+
+	// budgetLogFiltered covers the variadic args slice, which escapes because
+	// enabled log records keep it.
+	budgetLogFiltered = 1
+)
+
+func checkAllocs(t *testing.T, name string, budget float64, f func()) {
+	t.Helper()
+	if allocs := testing.AllocsPerRun(100, f); allocs > budget {
+		t.Errorf("%s: %.1f allocations exceeds budget of %.0f", name, allocs, budget)
+	}
+}
+
+func TestAllocationBudgets(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector adds allocations")
+	}
+	lgr := &logr.Logr{}
+	filter := &logr.StdFilter{Lvl: logr.Fatal}
+	err := lgr.AddTarget(target.NewWriterTarget(filter, &format.Plain{}, ioutil.Discard, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lgr.Shutdown()
+
+	formatter := &format.JSON{}
+	rec := newBenchRec(lgr)
+	buf := &bytes.Buffer{}
+	checkAllocs(t, "JSON.Format", budgetJSONFormat, func() {
+		buf.Reset()
+		_, _ = formatter.Format(rec, false, buf)
+	})
+
+	// a new log record per run since typed fields must not be merged, and
+	// cached, per record.
+	typed := lgr.NewLogger().With(logr.String("name", "Wiggin"), logr.Int("count", 42))
+	checkAllocs(t, "JSON.Format typed fields", budgetJSONTypedFields, func() {
+		buf.Reset()
+		_, _ = formatter.Format(logr.NewLogRec(logr.Error, typed, "msg", nil, false), false, buf)
+	})
+
+	plain := &format.Plain{}
+	checkAllocs(t, "Plain.Format typed fields", budgetPlainTypedFields, func() {
+		buf.Reset()
+		_, _ = plain.Format(logr.NewLogRec(logr.Error, typed, "msg", nil, false), false, buf)
+	})
+
+	logger := lgr.NewLogger().WithFields(logr.Fields{"name": "Wiggin"})
+	fields := logr.Fields{"count": 42, "ok": true}
+	checkAllocs(t, "Logger.WithFields", budgetWithFields, func() {
+		Logger = logger.WithFields(fields)
+	})
+
+	checkAllocs(t, "Logger.With", budgetTypedFields, func() {
+		Logger = lgr.NewLogger().With(logr.String("name", "Wiggin"), logr.Int("count", 42))
+	})
+
+	logger.Error("level cache primer")
+	checkAllocs(t, "filtered Logger.Error", budgetLogFiltered, func() {
+		logger.Error("filtered out")
+	})
+}
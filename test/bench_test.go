@@ -1,7 +1,9 @@
 package test
 
 import (
+	"bytes"
 	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/mattermost/logr"
@@ -15,6 +17,9 @@ var Enabled bool
 // Stacktrace avoids compiler optimization.
 var Stacktrace bool
 
+// Logger avoids compiler optimization.
+var Logger logr.Logger
+
 // BenchmarkFilterOut benchmarks `logr.IsLevelEnabled` with empty level cache.
 func BenchmarkFilterOut(b *testing.B) {
 	lgr := &logr.Logr{}
@@ -142,3 +147,104 @@ func BenchmarkLogger(b *testing.B) {
 		b.Error(err)
 	}
 }
+
+// newBenchRec creates a log record with a few fields for formatter benchmarks.
+func newBenchRec(lgr *logr.Logr) *logr.LogRec {
+	logger := lgr.NewLogger().WithFields(logr.Fields{
+		"name":  "Wiggin",
+		"count": 42,
+		"ok":    true,
+	})
+	return logr.NewLogRec(logr.Error, logger, "log entry %d", []interface{}{42}, false)
+}
+
+// Benchmark_JSON_Format measures formatting a log record with the JSON formatter
+// into a reused buffer, which is what targets do.
+func Benchmark_JSON_Format(b *testing.B) {
+	lgr := &logr.Logr{}
+	formatter := &format.JSON{}
+	rec := newBenchRec(lgr)
+	buf := &bytes.Buffer{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if _, err := formatter.Format(rec, false, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Benchmark_Sugar_WithFields measures adding fields to a Logger that already
+// has fields, which copies the parent fields.
+func Benchmark_Sugar_WithFields(b *testing.B) {
+	lgr := &logr.Logr{}
+	logger := lgr.NewLogger().WithFields(logr.Fields{"name": "Wiggin"})
+	fields := logr.Fields{"count": 42, "ok": true}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Logger = logger.WithFields(fields)
+	}
+}
+
+// Benchmark_TypedFields measures adding typed fields to a Logger.
+func Benchmark_TypedFields(b *testing.B) {
+	lgr := &logr.Logr{}
+	logger := lgr.NewLogger()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Logger = logger.With(logr.String("name", "Wiggin"), logr.Int("count", 42))
+	}
+}
+
+// BenchmarkWriterTarget measures logging to a file via a Writer target,
+// including the time taken to output the records.
+func BenchmarkWriterTarget(b *testing.B) {
+	benchmarkFileTarget(b, func(f *os.File) logr.Target {
+		filter := &logr.StdFilter{Lvl: logr.Info}
+		formatter := &format.Plain{Delim: " | "}
+		return target.NewWriterTarget(filter, formatter, f, 1000)
+	})
+}
+
+// BenchmarkBufferedWriterTarget measures logging to a file via a
+// BufferedWriter target, including the time taken to output the records.
+func BenchmarkBufferedWriterTarget(b *testing.B) {
+	benchmarkFileTarget(b, func(f *os.File) logr.Target {
+		filter := &logr.StdFilter{Lvl: logr.Info}
+		formatter := &format.Plain{Delim: " | "}
+		t, err := target.NewBufferedWriterTarget(filter, formatter, f, 0, 0, 1000)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return t
+	})
+}
+
+func benchmarkFileTarget(b *testing.B, newTarget func(f *os.File) logr.Target) {
+	f, err := ioutil.TempFile("", "logr_bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	lgr := &logr.Logr{}
+	if err := lgr.AddTarget(newTarget(f)); err != nil {
+		b.Fatal(err)
+	}
+	logger := lgr.NewLogger().WithFields(logr.Fields{"name": "Wiggin"})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Infof("log entry %d", i)
+	}
+	if err := lgr.Shutdown(); err != nil {
+		b.Error(err)
+	}
+}
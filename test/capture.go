@@ -0,0 +1,179 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/logr"
+)
+
+// CapturedRecord is a log record received by a Capture target.
+type CapturedRecord struct {
+	Level  logr.Level
+	Msg    string
+	Fields logr.Fields
+}
+
+// String returns the level, message and fields of the captured record.
+func (cr CapturedRecord) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s", cr.Level.Name, cr.Msg)
+	if len(cr.Fields) > 0 {
+		sb.WriteString(" ")
+		logr.WriteFields(&sb, cr.Fields, " ")
+	}
+	return sb.String()
+}
+
+// Capture is a synchronous target that keeps every log record it receives, at
+// any level, for making assertions about what was or was not logged. Since it
+// is synchronous, log records are captured before the logging call returns and
+// no flush is needed before checking.
+type Capture struct {
+	logr.Basic
+
+	mux  sync.Mutex
+	recs []CapturedRecord
+}
+
+var (
+	capturesMux sync.Mutex
+	captures    = make(map[*logr.Logr]*Capture)
+)
+
+// NewCapture creates a Capture target and adds it to the Logr. The Capture
+// is also used by `AssertNoRecords` and `AssertNoRecordsAbove` for that Logr,
+// and is remembered for the life of the test binary.
+func NewCapture(lgr *logr.Logr) (*Capture, error) {
+	c := &Capture{}
+	c.Basic.SetSynchronous(true)
+	c.Basic.Start(c, c, captureFilter{}, &logr.DefaultFormatter{}, 1000)
+	if err := lgr.AddTarget(c); err != nil {
+		return nil, err
+	}
+
+	capturesMux.Lock()
+	defer capturesMux.Unlock()
+	captures[lgr] = c
+	return c, nil
+}
+
+// Write keeps a copy of the log record.
+func (c *Capture) Write(rec *logr.LogRec) error {
+	cr := CapturedRecord{
+		Level:  rec.Level(),
+		Msg:    rec.Msg(),
+		Fields: rec.Fields(),
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.recs = append(c.recs, cr)
+	return nil
+}
+
+// Records returns a copy of the log records captured so far.
+func (c *Capture) Records() []CapturedRecord {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	recs := make([]CapturedRecord, len(c.recs))
+	copy(recs, c.recs)
+	return recs
+}
+
+// Count returns the number of log records captured at the specified level.
+func (c *Capture) Count(level logr.Level) int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var count int
+	for _, cr := range c.recs {
+		if cr.Level.ID == level.ID {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset discards the log records captured so far.
+func (c *Capture) Reset() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.recs = nil
+}
+
+// Above returns the log records captured that are more severe than the
+// specified level, i.e. have a lower level ID, as ordered by `logr.StdFilter`.
+func (c *Capture) Above(level logr.Level) []CapturedRecord {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	var recs []CapturedRecord
+	for _, cr := range c.recs {
+		if cr.Level.ID < level.ID {
+			recs = append(recs, cr)
+		}
+	}
+	return recs
+}
+
+// AssertNoRecords fails the test if any log records have been captured for the
+// Logr. A Capture must have been added via `NewCapture`.
+func AssertNoRecords(tb testing.TB, lgr *logr.Logr) bool {
+	tb.Helper()
+	c := captureFor(tb, lgr)
+	if c == nil {
+		return false
+	}
+	return assertNone(tb, c.Records(), "no log records")
+}
+
+// AssertNoRecordsAbove fails the test if any log records more severe than
+// the specified level have been captured for the Logr, e.g.
+// `AssertNoRecordsAbove(t, lgr, logr.Warn)` fails for Error, Fatal and Panic.
+// A Capture must have been added via `NewCapture`.
+func AssertNoRecordsAbove(tb testing.TB, lgr *logr.Logr, level logr.Level) bool {
+	tb.Helper()
+	c := captureFor(tb, lgr)
+	if c == nil {
+		return false
+	}
+	return assertNone(tb, c.Above(level), "no log records above "+level.Name)
+}
+
+func captureFor(tb testing.TB, lgr *logr.Logr) *Capture {
+	tb.Helper()
+	capturesMux.Lock()
+	c := captures[lgr]
+	capturesMux.Unlock()
+	if c == nil {
+		tb.Error("no Capture target for Logr; call test.NewCapture first")
+	}
+	return c
+}
+
+func assertNone(tb testing.TB, recs []CapturedRecord, want string) bool {
+	tb.Helper()
+	if len(recs) == 0 {
+		return true
+	}
+	var sb strings.Builder
+	for _, cr := range recs {
+		sb.WriteString("\n  ")
+		sb.WriteString(cr.String())
+	}
+	tb.Errorf("expected %s, got %d:%s", want, len(recs), sb.String())
+	return false
+}
+
+// captureFilter enables all levels, including custom levels, without
+// stack traces.
+type captureFilter struct{}
+
+func (captureFilter) IsEnabled(logr.Level) bool {
+	return true
+}
+
+func (captureFilter) IsStacktraceEnabled(logr.Level) bool {
+	return false
+}
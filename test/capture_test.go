@@ -0,0 +1,104 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/logr"
+)
+
+// recordingTB records errors instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Error(args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprint(args...))
+}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestCapture(t *testing.T) {
+	lgr := &logr.Logr{}
+	c, err := NewCapture(lgr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lgr.Shutdown()
+
+	logger := lgr.NewLogger()
+	logger.Debug("checking")
+	logger.Warn("slow")
+
+	// synchronous, so no flush is needed.
+	if got := c.Count(logr.Warn); got != 1 {
+		t.Errorf("expected 1 warn record, got %d", got)
+	}
+	AssertNoRecordsAbove(t, lgr, logr.Warn)
+
+	tb := &recordingTB{TB: t}
+	if AssertNoRecordsAbove(tb, lgr, logr.Info) {
+		t.Error("expected failure for warn record")
+	}
+	if len(tb.errs) != 1 || !strings.Contains(tb.errs[0], "warn slow") {
+		t.Errorf("unexpected errors: %q", tb.errs)
+	}
+
+	c.Reset()
+	AssertNoRecords(t, lgr)
+
+	logger.WithField("user", "bob").Error("denied")
+	tb = &recordingTB{TB: t}
+	if AssertNoRecords(tb, lgr) {
+		t.Error("expected failure for error record")
+	}
+	if len(tb.errs) != 1 || !strings.Contains(tb.errs[0], "error denied user=bob") {
+		t.Errorf("unexpected errors: %q", tb.errs)
+	}
+}
+
+func TestCaptureMissing(t *testing.T) {
+	tb := &recordingTB{TB: t}
+	if AssertNoRecords(tb, &logr.Logr{}) {
+		t.Error("expected failure without a Capture")
+	}
+	if len(tb.errs) != 1 {
+		t.Errorf("unexpected errors: %q", tb.errs)
+	}
+}
+
+func ExampleCapture() {
+	lgr := &logr.Logr{}
+	c, _ := NewCapture(lgr)
+	defer lgr.Shutdown()
+
+	logger := lgr.NewLogger()
+	logger.Info("starting")
+	logger.Warn("retrying")
+	logger.Warn("retrying")
+
+	fmt.Println(c.Count(logr.Warn), len(c.Above(logr.Warn)))
+	// Output: 2 0
+}
+
+func ExampleAssertNoRecordsAbove() {
+	var t testing.TB // the test's *testing.T
+
+	lgr := &logr.Logr{}
+	if _, err := NewCapture(lgr); err != nil {
+		return
+	}
+	defer lgr.Shutdown()
+
+	lgr.NewLogger().Warn("cache miss")
+
+	if t != nil {
+		AssertNoRecordsAbove(t, lgr, logr.Warn)
+	}
+}
@@ -12,6 +12,7 @@ type TestMetrics struct {
 	Errors    float64
 	Dropped   float64
 	Blocked   float64
+	Latencies float64
 }
 
 type TestMetricsCollector struct {
@@ -20,6 +21,7 @@ type TestMetricsCollector struct {
 	errorCounters   map[string]*TestCounter
 	droppedCounters map[string]*TestCounter
 	blockedCounters map[string]*TestCounter
+	latencyHistos   map[string]*TestHistogram
 }
 
 func NewTestMetricsCollector() *TestMetricsCollector {
@@ -29,6 +31,7 @@ func NewTestMetricsCollector() *TestMetricsCollector {
 		errorCounters:   make(map[string]*TestCounter),
 		droppedCounters: make(map[string]*TestCounter),
 		blockedCounters: make(map[string]*TestCounter),
+		latencyHistos:   make(map[string]*TestHistogram),
 	}
 }
 
@@ -39,6 +42,7 @@ func (c *TestMetricsCollector) Get(target string) TestMetrics {
 		Errors:    c.errorCounters[target].get(),
 		Dropped:   c.droppedCounters[target].get(),
 		Blocked:   c.blockedCounters[target].get(),
+		Latencies: c.latencyHistos[target].count(),
 	}
 }
 
@@ -87,6 +91,15 @@ func (c *TestMetricsCollector) BlockedCounter(target string) (logr.Counter, erro
 	return counter, nil
 }
 
+func (c *TestMetricsCollector) QueueLatencyHistogram(target string) (logr.Histogram, error) {
+	histo, ok := c.latencyHistos[target]
+	if !ok {
+		histo = &TestHistogram{}
+		c.latencyHistos[target] = histo
+	}
+	return histo, nil
+}
+
 type TestGauge struct {
 	val float64
 	mux sync.Mutex
@@ -146,3 +159,24 @@ func (c *TestCounter) Add(val float64) {
 	defer c.mux.Unlock()
 	c.val += val
 }
+
+type TestHistogram struct {
+	observations []float64
+	mux          sync.Mutex
+}
+
+func (h *TestHistogram) count() float64 {
+	if h == nil {
+		return 0
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return float64(len(h.observations))
+}
+
+func (h *TestHistogram) Observe(val float64) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.observations = append(h.observations, val)
+}
@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package test
+
+// raceEnabled reports whether the race detector is enabled, which adds
+// allocations of its own.
+const raceEnabled = true
@@ -34,10 +34,12 @@ func (st *SlowTarget) Write(rec *logr.LogRec) error {
 	buf := rec.Logger().Logr().BorrowBuffer()
 	defer rec.Logger().Logr().ReleaseBuffer(buf)
 
-	buf, err := st.Formatter().Format(rec, stacktrace, buf)
+	buf.Write(st.LinePrefix())
+	buf, err := st.FormatRecord(rec, stacktrace, buf)
 	if err != nil {
 		return err
 	}
+	st.EndRecord(buf)
 
 	time.Sleep(st.Delay)
 
@@ -0,0 +1,382 @@
+package test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogMessage is a syslog message received by SyslogServer.
+type SyslogMessage struct {
+	Facility int
+	Severity int
+
+	// Version is 1 for RFC 5424 messages and 0 for RFC 3164 messages.
+	Version int
+
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData string
+
+	// Msg is the message content, excluding any trailing newline.
+	Msg string
+}
+
+// SyslogServer is an in-process syslog server listening on the loopback
+// interface, for testing syslog targets without a syslog daemon.
+// It accepts RFC 3164 and RFC 5424 messages, over UDP, or over TCP using
+// either newline or octet counting framing (RFC 6587).
+type SyslogServer struct {
+	network string
+	conn    net.PacketConn
+	ln      net.Listener
+
+	mux     sync.Mutex
+	msgs    []SyslogMessage
+	errs    []error
+	notify  chan struct{}
+	clients []net.Conn
+	closed  bool
+
+	wg sync.WaitGroup
+}
+
+// NewSyslogServer starts a syslog server listening on a random loopback port.
+// network is "udp" or "tcp".
+func NewSyslogServer(network string) (*SyslogServer, error) {
+	s := &SyslogServer{network: network, notify: make(chan struct{}, 1)}
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		s.conn = conn
+		s.wg.Add(1)
+		go s.readPackets()
+	case "tcp":
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		s.ln = ln
+		s.wg.Add(1)
+		go s.accept()
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+	return s, nil
+}
+
+// Network returns the network the server is listening on.
+func (s *SyslogServer) Network() string {
+	return s.network
+}
+
+// Addr returns the address the server is listening on.
+func (s *SyslogServer) Addr() string {
+	if s.conn != nil {
+		return s.conn.LocalAddr().String()
+	}
+	return s.ln.Addr().String()
+}
+
+// Messages returns a copy of the messages received so far.
+func (s *SyslogServer) Messages() []SyslogMessage {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	msgs := make([]SyslogMessage, len(s.msgs))
+	copy(msgs, s.msgs)
+	return msgs
+}
+
+// Errors returns any errors parsing received messages.
+func (s *SyslogServer) Errors() []error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	errs := make([]error, len(s.errs))
+	copy(errs, s.errs)
+	return errs
+}
+
+// WaitForMessages waits until at least count messages have been received, or
+// the timeout elapses, and returns the messages received.
+func (s *SyslogServer) WaitForMessages(count int, timeout time.Duration) ([]SyslogMessage, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		msgs := s.Messages()
+		if len(msgs) >= count {
+			return msgs, nil
+		}
+		select {
+		case <-s.notify:
+		case <-timer.C:
+			return msgs, fmt.Errorf("timed out waiting for %d syslog messages; got %d", count, len(msgs))
+		}
+	}
+}
+
+// Close stops the server and closes any client connections.
+func (s *SyslogServer) Close() error {
+	s.mux.Lock()
+	s.closed = true
+	s.mux.Unlock()
+
+	var err error
+	if s.conn != nil {
+		err = s.conn.Close()
+	} else {
+		err = s.ln.Close()
+		s.mux.Lock()
+		for _, c := range s.clients {
+			c.Close()
+		}
+		s.mux.Unlock()
+	}
+	s.wg.Wait()
+	return err
+}
+
+func (s *SyslogServer) isClosed() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.closed
+}
+
+func (s *SyslogServer) readPackets() {
+	defer s.wg.Done()
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.add(buf[:n])
+	}
+}
+
+func (s *SyslogServer) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mux.Lock()
+		s.clients = append(s.clients, conn)
+		s.mux.Unlock()
+
+		s.wg.Add(1)
+		go s.readStream(conn)
+	}
+}
+
+func (s *SyslogServer) readStream(conn net.Conn) {
+	defer s.wg.Done()
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		frame, err := readSyslogFrame(r)
+		if len(frame) > 0 {
+			s.add(frame)
+		}
+		if err != nil {
+			if err != io.EOF && !s.isClosed() {
+				s.addError(err)
+			}
+			return
+		}
+	}
+}
+
+// readSyslogFrame reads one message from a stream, using octet counting
+// framing if the frame starts with a digit, otherwise newline framing.
+func readSyslogFrame(r *bufio.Reader) ([]byte, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if b[0] < '0' || b[0] > '9' {
+		return r.ReadBytes('\n')
+	}
+
+	lenStr, err := r.ReadString(' ')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(lenStr, " "))
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid syslog frame length %q", lenStr)
+	}
+	frame := make([]byte, n)
+	if _, err = io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (s *SyslogServer) add(frame []byte) {
+	msg, err := ParseSyslogMessage(string(frame))
+	if err != nil {
+		s.addError(err)
+		return
+	}
+	s.mux.Lock()
+	s.msgs = append(s.msgs, msg)
+	s.mux.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *SyslogServer) addError(err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+// ParseSyslogMessage parses an RFC 5424 or RFC 3164 syslog message.
+// RFC 3164 timestamps may be in the traditional `Jan _2 15:04:05` format or,
+// as sent by `log/syslog` to remote servers, RFC 3339.
+func ParseSyslogMessage(frame string) (SyslogMessage, error) {
+	var msg SyslogMessage
+
+	frame = strings.TrimSuffix(frame, "\n")
+	if !strings.HasPrefix(frame, "<") {
+		return msg, errors.New("syslog message missing priority")
+	}
+	end := strings.IndexByte(frame, '>')
+	if end < 2 || end > 4 {
+		return msg, errors.New("syslog message has invalid priority")
+	}
+	pri, err := strconv.Atoi(frame[1:end])
+	if err != nil || pri > 191 {
+		return msg, fmt.Errorf("syslog message has invalid priority %q", frame[1:end])
+	}
+	msg.Facility = pri / 8
+	msg.Severity = pri % 8
+	rest := frame[end+1:]
+
+	if strings.HasPrefix(rest, "1 ") {
+		return parseRFC5424(msg, rest[2:])
+	}
+	return parseRFC3164(msg, rest)
+}
+
+// parseRFC5424 parses the header fields following the version.
+func parseRFC5424(msg SyslogMessage, rest string) (SyslogMessage, error) {
+	msg.Version = 1
+
+	fields := strings.SplitN(rest, " ", 6)
+	if len(fields) < 6 {
+		return msg, errors.New("syslog message has truncated RFC 5424 header")
+	}
+	if fields[0] != "-" {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return msg, fmt.Errorf("syslog message has invalid timestamp: %w", err)
+		}
+		msg.Timestamp = ts
+	}
+	msg.Hostname = nilValue(fields[1])
+	msg.AppName = nilValue(fields[2])
+	msg.ProcID = nilValue(fields[3])
+	msg.MsgID = nilValue(fields[4])
+
+	sd, rest, err := splitStructuredData(fields[5])
+	if err != nil {
+		return msg, err
+	}
+	msg.StructuredData = nilValue(sd)
+	msg.Msg = strings.TrimPrefix(rest, "\ufeff") // optional BOM
+	return msg, nil
+}
+
+// splitStructuredData splits RFC 5424 structured data from the message.
+func splitStructuredData(s string) (sd string, rest string, err error) {
+	if s == "-" || strings.HasPrefix(s, "- ") {
+		return "-", strings.TrimPrefix(s[1:], " "), nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		return "", "", errors.New("syslog message has invalid structured data")
+	}
+	var escaped, quoted bool
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == ']' && !quoted:
+			if i+1 == len(s) || s[i+1] == ' ' {
+				rest = s[i+1:]
+				return s[:i+1], strings.TrimPrefix(rest, " "), nil
+			}
+		}
+	}
+	return "", "", errors.New("syslog message has unterminated structured data")
+}
+
+// parseRFC3164 parses the timestamp, hostname and tag following the priority.
+func parseRFC3164(msg SyslogMessage, rest string) (SyslogMessage, error) {
+	const stampLen = len(time.Stamp)
+	if len(rest) > stampLen && rest[stampLen] == ' ' {
+		if ts, err := time.Parse(time.Stamp, rest[:stampLen]); err == nil {
+			msg.Timestamp = ts
+			rest = rest[stampLen+1:]
+		}
+	}
+	if msg.Timestamp.IsZero() {
+		idx := strings.IndexByte(rest, ' ')
+		if idx < 0 {
+			return msg, errors.New("syslog message missing RFC 3164 timestamp")
+		}
+		ts, err := time.Parse(time.RFC3339Nano, rest[:idx])
+		if err != nil {
+			return msg, fmt.Errorf("syslog message has invalid timestamp: %w", err)
+		}
+		msg.Timestamp = ts
+		rest = rest[idx+1:]
+	}
+
+	// the hostname is optional; local syslog messages start with the tag.
+	tagEnd := strings.Index(rest, ": ")
+	if tagEnd < 0 {
+		msg.Msg = rest
+		return msg, nil
+	}
+	header := rest[:tagEnd]
+	msg.Msg = rest[tagEnd+2:]
+	if idx := strings.LastIndexByte(header, ' '); idx >= 0 {
+		msg.Hostname = header[:idx]
+		header = header[idx+1:]
+	}
+	if idx := strings.IndexByte(header, '['); idx >= 0 && strings.HasSuffix(header, "]") {
+		msg.ProcID = header[idx+1 : len(header)-1]
+		header = header[:idx]
+	}
+	msg.AppName = header
+	return msg, nil
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslogMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		want  SyslogMessage
+	}{
+		{
+			name:  "rfc5424",
+			frame: `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventID="1011"] An application event`,
+			want: SyslogMessage{Facility: 20, Severity: 5, Version: 1,
+				Timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC), Hostname: "mymachine.example.com",
+				AppName: "evntslog", MsgID: "ID47", StructuredData: `[exampleSDID@32473 iut="3" eventID="1011"]`, Msg: "An application event"},
+		},
+		{
+			name:  "rfc5424 nil values",
+			frame: "<34>1 - - su 42 - - 'su root' failed\n",
+			want:  SyslogMessage{Facility: 4, Severity: 2, Version: 1, AppName: "su", ProcID: "42", Msg: "'su root' failed"},
+		},
+		{
+			name:  "rfc3164",
+			frame: "<34>Oct 11 22:14:15 mymachine su: 'su root' failed",
+			want: SyslogMessage{Facility: 4, Severity: 2, Timestamp: time.Date(0, 10, 11, 22, 14, 15, 0, time.UTC),
+				Hostname: "mymachine", AppName: "su", Msg: "'su root' failed"},
+		},
+		{
+			name:  "log/syslog remote",
+			frame: "<27>2020-05-17T10:30:15Z host logrtest[123]: disk full\n",
+			want: SyslogMessage{Facility: 3, Severity: 3, Timestamp: time.Date(2020, 5, 17, 10, 30, 15, 0, time.UTC),
+				Hostname: "host", AppName: "logrtest", ProcID: "123", Msg: "disk full"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSyslogMessage(tt.frame)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("expected timestamp %v; got %v", tt.want.Timestamp, got.Timestamp)
+			}
+			got.Timestamp = tt.want.Timestamp
+			if got != tt.want {
+				t.Errorf("expected: %+v;  got: %+v", tt.want, got)
+			}
+		})
+	}
+
+	for _, frame := range []string{"no priority", "<999>1 - - - - - -", "<34>1 - -"} {
+		if _, err := ParseSyslogMessage(frame); err == nil {
+			t.Errorf("expected error parsing %q", frame)
+		}
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("<14>1 - - - - - - line one\n18 <14>multi\nline two"))
+
+	frame, err := readSyslogFrame(r)
+	if err != nil || string(frame) != "<14>1 - - - - - - line one\n" {
+		t.Errorf("newline framing: got %q, %v", frame, err)
+	}
+	frame, err = readSyslogFrame(r)
+	if err != nil || string(frame) != "<14>multi\nline two" {
+		t.Errorf("octet counting framing: got %q, %v", frame, err)
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/logr"
+)
+
+// Testing outputs log records to a `testing.TB` via `tb.Log`, so that log
+// output is attributed to the test and only shown on failure or when the
+// `-v` flag is used.
+type Testing struct {
+	logr.Basic
+
+	mux  sync.Mutex
+	tb   testing.TB
+	lgr  *logr.Logr
+	done bool
+}
+
+// NewTestTarget creates a target that outputs log records to a `testing.TB`.
+// When the test completes, the Logr the target belongs to is flushed so queued
+// log records appear in order with the test's output; log records arriving
+// after the test completes are discarded.
+func NewTestTarget(tb testing.TB, filter logr.Filter, formatter logr.Formatter) *Testing {
+	t := &Testing{tb: tb}
+	t.Basic.Start(t, t, filter, formatter, 1000)
+	tb.Cleanup(t.cleanup)
+	return t
+}
+
+// Log queues a log record for output, remembering the Logr it belongs to so
+// it can be flushed when the test completes.
+func (t *Testing) Log(rec *logr.LogRec) {
+	t.mux.Lock()
+	t.lgr = rec.Logger().Logr()
+	t.mux.Unlock()
+
+	t.Basic.Log(rec)
+}
+
+// Write converts the log record to bytes, via the Formatter,
+// and outputs to the testing.TB.
+func (t *Testing) Write(rec *logr.LogRec) error {
+	_, stacktrace := t.IsLevelEnabled(rec.Level())
+
+	buf := rec.Logger().Logr().BorrowBuffer()
+	defer rec.Logger().Logr().ReleaseBuffer(buf)
+
+	buf, err := t.FormatRecord(rec, stacktrace, buf)
+	if err != nil {
+		return err
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if !t.done {
+		t.tb.Log(strings.TrimRight(buf.String(), "\n"))
+	}
+	return nil
+}
+
+// cleanup flushes the Logr that owns this target, then prevents any further
+// output to the testing.TB which must not be used after the test completes.
+func (t *Testing) cleanup() {
+	t.mux.Lock()
+	lgr := t.lgr
+	t.mux.Unlock()
+
+	if lgr != nil && !lgr.IsShutdown() {
+		_ = lgr.Flush()
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.done = true
+}
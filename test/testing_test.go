@@ -0,0 +1,83 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mattermost/logr"
+	"github.com/mattermost/logr/format"
+)
+
+// fakeTB captures calls to Log and Cleanup.
+type fakeTB struct {
+	testing.TB
+	mux      sync.Mutex
+	lines    []string
+	cleanups []func()
+}
+
+func (tb *fakeTB) Log(args ...interface{}) {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	tb.lines = append(tb.lines, fmt.Sprint(args...))
+}
+
+func (tb *fakeTB) Cleanup(f func()) {
+	tb.cleanups = append(tb.cleanups, f)
+}
+
+func (tb *fakeTB) Lines() []string {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	return append([]string(nil), tb.lines...)
+}
+
+func TestTestingTarget(t *testing.T) {
+	lgr := &logr.Logr{}
+	tb := &fakeTB{TB: t}
+	filter := &logr.StdFilter{Lvl: logr.Info, Stacktrace: logr.Panic}
+	formatter := &format.Plain{DisableTimestamp: true, Delim: " | "}
+
+	tgt := NewTestTarget(tb, filter, formatter)
+	err := lgr.AddTarget(tgt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := lgr.NewLogger().WithField("name", "wiggin")
+	logger.Info("first")
+	err = lgr.Flush()
+	if err != nil {
+		t.Error(err)
+	}
+	logger.Debug("XXX")
+	logger.Warn("second")
+
+	// simulate test completion; queued records must be flushed.
+	for _, f := range tb.cleanups {
+		f()
+	}
+
+	lines := tb.Lines()
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %v", len(lines), lines)
+	}
+	if lines[0] != "info | first | name=wiggin" {
+		t.Errorf("unexpected line: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "warn | second") {
+		t.Errorf("unexpected line: %q", lines[1])
+	}
+
+	// records after test completion are discarded.
+	logger.Info("too late")
+	err = lgr.Shutdown()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(tb.Lines()) != 2 {
+		t.Errorf("records logged after cleanup: %v", tb.Lines())
+	}
+}
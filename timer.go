@@ -0,0 +1,45 @@
+package logr
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefElapsedKey is the field key used for the elapsed time added by a Logger
+// created via `Logger.WithTimer`.
+const DefElapsedKey = "elapsed"
+
+// timer holds the baseline for elapsed times, shared by all Loggers derived
+// from the Logger that created it.
+type timer struct {
+	start int64 // unix nanos, accessed atomically
+}
+
+func (t *timer) elapsed(now time.Time) time.Duration {
+	return time.Duration(now.UnixNano() - atomic.LoadInt64(&t.start))
+}
+
+// WithTimer creates a new `Logger` that adds a field, keyed by DefElapsedKey,
+// to each log record containing the time elapsed since the Logger was created
+// or `Mark` was last called. Loggers derived from it via `WithFields` share
+// the same baseline.
+func (logger Logger) WithTimer() Logger {
+	l := logger
+	l.timer = &timer{start: logger.logr.now().UnixNano()}
+	return l
+}
+
+// Mark resets the baseline for elapsed times to now. Has no effect unless
+// this Logger was created via `WithTimer`.
+func (logger Logger) Mark() {
+	if logger.timer != nil {
+		atomic.StoreInt64(&logger.timer.start, logger.logr.now().UnixNano())
+	}
+}
+
+// addElapsed adds the elapsed time field if the log record's Logger has a timer.
+func (rec *LogRec) addElapsed() {
+	if rec.logger.timer != nil {
+		rec.logger = rec.logger.WithField(DefElapsedKey, rec.logger.timer.elapsed(rec.time))
+	}
+}